@@ -0,0 +1,26 @@
+package main
+
+import (
+	"Chamael/pkg/crypto"
+	"flag"
+	"log"
+)
+
+func main() {
+	bits := flag.Int("bits", crypto.RSABitLength, "Bit length of the RSA modulus")
+	out := flag.String("out", "./configs/acc_params.yaml", "Output parameter file, referenced by AccParams in the node config")
+	flag.Parse()
+
+	setup, err := crypto.GenerateSetup(*bits)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	// 写入前按节点启动时的规则检查一遍
+	if err := setup.Validate(); err != nil {
+		log.Fatalln(err)
+	}
+	if err := crypto.WriteSetup(*out, setup); err != nil {
+		log.Fatalln(err)
+	}
+	log.Println("Wrote RSA accumulator parameters to", *out)
+}
@@ -77,6 +77,7 @@ func main() {
 
 	// NSShard 中的所有节点
 	var ps []party.HonestParty
+	var acc_setup *crypto.Setup
 
 	// 读取节点配置
 	homeDir, err := os.UserHomeDir()
@@ -93,6 +94,14 @@ func main() {
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		// 与节点使用相同的累加器参数
+		if acc_setup == nil {
+			acc_setup, err = c.AccSetup()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+		}
 		ps = append(ps, *party.NewHonestParty(uint32(c.N), uint32(c.F), uint32(c.M), uint32(c.PID), uint32(c.Snumber), uint32(c.SID), c.IPList, c.PortList, c.PK, c.SK, true))
 	}
 
@@ -100,9 +109,8 @@ func main() {
 	// 构造两个不同的累加器
 	TX1 := "Dummy TX: 1234ABCD"
 	TX2 := "Dummy TX: 5678EEFF"
-	acc_setup := crypto.TrustedSetup()
-	acc1 := crypto.FastAcc([]string{TX1}, crypto.HashToPrimeFromSha256, acc_setup)
-	acc2 := crypto.FastAcc([]string{TX2}, crypto.HashToPrimeFromSha256, acc_setup)
+	acc1 := crypto.NewAccumulator([]string{TX1}, crypto.HashToPrimeFromSha256, acc_setup).Value()
+	acc2 := crypto.NewAccumulator([]string{TX2}, crypto.HashToPrimeFromSha256, acc_setup).Value()
 	acc1_bytes := acc1.Bytes()
 	acc2_bytes := acc2.Bytes()
 
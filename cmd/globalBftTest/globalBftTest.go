@@ -4,6 +4,7 @@ import (
 	"Chamael/internal/bft"
 	"Chamael/internal/party"
 	"Chamael/pkg/config"
+	"Chamael/pkg/protobuf"
 	"time"
 
 	"fmt"
@@ -27,6 +28,10 @@ func main() {
 	}
 
 	p := party.NewHonestParty(uint32(c.N), uint32(c.F), uint32(c.M), uint32(c.PID), uint32(c.Snumber), uint32(c.SID), c.IPList, c.PortList, c.PK, c.SK, Debug)
+
+	if c.ViewTimeout > 0 {
+		bft.ViewTimeout = time.Millisecond * time.Duration(c.ViewTimeout)
+	}
 	p.InitReceiveChannel()
 
 	//fmt.Println(p.PID, p.ShardList)
@@ -34,14 +39,14 @@ func main() {
 
 	p.InitSendChannel()
 
-	inputChannel := make(chan []string, 4096)
-	outputChannel := make(chan []string, 4096)
+	inputChannel := make(chan []*protobuf.Transaction, 4096)
+	outputChannel := make(chan []*protobuf.Transaction, 4096)
 
-	txs := []string{"test-txs1", "tx2", "tx369"}
+	txs := []*protobuf.Transaction{{Data: []byte("test-txs1")}, {Data: []byte("tx2")}, {Data: []byte("tx369")}}
 	inputChannel <- txs
 
 	fmt.Println("Start HotStuffProcess", p.PID)
-	bft.HotStuffProcess(p, 1, inputChannel, outputChannel, true)
+	bft.HotStuffProcess(p, bft.NewChainState(), 1, inputChannel, outputChannel, true)
 
 	txs_out := <-outputChannel
 	fmt.Println("txs_out:", txs_out, p.PID)
@@ -4,6 +4,8 @@ import (
 	"Chamael/internal/bft"
 	"Chamael/internal/party"
 	"Chamael/pkg/config"
+	"Chamael/pkg/core"
+	"Chamael/pkg/protobuf"
 	"Chamael/pkg/txs"
 	"Chamael/pkg/utils/db"
 	"Chamael/pkg/utils/logger"
@@ -31,6 +33,52 @@ func main() {
 	}
 
 	p := party.NewHonestParty(uint32(c.N), uint32(c.F), uint32(c.M), uint32(c.PID), uint32(c.Snumber), uint32(c.SID), c.IPList, c.PortList, c.PK, c.SK, Debug)
+
+	if c.ViewTimeout > 0 {
+		bft.ViewTimeout = time.Millisecond * time.Duration(c.ViewTimeout)
+	}
+	if c.PendingTimeout > 0 {
+		bft.PendingTimeout = uint32(c.PendingTimeout)
+	}
+	bft.UseChainedHotStuff = c.HotStuffMode == "chained"
+	if c.AccParams == "" {
+		fmt.Println("AccParams is not set, using the demo RSA accumulator parameters")
+	}
+	bft.AccSetup, err = c.AccSetup()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	linkOptions := core.DefaultLinkOptions
+	if c.SendPolicy == "drop" {
+		linkOptions.Policy = core.DropWhileDown
+	}
+	if c.SendBuffer > 0 {
+		linkOptions.BufferSize = c.SendBuffer
+	}
+	var transport core.Transport
+	if c.TLS {
+		tlsTransport, err := core.NewTLSTransport(p.PID, c.IPList, c.PortList, p.SK, p.PK, Debug)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		tlsTransport.SetLinkOptions(linkOptions)
+		transport = tlsTransport
+	} else {
+		tcpTransport := core.NewTCPTransport(p.PID, c.IPList, c.PortList, Debug)
+		tcpTransport.SetLinkOptions(linkOptions)
+		transport = tcpTransport
+	}
+	if c.NetSim != "" {
+		simConfig, err := core.LoadSimConfig(c.NetSim)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		transport = core.NewSimTransport(transport, p.PID, simConfig)
+	}
+	p.SetTransport(transport)
+	if c.SignMessages {
+		p.EnableSignatures()
+	}
 	p.InitReceiveChannel()
 
 	time.Sleep(time.Second * time.Duration(c.PrepareTime/10))
@@ -44,7 +92,7 @@ func main() {
 
 	//generateStartTime := time.Now()
 	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	var Txs []string
+	var Txs []*protobuf.Transaction
 	for i := 0; i < isTxnum*c.TestEpochs; i++ {
 		tx := txs.InterTxGenerator(txlength, int(p.Snumber), int(p.PID), chars)
 		Txs = append(Txs, tx)
@@ -67,9 +115,24 @@ func main() {
 
 	ctxdb := homeDir + "/Chamael/db/cross_txs_node" + strconv.Itoa(int(p.PID)) + ".db"
 
-	itx_inputChannel := make(chan []string, 4096)
-	ctx_inputChannel := make(chan []string, 4096)
-	outputChannel := make(chan []string, 4096)
+	// 已提交区块的账本, 同时保存重启所需的检查点
+	dataDir := c.DataDir
+	if dataDir == "" {
+		dataDir = homeDir + "/Chamael/db"
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		log.Fatalln(err)
+	}
+	ledger, err := db.OpenBlockStore(fmt.Sprintf(dataDir+"/blocks_node%d.db", p.PID))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer ledger.Close()
+	p.Ledger = ledger
+
+	itx_inputChannel := make(chan []*protobuf.Transaction, 4096)
+	ctx_inputChannel := make(chan []*protobuf.Transaction, 4096)
+	outputChannel := make(chan []*protobuf.Transaction, 4096)
 
 	//预先装入一些交易
 	//loadStartTime := time.Now()
@@ -110,12 +173,13 @@ func main() {
 	block_delay_channel := make(chan time.Duration, 4096)
 	round_delay_channel := make(chan time.Duration, 4096)
 	extra_delay_channel := make(chan time.Duration, 4096)
+	pool_stats_channel := make(chan txs.PoolStats, 4096)
 	//timeChannel <- time.Now()
-	go bft.KronosProcess(p, c.TestEpochs, itx_inputChannel, ctx_inputChannel, outputChannel, timeChannel, block_delay_channel, round_delay_channel, extra_delay_channel, c.WaitTime)
+	go bft.KronosProcess(p, c.TestEpochs, itx_inputChannel, ctx_inputChannel, outputChannel, timeChannel, block_delay_channel, round_delay_channel, extra_delay_channel, pool_stats_channel, c.WaitTime)
 
 	// time.Sleep(time.Second * 15)
 	time.Sleep(time.Second * (time.Duration(c.WaitTime / 3)))
-	logger.CalculateTPS(c, *p, homeDir+"/Chamael/log/", timeChannel, outputChannel, block_delay_channel, round_delay_channel, extra_delay_channel)
+	logger.CalculateTPS(c, *p, homeDir+"/Chamael/log/", timeChannel, outputChannel, block_delay_channel, round_delay_channel, extra_delay_channel, pool_stats_channel)
 	if p.Debug {
 		logger.RenameHonest(c, *p, homeDir+"/Chamael/log/")
 	}
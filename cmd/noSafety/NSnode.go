@@ -30,6 +30,10 @@ func main() {
 
 	p := party.NewHonestParty(uint32(c.N), uint32(c.F), uint32(c.M), uint32(c.PID), uint32(c.Snumber), uint32(c.SID), c.IPList, c.PortList, c.PK, c.SK, Debug)
 
+	if c.ViewTimeout > 0 {
+		bft.ViewTimeout = time.Millisecond * time.Duration(c.ViewTimeout)
+	}
+
 	// 读取 NL.yaml 文件
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
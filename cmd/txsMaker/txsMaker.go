@@ -1,6 +1,7 @@
 package main
 
 import (
+	"Chamael/pkg/protobuf"
 	"Chamael/pkg/txs"
 	"Chamael/pkg/utils/db"
 	"flag"
@@ -26,7 +27,7 @@ func main() {
 
 	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-	var Txs []string
+	var Txs []*protobuf.Transaction
 	for i := 0; i < *txNum; i++ {
 		tx := txs.CrossTxGenerator(32, *shardNum, *rRate, *PID, chars)
 		Txs = append(Txs, tx)
@@ -3,6 +3,7 @@ module Chamael
 go 1.18
 
 require (
+	github.com/bits-and-blooms/bitset v1.22.0
 	github.com/cbergoon/merkletree v0.2.0
 	github.com/mattn/go-sqlite3 v1.14.24
 	github.com/pkg/errors v0.9.1
@@ -13,7 +14,6 @@ require (
 )
 
 require (
-	github.com/bits-and-blooms/bitset v1.22.0 // indirect
 	go.dedis.ch/fixbuf v1.0.3 // indirect
 	golang.org/x/sys v0.0.0-20201101102859-da207088b7d1 // indirect
 	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
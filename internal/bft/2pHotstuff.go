@@ -4,261 +4,462 @@ import (
 	"Chamael/internal/party"
 	"Chamael/pkg/core"
 	"Chamael/pkg/protobuf"
-	"Chamael/pkg/utils"
+	"bytes"
 	"fmt"
-	"strings"
 
+	"github.com/bits-and-blooms/bitset"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
 )
 
-// 收集足量的New_View消息后广播Prepare消息
-func Prepare_BroadCast(p *party.HonestParty, e uint32, txs []string, isGlobal bool) {
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"Prepare":        func() proto.Message { return new(protobuf.Prepare) },
+		"Prepare_Vote":   func() proto.Message { return new(protobuf.Prepare_Vote) },
+		"Precommit":      func() proto.Message { return new(protobuf.Precommit) },
+		"Precommit_Vote": func() proto.Message { return new(protobuf.Precommit_Vote) },
+		"Commit":         func() proto.Message { return new(protobuf.Commit) },
+	})
+}
+
+// 收集足量的New_View消息后,以最近提交的区块(或 highQC 所指的未提交区块)为父区块生成新区块并广播Prepare消息
+func Prepare_BroadCast(p *party.HonestParty, cs *ChainState, pm *Pacemaker, txs []*protobuf.Transaction, isGlobal bool) (*protobuf.Block, bool) {
 	var l []int
 	seen := make(map[int]bool)
-
-	var threshold int
-	if isGlobal {
-		F := (int(p.N*p.M) - 1) / 3
-		threshold = 2*F + 1
-	} else {
-		threshold = 2*int(p.F) + 1
-	}
+	threshold := quorum(p, isGlobal)
 
 	for {
-		if (len(l) >= threshold) || (e == 1) {
-			fmt.Println("New View ", e, "start")
+		// 第一个 epoch 无需等待 New_View; 视图切换后的新 Leader 以收齐的 Timeout 消息作为进入新视图的依据
+		if (len(l) >= threshold) || (pm.epoch == 1) || (pm.View() > 0) {
+			fmt.Println("New View ", pm.epoch, pm.View(), "start")
 			break
 		}
-		m := <-p.GetMessage("New_View", utils.Uint32ToBytes(e))
-		if !seen[int(m.Sender)] {
-			l = append(l, int(m.Sender))
-			seen[int(m.Sender)] = true
+		select {
+		case m := <-p.GetMessage("New_View", pm.ID()):
+			if !seen[int(m.Sender)] {
+				l = append(l, int(m.Sender))
+				seen[int(m.Sender)] = true
+			}
+		case <-pm.TimerC():
+			pm.OnLocalTimeout()
+		case m := <-pm.TimeoutMessages():
+			if pm.OnTimeout(m) {
+				return nil, false
+			}
 		}
 	}
-	PrepareMessage := core.Encapsulation("Prepare", utils.Uint32ToBytes(e), p.PID, &protobuf.Prepare{
-		Txs: txs,
-	})
-	if isGlobal {
-		p.Broadcast(PrepareMessage)
-	} else {
-		p.Intra_Broadcast(PrepareMessage)
+	// 视图切换后 highQC 可能指向已被部分节点锁定但未提交的区块, 新区块必须扩展该区块
+	parent := cs.Tip()
+	if b := cs.GetBlock(pm.HighQC().GetBlockHash()); b != nil && b.Height > parent.Height {
+		parent = b
 	}
-
+	block := NewBlock(parent.Height+1, BlockHash(parent), p.PID, txs, pm.HighQC())
+	cs.AddBlock(block)
+	PrepareMessage := core.Encapsulation("Prepare", pm.ID(), p.PID, &protobuf.Prepare{
+		Block:  block,
+		HighQC: pm.HighQC(),
+	})
+	hsBroadcast(p, PrepareMessage, isGlobal)
+	return block, true
 }
 
-// 收集足量的Prepare_Vote消息,验证AggSig1(txs||vote1||epoch)后广播Precommit消息
-func Precommit_BroadCast(p *party.HonestParty, e uint32, txs []string, isGlobal bool) {
+// 收集足量的Prepare_Vote消息,验证AggSig1(blockHash||1||epoch)后广播Precommit消息
+func Precommit_BroadCast(p *party.HonestParty, cs *ChainState, pm *Pacemaker, block *protobuf.Block, isGlobal bool) bool {
 	suite := bn256.NewSuite()
+	e := pm.epoch
 	var l []int
 	seen := make(map[int]bool)
 	var signatures [][]byte
 	var pubkeys []kyber.Point
+	signers_bm := bitset.New(uint(p.N))
+	threshold := quorum(p, isGlobal)
 
-	var threshold int
-	if isGlobal {
-		F := (int(p.N*p.M) - 1) / 3
-		threshold = 2*F + 1
-	} else {
-		threshold = 2*int(p.F) + 1
-	}
+	// Leader 自己的投票同样计入 2f+1
+	hash := BlockHash(block)
+	local := voteDigest(hash, 1, e)
+	ownSig, _ := bls.Sign(suite, p.SK, local)
+	l = append(l, int(p.PID))
+	seen[int(p.PID)] = true
+	signatures = append(signatures, ownSig)
+	pubkeys = append(pubkeys, p.PK[p.PID])
+	signers_bm.Set(signerIndex(p, p.PID, isGlobal))
 
-	for {
-		m := <-p.GetMessage("Prepare_Vote", utils.Uint32ToBytes(e))
-		payload := (core.Decapsulation("Prepare_Vote", m)).(*protobuf.Prepare_Vote)
-		if !seen[int(m.Sender)] {
+	for len(l) < threshold {
+		select {
+		case m := <-p.GetMessage("Prepare_Vote", pm.ID()):
+			raw, err := core.Decapsulation("Prepare_Vote", m)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			payload := raw.(*protobuf.Prepare_Vote)
+			if seen[int(m.Sender)] || !inCommittee(p, m.Sender, isGlobal) {
+				continue
+			}
+			// 逐条验证投票, 无效的投票不计入 2f+1, 避免聚合签名验证失败使本视图停顿
+			if bls.Verify(suite, p.PK[m.Sender], local, payload.Sig) != nil {
+				fmt.Println("Prepare_Vote verification failed(Malicious Participator)")
+				continue
+			}
 			l = append(l, int(m.Sender))
 			seen[int(m.Sender)] = true
 			signatures = append(signatures, payload.Sig)
 			pubkeys = append(pubkeys, p.PK[m.Sender])
-		}
-		if len(l) >= threshold {
-			break
+			signers_bm.Set(signerIndex(p, m.Sender, isGlobal))
+		case <-pm.TimerC():
+			pm.OnLocalTimeout()
+		case m := <-pm.TimeoutMessages():
+			if pm.OnTimeout(m) {
+				return false
+			}
 		}
 	}
 	aggSig, _ := bls.AggregateSignatures(suite, signatures...)
 	aggPubKey := bls.AggregatePublicKeys(suite, pubkeys...)
-	local := utils.MessageEncap([][]byte{[]byte(strings.Join(txs, "")), utils.Uint32ToBytes(1), utils.Uint32ToBytes(e)})
 	err := bls.Verify(suite, aggPubKey, local, aggSig)
 	if err != nil {
-		fmt.Println("AggSig1(txs||vote1||epoch) verification failed(Malicious Participator):", err)
-		return
+		fmt.Println("AggSig1(blockHash||1||epoch) verification failed(Malicious Participator):", err)
+		return false
 	}
+	signers, _ := signers_bm.MarshalBinary()
+	qc := &protobuf.QuorumCert{
+		Epoch:     e,
+		View:      pm.View(),
+		Aggsig:    aggSig,
+		BlockHash: hash,
+		Signers:   signers,
+	}
+	pm.UpdateHighQC(qc)
+	cs.UpdateLockedQC(qc)
 
-	PrecommitMessage := core.Encapsulation("Precommit", utils.Uint32ToBytes(e), p.PID, &protobuf.Precommit{
-		Aggsig: aggSig,
-		Aggpk:  utils.PointToBytes(aggPubKey),
+	PrecommitMessage := core.Encapsulation("Precommit", pm.ID(), p.PID, &protobuf.Precommit{
+		Aggsig:  aggSig,
+		Signers: signers,
 	})
-	if isGlobal {
-		p.Broadcast(PrecommitMessage)
-	} else {
-		p.Intra_Broadcast(PrecommitMessage)
-	}
+	hsBroadcast(p, PrecommitMessage, isGlobal)
+	return true
 }
 
-// 收集足量的Precommit_Vote消息,验证AggSig2(vote2||epoch)后广播Commit消息
-func Commit_BroadCast(p *party.HonestParty, e uint32, txs []string, outputChannel chan []string, isGlobal bool) {
+// 收集足量的Precommit_Vote消息,验证AggSig2(blockHash||2||epoch)后广播Commit消息
+func Commit_BroadCast(p *party.HonestParty, cs *ChainState, pm *Pacemaker, block *protobuf.Block, outputChannel chan []*protobuf.Transaction, isGlobal bool) bool {
 	suite := bn256.NewSuite()
+	e := pm.epoch
 	var l []int
 	seen := make(map[int]bool)
 	var signatures [][]byte
 	var pubkeys []kyber.Point
+	signers_bm := bitset.New(uint(p.N))
+	threshold := quorum(p, isGlobal)
 
-	var threshold int
-	if isGlobal {
-		F := (int(p.N*p.M) - 1) / 3
-		threshold = 2*F + 1
-	} else {
-		threshold = 2*int(p.F) + 1
-	}
+	// Leader 自己的投票同样计入 2f+1
+	local := voteDigest(BlockHash(block), 2, e)
+	ownSig, _ := bls.Sign(suite, p.SK, local)
+	l = append(l, int(p.PID))
+	seen[int(p.PID)] = true
+	signatures = append(signatures, ownSig)
+	pubkeys = append(pubkeys, p.PK[p.PID])
+	signers_bm.Set(signerIndex(p, p.PID, isGlobal))
 
-	for {
-		m := <-p.GetMessage("Precommit_Vote", utils.Uint32ToBytes(e))
-		payload := (core.Decapsulation("Precommit_Vote", m)).(*protobuf.Precommit_Vote)
-		if !seen[int(m.Sender)] {
+	for len(l) < threshold {
+		select {
+		case m := <-p.GetMessage("Precommit_Vote", pm.ID()):
+			raw, err := core.Decapsulation("Precommit_Vote", m)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			payload := raw.(*protobuf.Precommit_Vote)
+			if seen[int(m.Sender)] || !inCommittee(p, m.Sender, isGlobal) {
+				continue
+			}
+			// 逐条验证投票, 无效的投票不计入 2f+1, 避免聚合签名验证失败使本视图停顿
+			if bls.Verify(suite, p.PK[m.Sender], local, payload.Sig) != nil {
+				fmt.Println("Precommit_Vote verification failed(Malicious Participator)")
+				continue
+			}
 			l = append(l, int(m.Sender))
 			seen[int(m.Sender)] = true
 			signatures = append(signatures, payload.Sig)
 			pubkeys = append(pubkeys, p.PK[m.Sender])
-		}
-		if len(l) >= threshold {
-			break
+			signers_bm.Set(signerIndex(p, m.Sender, isGlobal))
+		case <-pm.TimerC():
+			pm.OnLocalTimeout()
+		case m := <-pm.TimeoutMessages():
+			if pm.OnTimeout(m) {
+				return false
+			}
 		}
 	}
 	aggSig, _ := bls.AggregateSignatures(suite, signatures...)
 	aggPubKey := bls.AggregatePublicKeys(suite, pubkeys...)
-	local := utils.MessageEncap([][]byte{utils.Uint32ToBytes(1), utils.Uint32ToBytes(e)})
 	err := bls.Verify(suite, aggPubKey, local, aggSig)
 	if err != nil {
-		fmt.Println("AggSig2(vote2||epoch) verification failed(Malicious Participator):", err)
-		return
+		fmt.Println("AggSig2(blockHash||2||epoch) verification failed(Malicious Participator):", err)
+		return false
 	}
 
-	CommitMessage := core.Encapsulation("Commit", utils.Uint32ToBytes(e), p.PID, &protobuf.Commit{
-		Aggsig: aggSig,
-		Aggpk:  utils.PointToBytes(aggPubKey),
+	signers, _ := signers_bm.MarshalBinary()
+	cs.RecordCommitQC(&protobuf.QuorumCert{
+		Epoch:     e,
+		View:      pm.View(),
+		Aggsig:    aggSig,
+		BlockHash: BlockHash(block),
+		Signers:   signers,
+		Phase:     2,
 	})
-	if isGlobal {
-		p.Broadcast(CommitMessage)
-	} else {
-		p.Intra_Broadcast(CommitMessage)
+	CommitMessage := core.Encapsulation("Commit", pm.ID(), p.PID, &protobuf.Commit{
+		Aggsig:  aggSig,
+		Signers: signers,
+	})
+	hsBroadcast(p, CommitMessage, isGlobal)
+
+	// Leader 同样为下一个 epoch 发送 New_View 消息,使下一个 Leader 在有节点崩溃时仍能收齐 2f+1 条
+	New_ViewMessage := core.Encapsulation("New_View", hsID(e+1, 0), p.PID, &protobuf.New_View{
+		None: make([]byte, 0),
+	})
+	hsBroadcast(p, New_ViewMessage, isGlobal)
+	outputChannel <- committedTxs(cs.Commit(block))
+	return true
+}
+
+// CheckProposal 检查 Leader 提议的区块: 交易与 TxRoot 一致, 父区块已知且高度连续,
+// justify 是本次共识参与者的有效 QC, 并满足 lockedQC 安全规则
+func CheckProposal(p *party.HonestParty, cs *ChainState, b *protobuf.Block, isGlobal bool) bool {
+	if !VerifyBlockTxs(b) {
+		fmt.Println("Block txRoot mismatch(Malicious Leader)", b.Height)
+		return false
 	}
-	outputChannel <- txs
+	parent := cs.GetBlock(b.Parent)
+	if parent == nil || b.Height != parent.Height+1 {
+		fmt.Println("Block with unknown parent or wrong height", b.Height)
+		return false
+	}
+	if b.Justify != nil && !verifyQC(p, b.Justify, isGlobal) {
+		fmt.Println("Block justify verification failed(Malicious Leader)", b.Height)
+		return false
+	}
+	if !cs.SafeNode(p, b, isGlobal) {
+		fmt.Println("Block conflicts with lockedQC(Malicious Leader)", b.Height)
+		return false
+	}
+	return true
 }
 
-// isGlobal: true 全局共识, false 片内共识
-func HotStuffProcess(p *party.HonestParty, epoch int, inputChannel chan []string, outputChannel chan []string, isGlobal bool) {
+// 作为普通参与节点处理当前视图的 Prepare/Precommit/Commit 消息,提交后返回 true,视图切换时返回 false
+func Replica_Process(p *party.HonestParty, cs *ChainState, pm *Pacemaker, outputChannel chan []*protobuf.Transaction, isGlobal bool) bool {
 	suite := bn256.NewSuite()
-	e := uint32(epoch)
-	var txs []string //处理自己作为Leader时提议的交易集合;从inputchannel来,所以是[]String
-	var Txs []byte   //处理自己作为普通参与者时接收的交易集合;只供验签使用,所以用[]byte
+	e := pm.epoch
+	var block *protobuf.Block // 当前视图 Leader 提议的区块
+	var hash []byte           // 区块哈希,投票与验签均针对区块哈希
 
-	var gotPrepare bool = false // 判断是否收到Prepare消息，防止Leader在收到Precommit/Commit消息后，没有收到Prepare消息，导致Txs为空
-
-	// 判断是否是Leader
-	var is_leader bool = false
-	if isGlobal { // 全局共识，选择 PID = (e-1)%(N*M)
-		if (e-1)%(p.N*p.M) == p.PID {
-			is_leader = true
-			txs = <-inputChannel
+	// 从Prepare消息中取出区块并检查安全规则,防止在收到Precommit/Commit消息后,没有收到Prepare消息,导致区块为空
+	getPrepare := func(m *protobuf.Message) bool {
+		raw, err := core.Decapsulation("Prepare", m)
+		if err != nil {
+			fmt.Println(err)
+			return false
+		}
+		payload := raw.(*protobuf.Prepare)
+		if m.Sender != pm.Leader() || payload.Block == nil || payload.Block.Proposer != m.Sender {
+			fmt.Println("Invalid block in Prepare(Malicious Leader)")
+			return false
+		}
+		// 父区块未知但 justify 证明了它, 说明本节点落后, 先向其他节点同步缺失的区块
+		b := payload.Block
+		if cs.GetBlock(b.Parent) == nil && b.Justify != nil && bytes.Equal(b.Justify.BlockHash, b.Parent) {
+			SyncTo(p, cs, pm.epoch, b.Justify, syncPeers(p, m.Sender, isGlobal), isGlobal)
 		}
-	} else { // 片内共识，选择 SID = (e-1)%N
-		if (e-1)%p.N == p.SID {
-			is_leader = true
-			txs = <-inputChannel
+		if !CheckProposal(p, cs, payload.Block, isGlobal) {
+			return false
 		}
+		block = payload.Block
+		hash = BlockHash(block)
+		cs.AddBlock(block)
+		return true
 	}
-
-	if is_leader == true { //自己作为领导者时
-		//收集足量的New_View消息后广播Prepare消息
-		Prepare_BroadCast(p, e, txs, isGlobal)
-		//收集足量的Prepare_Vote消息,验证AggSig1(txs||vote1||epoch)后广播Precommit消息
-		Precommit_BroadCast(p, e, txs, isGlobal)
-		//收集足量的Precommit_Vote消息,验证AggSig2(vote2||epoch)后广播Commit消息并把Txs放入输出通道
-		Commit_BroadCast(p, e, txs, outputChannel, isGlobal)
-
-	} else { //自己作为普通参与节点时
-	Loop:
+	// 先收到 Precommit/Commit 时等待当前 Leader 的 Prepare, 同时处理视图计时, 视图切换时返回 false
+	waitPrepare := func() bool {
 		for {
 			select {
-			//收到Prepare消息,签sig1(txs||vote1||epoch)并回复Prepare_Vote消息
-			case m := <-p.GetMessage("Prepare", utils.Uint32ToBytes(e)):
-				payload := (core.Decapsulation("Prepare", m)).(*protobuf.Prepare)
-				txs = payload.Txs
-				Txs = []byte(strings.Join(txs, ""))
-				var vote uint32
-				vote = 1
-				smessage := utils.MessageEncap([][]byte{Txs, utils.Uint32ToBytes(vote), utils.Uint32ToBytes(e)})
+			case m := <-p.GetMessage("Prepare", pm.ID()):
+				if m.Sender != pm.Leader() {
+					continue
+				}
+				return getPrepare(m)
+			case <-pm.TimerC():
+				pm.OnLocalTimeout()
+			case m := <-pm.TimeoutMessages():
+				if pm.OnTimeout(m) {
+					return false
+				}
+			}
+		}
+	}
 
-				sigPrepare, _ := bls.Sign(suite, p.SK, smessage) //sign(txs||vote1||epoch)
-				Prepare_VoteMessage := core.Encapsulation("Prepare_Vote", utils.Uint32ToBytes(e), p.PID, &protobuf.Prepare_Vote{
-					Vote: vote,
-					Sig:  sigPrepare,
-				})
-				p.Send(Prepare_VoteMessage, m.Sender)
-				gotPrepare = true
-			//收到Precommit消息,验证aggsig1(txs||vote1||epoch),签sig2(vote2||epoch)并回复Precommit_Vote消息
-			case m := <-p.GetMessage("Precommit", utils.Uint32ToBytes(e)):
-				payload := (core.Decapsulation("Precommit", m)).(*protobuf.Precommit)
+	for {
+		select {
+		//收到Prepare消息,签sig1(blockHash||1||epoch)并回复Prepare_Vote消息
+		case m := <-p.GetMessage("Prepare", pm.ID()):
+			if m.Sender != pm.Leader() {
+				continue
+			}
+			// 每个视图只为一个区块投票, 同一 Leader 的第二个 Prepare 视为作恶
+			if block != nil {
+				fmt.Println("Duplicate Prepare in view", e, pm.View(), "(Malicious Leader)")
+				continue
+			}
+			if !getPrepare(m) {
+				return false
+			}
+			// 本视图已超时则不再投票
+			if pm.TimedOut() {
+				continue
+			}
+			var vote uint32
+			vote = 1
+			sigPrepare, _ := bls.Sign(suite, p.SK, voteDigest(hash, 1, e)) //sign(blockHash||1||epoch)
+			Prepare_VoteMessage := core.Encapsulation("Prepare_Vote", pm.ID(), p.PID, &protobuf.Prepare_Vote{
+				Vote: vote,
+				Sig:  sigPrepare,
+			})
+			p.Send(Prepare_VoteMessage, m.Sender)
+		//收到Precommit消息,验证aggsig1(blockHash||1||epoch),签sig2(blockHash||2||epoch)并回复Precommit_Vote消息
+		case m := <-p.GetMessage("Precommit", pm.ID()):
+			if m.Sender != pm.Leader() {
+				continue
+			}
+			raw, err := core.Decapsulation("Precommit", m)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			payload := raw.(*protobuf.Precommit)
 
-				if !gotPrepare {
-					mPrepare := <-p.GetMessage("Prepare", utils.Uint32ToBytes(e))
-					payloadPrepare := (core.Decapsulation("Prepare", mPrepare)).(*protobuf.Prepare)
-					txs = payloadPrepare.Txs
-					Txs = []byte(strings.Join(txs, ""))
-					gotPrepare = true
-				}
+			if block == nil && !waitPrepare() {
+				return false
+			}
 
-				sver := utils.MessageEncap([][]byte{Txs, utils.Uint32ToBytes(1), utils.Uint32ToBytes(e)})
-				AggPK := utils.BytesToPoint(payload.Aggpk)
-				err := bls.Verify(suite, AggPK, sver, payload.Aggsig)
-				if err != nil {
-					fmt.Println("AggSig1(txs||vote1||epoch) verification failed(Malicious Leader):", err)
-					return
-				}
+			err = verifyAggregate(p, payload.Signers, voteDigest(hash, 1, e), payload.Aggsig, isGlobal)
+			if err != nil {
+				fmt.Println("AggSig1(blockHash||1||epoch) verification failed(Malicious Leader):", err)
+				return false
+			}
+			// prepareQC 成为 highQC, 同时锁定该区块: 此后只为扩展该区块的提议(或 justify 更高的提议)投票
+			qc := &protobuf.QuorumCert{
+				Epoch:     e,
+				View:      pm.View(),
+				Aggsig:    payload.Aggsig,
+				BlockHash: hash,
+				Signers:   payload.Signers,
+			}
+			pm.UpdateHighQC(qc)
+			cs.UpdateLockedQC(qc)
+			if pm.TimedOut() {
+				continue
+			}
 
-				var vote uint32
-				vote = 1
-				smessage := utils.MessageEncap([][]byte{utils.Uint32ToBytes(vote), utils.Uint32ToBytes(e)})
+			var vote uint32
+			vote = 1
+			sigPrecommit, _ := bls.Sign(suite, p.SK, voteDigest(hash, 2, e)) //sign(blockHash||2||epoch)
+			Precommit_VoteMessage := core.Encapsulation("Precommit_Vote", pm.ID(), p.PID, &protobuf.Precommit_Vote{
+				Vote: vote,
+				Sig:  sigPrecommit,
+			})
+			p.Send(Precommit_VoteMessage, m.Sender)
+		//收到Commit消息,验证aggsig2(blockHash||2||epoch),提交区块并回复New_View消息;
+		case m := <-p.GetMessage("Commit", pm.ID()):
+			if m.Sender != pm.Leader() {
+				continue
+			}
+			raw, err := core.Decapsulation("Commit", m)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			payload := raw.(*protobuf.Commit)
 
-				sigPrecommit, _ := bls.Sign(suite, p.SK, smessage) //sign(vote2||epoch)
-				Precommit_VoteMessage := core.Encapsulation("Precommit_Vote", utils.Uint32ToBytes(e), p.PID, &protobuf.Precommit_Vote{
-					Vote: vote,
-					Sig:  sigPrecommit,
-				})
-				p.Send(Precommit_VoteMessage, m.Sender)
-			//收到Commit消息,验证aggsig2(vote2||epoch)并回复New_View消息;
-			case m := <-p.GetMessage("Commit", utils.Uint32ToBytes(e)):
-				payload := (core.Decapsulation("Commit", m)).(*protobuf.Commit)
+			if block == nil && !waitPrepare() {
+				return false
+			}
 
-				if !gotPrepare {
-					mPrepare := <-p.GetMessage("Prepare", utils.Uint32ToBytes(e))
-					payloadPrepare := (core.Decapsulation("Prepare", mPrepare)).(*protobuf.Prepare)
-					txs = payloadPrepare.Txs
-					gotPrepare = true
-				}
+			err = verifyAggregate(p, payload.Signers, voteDigest(hash, 2, e), payload.Aggsig, isGlobal)
+			if err != nil {
+				fmt.Println("AggSig2(blockHash||2||epoch) verification failed(Malicious Leader):", err)
+				return false
+			}
+			// commitQC 随区块写入账本, 作为区块被提交的证明
+			cs.RecordCommitQC(&protobuf.QuorumCert{
+				Epoch:     e,
+				View:      pm.View(),
+				Aggsig:    payload.Aggsig,
+				BlockHash: hash,
+				Signers:   payload.Signers,
+				Phase:     2,
+			})
 
-				sver := utils.MessageEncap([][]byte{utils.Uint32ToBytes(1), utils.Uint32ToBytes(e)})
-				AggPK := utils.BytesToPoint(payload.Aggpk)
-				err := bls.Verify(suite, AggPK, sver, payload.Aggsig)
-				if err != nil {
-					fmt.Println("AggSig2(vote2||epoch) verification failed(Malicious Leader):", err)
-					return
-				}
+			New_ViewMessage := core.Encapsulation("New_View", hsID(e+1, 0), p.PID, &protobuf.New_View{
+				None: make([]byte, 0),
+			})
+			hsBroadcast(p, New_ViewMessage, isGlobal)
+			outputChannel <- committedTxs(cs.Commit(block))
+			return true
+		//本地计时器超时,广播Timeout消息,之后只等待Commit或视图切换
+		case <-pm.TimerC():
+			pm.OnLocalTimeout()
+		//收齐2f+1条Timeout消息,进入下一视图
+		case m := <-pm.TimeoutMessages():
+			if pm.OnTimeout(m) {
+				return false
+			}
+		}
+	}
+}
+
+// isGlobal: true 全局共识, false 片内共识; cs 为该共识实例跨 epoch 的区块链状态
+func HotStuffProcess(p *party.HonestParty, cs *ChainState, epoch int, inputChannel chan []*protobuf.Transaction, outputChannel chan []*protobuf.Transaction, isGlobal bool) {
+	e := uint32(epoch)
+	var txs []*protobuf.Transaction //处理自己作为Leader时提议的交易集合;从inputchannel来
 
-				New_ViewMessage := core.Encapsulation("New_View", utils.Uint32ToBytes(e+1), p.PID, &protobuf.New_View{
-					None: make([]byte, 0),
-				})
-				if isGlobal {
-					p.Broadcast(New_ViewMessage)
+	pm := NewPacemaker(p, e, isGlobal)
+	pm.UpdateHighQC(cs.HighQC())
+	defer func() {
+		pm.Stop()
+		cs.UpdateHighQC(pm.HighQC())
+	}()
+
+	for {
+		var committed bool
+		if pm.IsLeader() { //自己作为领导者时
+			if txs == nil {
+				if pm.View() == 0 {
+					txs = <-inputChannel
 				} else {
-					p.Intra_Broadcast(New_ViewMessage)
+					// 视图切换后的新 Leader 可能没有待提议的交易,此时提议空交易集合
+					select {
+					case txs = <-inputChannel:
+					default:
+						txs = []*protobuf.Transaction{}
+					}
 				}
-				outputChannel <- txs
-				break Loop
 			}
+			pm.StartTimer()
+			//收集足量的New_View消息后广播包含区块的Prepare消息
+			//收集足量的Prepare_Vote消息,验证AggSig1(blockHash||1||epoch)后广播Precommit消息
+			//收集足量的Precommit_Vote消息,验证AggSig2(blockHash||2||epoch)后广播Commit消息并把区块中的交易放入输出通道
+			block, ok := Prepare_BroadCast(p, cs, pm, txs, isGlobal)
+			committed = ok &&
+				Precommit_BroadCast(p, cs, pm, block, isGlobal) &&
+				Commit_BroadCast(p, cs, pm, block, outputChannel, isGlobal)
+		} else { //自己作为普通参与节点时
+			pm.StartTimer()
+			committed = Replica_Process(p, cs, pm, outputChannel, isGlobal)
 		}
+		if committed {
+			return
+		}
+		// 当前视图未能提交,等待收齐 2f+1 条 Timeout 消息后轮换 Leader
+		pm.WaitViewChange()
 	}
-
 }
@@ -0,0 +1,60 @@
+package bft
+
+import (
+	"Chamael/pkg/core"
+	"Chamael/pkg/protobuf"
+	"testing"
+
+	"github.com/bits-and-blooms/bitset"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
+)
+
+// 测试 Leader 逐条验证投票: 签名无效的投票被丢弃, 其余节点的有效投票仍能组成 QC
+func TestLeaderDropsInvalidVotes(t *testing.T) {
+	suite := bn256.NewSuite()
+	ps := newMemoryParties(t, 4, 1, 1, false)
+	cs := NewChainState()
+	pm := NewPacemaker(ps[0], 1, false)
+	pm.StartTimer()
+	defer pm.Stop()
+	block := NewBlock(1, genesisHash, 0, []*protobuf.Transaction{{Data: []byte("tx-a")}}, nil)
+	cs.AddBlock(block)
+	hash := BlockHash(block)
+
+	// 节点 1 先发出对其他内容的签名, 节点 2, 3 的投票有效
+	vote := func(typ string, sender int, digest []byte) {
+		sig, _ := bls.Sign(suite, ps[sender].SK, digest)
+		var payload proto.Message = &protobuf.Prepare_Vote{Sig: sig}
+		if typ == "Precommit_Vote" {
+			payload = &protobuf.Precommit_Vote{Sig: sig}
+		}
+		ps[sender].Send(core.Encapsulation(typ, pm.ID(), ps[sender].PID, payload), 0)
+	}
+	for phase, typ := range map[uint32]string{1: "Prepare_Vote", 2: "Precommit_Vote"} {
+		vote(typ, 1, voteDigest(hash, phase, 2))
+		vote(typ, 2, voteDigest(hash, phase, 1))
+		vote(typ, 3, voteDigest(hash, phase, 1))
+	}
+
+	if !Precommit_BroadCast(ps[0], cs, pm, block, false) {
+		t.Fatalf("expected the valid Prepare_Votes to form a QC")
+	}
+	qc := cs.LockedQC()
+	if qc == nil || !verifyQC(ps[1], qc, false) {
+		t.Fatalf("expected a valid QC to be locked")
+	}
+	bm := bitset.New(4)
+	if err := bm.UnmarshalBinary(qc.Signers); err != nil || bm.Count() != 3 || bm.Test(1) {
+		t.Errorf("expected the invalid vote of party 1 to be left out of the QC")
+	}
+
+	output := make(chan []*protobuf.Transaction, 1)
+	if !Commit_BroadCast(ps[0], cs, pm, block, output, false) {
+		t.Fatalf("expected the valid Precommit_Votes to form a commit QC")
+	}
+	if batch := <-output; len(batch) != 1 || cs.Tip() != block {
+		t.Errorf("expected the block to be committed")
+	}
+}
@@ -8,29 +8,43 @@ import (
 	"Chamael/pkg/txs"
 	"Chamael/pkg/utils"
 	"bytes"
+	"crypto/sha256"
 	"fmt"
+	"log"
+	"math/big"
 	"time"
 
+	"github.com/bits-and-blooms/bitset"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
 )
 
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"TXs_Inform":      func() proto.Message { return new(protobuf.TXs_Inform) },
+		"Sig_Inform":      func() proto.Message { return new(protobuf.Sig_Inform) },
+		"Sigmsg":          func() proto.Message { return new(protobuf.Sigmsg) },
+		"InputBFT_Result": func() proto.Message { return new(protobuf.InputBFT_Result) },
+	})
+}
+
+// AccSetup 交易累加器的RSA参数, 由节点配置中的参数文件设置
+var AccSetup = crypto.TrustedSetup()
+
 // 按输入分片分类交易
-func CategorizeTransactionsByInputShard(transactions []string) map[int][]string {
-	inputShardCategories := make(map[int][]string)
+func CategorizeTransactionsByInputShard(transactions []*protobuf.Transaction) map[int][]*protobuf.Transaction {
+	inputShardCategories := make(map[int][]*protobuf.Transaction)
 
 	for _, tx := range transactions {
-		// 提取交易详情
-		details, err := txs.ExtractTransactionDetails(tx)
-		if err != nil {
+		if err := txs.Verify(tx); err != nil {
 			fmt.Printf("Skipping invalid transaction: %v\n ", err)
-			fmt.Println(tx)
 			continue
 		}
 
 		// 将交易分配到每个输入分片对应的类别
-		for _, shard := range details.InputShard {
+		for _, shard := range txs.InputShards(tx) {
 			inputShardCategories[shard] = append(inputShardCategories[shard], tx)
 		}
 	}
@@ -39,31 +53,19 @@ func CategorizeTransactionsByInputShard(transactions []string) map[int][]string
 }
 
 // 按输出分片分类交易
-func CategorizeTransactionsByOutputShard(transactions []string) (map[int][]string, []string) {
-	crossShardTransactions := make(map[int][]string) // 按输出分片存储跨片交易
-	innerShardTransactions := []string{}             // 存储片内交易
+func CategorizeTransactionsByOutputShard(transactions []*protobuf.Transaction) (map[int][]*protobuf.Transaction, []*protobuf.Transaction) {
+	crossShardTransactions := make(map[int][]*protobuf.Transaction) // 按输出分片存储跨片交易
+	innerShardTransactions := []*protobuf.Transaction{}             // 存储片内交易
 
 	for _, tx := range transactions {
-		// 提取交易详情
-		details, err := txs.ExtractTransactionDetails(tx)
-		if err != nil {
+		if err := txs.Verify(tx); err != nil {
 			fmt.Printf("Skipping invalid transaction: %v\n ", err)
-			fmt.Println(tx)
 			continue
 		}
 
-		// 判断是否是跨片交易
-		isCrossShard := false
-		for _, shard := range details.InputShard {
-			if shard != details.OutputShard {
-				isCrossShard = true
-				break
-			}
-		}
-
-		if isCrossShard {
+		if !txs.IsInternal(tx) {
 			// 按输出分片分类
-			crossShardTransactions[details.OutputShard] = append(crossShardTransactions[details.OutputShard], tx)
+			crossShardTransactions[txs.OutputShard(tx)] = append(crossShardTransactions[txs.OutputShard(tx)], tx)
 		} else {
 			// 片内交易
 			innerShardTransactions = append(innerShardTransactions, tx)
@@ -73,13 +75,144 @@ func CategorizeTransactionsByOutputShard(transactions []string) (map[int][]strin
 	return crossShardTransactions, innerShardTransactions
 }
 
-func TXs_Inform_Handler(p *party.HonestParty, e uint32, TXsInformChannel chan []string) {
+// executeTxs 按共识顺序在本分片状态上执行交易. proven 是收齐了各输入分片结果的跨片交易,
+// 本分片只为其中的交易入账输出, 区块中其他要求本分片入账的跨片交易被跳过.
+// 返回执行成功的交易, 本分片作为输入分片因输入无效而拒绝的跨片交易, 以及 proven 中尚未入账的交易
+func executeTxs(state *txs.State, batch []*protobuf.Transaction, proven []*protobuf.Transaction) ([]*protobuf.Transaction, []*protobuf.Transaction, []*protobuf.Transaction) {
+	pending := make(map[string]bool)
+	for _, tx := range proven {
+		pending[string(tx.Id)] = true
+	}
+	var executed, rejected []*protobuf.Transaction
+	for _, tx := range batch {
+		err := txs.Verify(tx)
+		if err == nil {
+			if pending[string(tx.Id)] {
+				err = state.ApplyOutputs(tx)
+				delete(pending, string(tx.Id))
+			} else {
+				err = state.Execute(tx)
+			}
+		}
+		if err != nil {
+			if txs.Rejected(err) && !txs.IsInternal(tx) && contains(txs.InputShards(tx), state.Shard()) {
+				rejected = append(rejected, tx)
+			}
+			fmt.Printf("Skipping transaction %x: %v\n", tx.Id, err)
+			continue
+		}
+		executed = append(executed, tx)
+	}
+	var remaining []*protobuf.Transaction
+	for _, tx := range proven {
+		if pending[string(tx.Id)] {
+			remaining = append(remaining, tx)
+		}
+	}
+	return executed, rejected, remaining
+}
+
+// resultCounts 各输出分片的跨片交易数
+func resultCounts(byShard map[int][]*protobuf.Transaction, M int) []uint32 {
+	counts := make([]uint32, M)
+	for i := range counts {
+		counts[i] = uint32(len(byShard[i]))
+	}
+	return counts
+}
+
+// countsLeaf 跨片结果默克尔树的第一个叶子: 各输出分片的交易数和拒绝的交易数.
+// 接收方据此确定自己的交易在树中的位置, 协调者少发交易会改变树根
+func countsLeaf(counts []uint32, rejected int) string {
+	leaf := []byte("Chamael-Counts")
+	for _, c := range counts {
+		leaf = append(leaf, utils.Uint32ToBytes(c)...)
+	}
+	return string(append(leaf, utils.Uint32ToBytes(uint32(rejected))...))
+}
+
+// resultLeaves 跨片结果的交易级默克尔树叶子: 交易数叶子, 按输出分片依次排列的交易, 本分片拒绝的交易
+func resultLeaves(counts []uint32, byShard map[int][]*protobuf.Transaction, rejected []*protobuf.Transaction) []string {
+	leaves := []string{countsLeaf(counts, len(rejected))}
+	for i := range counts {
+		leaves = append(leaves, txs.Encode(byShard[i])...)
+	}
+	return append(leaves, txs.Encode(rejected)...)
+}
+
+// resultIndices 输出分片 shard 需要验证的叶子位置: 交易数叶子, 该分片的交易, 所有拒绝的交易
+func resultIndices(counts []uint32, shard int, rejected int) []int {
+	indices := []int{0}
+	offset := 1
+	for i, c := range counts {
+		if i == shard {
+			for j := 0; j < int(c); j++ {
+				indices = append(indices, offset+j)
+			}
+		}
+		offset += int(c)
+	}
+	for j := 0; j < rejected; j++ {
+		indices = append(indices, offset+j)
+	}
+	return indices
+}
+
+// verifyResultProof 验证 InputBFT_Result 中发给本分片的交易和拒绝的交易是 root 下的全部对应叶子
+func verifyResultProof(p *party.HonestParty, payload *protobuf.InputBFT_Result) bool {
+	if len(payload.Counts) != int(p.M) || int(payload.Counts[p.Snumber]) != len(payload.Txs) {
+		return false
+	}
+	leaves := 1 + len(payload.Rejected)
+	for _, c := range payload.Counts {
+		leaves += int(c)
+	}
+	proven := append([]string{countsLeaf(payload.Counts, len(payload.Rejected))}, txs.Encode(payload.Txs)...)
+	proven = append(proven, txs.Encode(payload.Rejected)...)
+	return crypto.VerifyMultiProof(payload.Root, &crypto.MerkleMultiProof{
+		Leaves:  leaves,
+		Indices: resultIndices(payload.Counts, int(p.Snumber), len(payload.Rejected)),
+		Hashes:  payload.Proof,
+	}, proven)
+}
+
+// accDigest 累加器更新 from^exp = to 的摘要, 每个值带长度前缀, 随 root 一起签名
+func accDigest(from, to, exp []byte) []byte {
+	h := sha256.New()
+	for _, v := range [][]byte{from, to, exp} {
+		h.Write(utils.Uint32ToBytes(uint32(len(v))))
+		h.Write(v)
+	}
+	return h.Sum(nil)
+}
+
+// verifyAccUpdate 用 PoE 验证 InputBFT_Result 中源分片的累加器从 acc_from 并入代表数乘积为 acc_exp 的交易后为 acc,
+// acc_from 必须是上一次接受的该分片的累加器值 last, 保证各 epoch 的更新首尾相连
+func verifyAccUpdate(payload *protobuf.InputBFT_Result, last []byte) bool {
+	if !bytes.Equal(payload.AccFrom, last) {
+		return false
+	}
+	from := new(big.Int).SetBytes(payload.AccFrom)
+	to := new(big.Int).SetBytes(payload.Acc)
+	if to.Cmp(AccSetup.N) >= 0 {
+		return false
+	}
+	exp := new(big.Int).SetBytes(payload.AccExp)
+	return crypto.VerifyPoE(AccSetup, from, exp, to, &crypto.PoE{Q: new(big.Int).SetBytes(payload.AccProof)})
+}
+
+func TXs_Inform_Handler(p *party.HonestParty, e uint32, TXsInformChannel chan []*protobuf.Transaction) {
 	var l []int
-	var Result []string
+	var Result []*protobuf.Transaction
 	seen := make(map[int]bool)
 	for {
 		m := <-p.GetMessage("TXs_Inform", utils.Uint32ToBytes(e))
-		payload := (core.Decapsulation("TXs_Inform", m)).(*protobuf.TXs_Inform)
+		raw, err := core.Decapsulation("TXs_Inform", m)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.TXs_Inform)
 		if !seen[int(m.Sender)] {
 			l = append(l, int(m.Sender))
 			seen[int(m.Sender)] = true
@@ -93,67 +226,233 @@ func TXs_Inform_Handler(p *party.HonestParty, e uint32, TXsInformChannel chan []
 	}
 }
 
-func InpufBFT_Result_Handler(p *party.HonestParty, e uint32, InputResultTobeDoneChannel chan []string, txPool *TransactionPool) {
+// resultDigest 分片 shard 在 epoch e 的 InputBFT_Result 被签名的内容, 绑定 epoch 和分片, 防止签名被重放到其他 epoch 或分片,
+// acc 为 accDigest 给出的累加器更新的摘要
+func resultDigest(e uint32, shard uint32, root []byte, acc []byte) []byte {
+	return utils.MessageEncap([][]byte{utils.Uint32ToBytes(e), utils.Uint32ToBytes(shard), root, acc})
+}
+
+// collectRootSignatures 协调者收集片内其他节点对 digest 的签名: 只接受本分片节点用自己登记的公钥签出的签名,
+// 无效的消息丢弃后继续等待. 自己的签名 sig 加上 2f 个其他节点的签名构成 2f+1 的法定人数, 返回记录签名者 SID 的位图和聚合签名
+func collectRootSignatures(p *party.HonestParty, e uint32, digest []byte, sig []byte) ([]byte, []byte, error) {
+	suite := bn256.NewSuite()
+	signers_bm := bitset.New(uint(p.N))
+	signers_bm.Set(uint(p.SID))
+	signatures := [][]byte{sig}
+	pubkeys := []kyber.Point{p.PK[p.PID]}
+	seen := map[uint32]bool{p.PID: true}
+	for len(signatures) < int(2*p.F+1) {
+		m := <-p.GetMessage("Sigmsg", utils.Uint32ToBytes(e))
+		raw, err := core.Decapsulation("Sigmsg", m)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.Sigmsg)
+
+		if m.Sender/p.N != p.Snumber || seen[m.Sender] {
+			fmt.Println("Ignoring Sigmsg from", m.Sender)
+			continue
+		}
+		if err := bls.Verify(suite, p.PK[m.Sender], digest, payload.Sig); err != nil {
+			fmt.Println("Invalid Mktree Root signature from", m.Sender, err)
+			continue
+		}
+		seen[m.Sender] = true
+		signatures = append(signatures, payload.Sig)
+		pubkeys = append(pubkeys, p.PK[m.Sender])
+		signers_bm.Set(uint(m.Sender % p.N))
+	}
+	aggSig, err := bls.AggregateSignatures(suite, signatures...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := bls.Verify(suite, bls.AggregatePublicKeys(suite, pubkeys...), digest, aggSig); err != nil {
+		return nil, nil, err
+	}
+	signers, err := signers_bm.MarshalBinary()
+	return signers, aggSig, err
+}
+
+// verifyShardSignature 用位图 signers 选出分片 shard 登记在 p.PK 中的公钥, 聚合后验证 aggsig 是对 resultDigest(e, shard, root, acc) 的签名,
+// 签名者少于 2f+1 时返回错误
+func verifyShardSignature(p *party.HonestParty, e uint32, shard int, signers []byte, root []byte, acc []byte, aggsig []byte) error {
 	suite := bn256.NewSuite()
+	var signers_bm bitset.BitSet
+	if err := signers_bm.UnmarshalBinary(signers); err != nil {
+		return err
+	}
+	var pubkeys []kyber.Point
+	for i, e := signers_bm.NextSet(0); e; i, e = signers_bm.NextSet(i + 1) {
+		if i >= uint(p.N) {
+			return fmt.Errorf("signer %d is not a member of shard %d", i, shard)
+		}
+		pubkeys = append(pubkeys, p.PK[shard*int(p.N)+int(i)])
+	}
+	if len(pubkeys) < int(2*p.F+1) {
+		return fmt.Errorf("%d signers from shard %d, need %d", len(pubkeys), shard, 2*p.F+1)
+	}
+	return bls.Verify(suite, bls.AggregatePublicKeys(suite, pubkeys...), resultDigest(e, uint32(shard), root, acc), aggsig)
+}
+
+// lastAcc 上一次接受的分片 shard 的累加器值, 尚未收到该分片的结果时为初始值 g
+func lastAcc(accs map[int][]byte, shard int) []byte {
+	if acc, ok := accs[shard]; ok {
+		return acc
+	}
+	return AccSetup.G.Bytes()
+}
+
+// accElements 本 epoch 要并入累加器的元素: 按提交顺序去掉批内重复和此前已累加的交易编码
+func accElements(acc *crypto.Accumulator, batch []*protobuf.Transaction) []string {
+	var elements []string
+	seen := make(map[string]bool)
+	for _, v := range txs.Encode(batch) {
+		if seen[v] || acc.Contains(v) {
+			continue
+		}
+		seen[v] = true
+		elements = append(elements, v)
+	}
+	return elements
+}
+
+// InpufBFT_Result_Handler 收集各分片 epoch e 的 InputBFT_Result, accs 为每个分片上一次接受的累加器值, 接受结果后更新
+func InpufBFT_Result_Handler(p *party.HonestParty, e uint32, InputResultTobeDoneChannel chan []*protobuf.Transaction, txPool *TransactionPool, state *txs.State, accs map[int][]byte) {
 	var l []int
 	seen := make(map[int]bool)
 	for {
 		m := <-p.GetMessage("InputBFT_Result", utils.Uint32ToBytes(e))
-		payload := (core.Decapsulation("InputBFT_Result", m)).(*protobuf.InputBFT_Result)
-		AggPK := utils.BytesToPoint(payload.Aggpk)
-		err := bls.Verify(suite, AggPK, payload.Root, payload.Aggsig)
+		raw, err := core.Decapsulation("InputBFT_Result", m)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.InputBFT_Result)
+		shard := int((m.Sender - m.Sender%p.N) / p.N)
+		// 聚合公钥由签名位图从源分片登记的公钥重建, 不信任消息自带的公钥; 验证失败只丢弃这条消息
+		err = verifyShardSignature(p, e, shard, payload.Signers, payload.Root, accDigest(payload.AccFrom, payload.Acc, payload.AccExp), payload.Aggsig)
 		if err != nil {
 			fmt.Println("AggSig(root) verification failed:", err)
-			return
+			continue
 		}
 
-		result := crypto.VerifyMerkleTreeProof(payload.Root, payload.Path, payload.Indicator, payload.Txs)
-		if result == false {
+		if !verifyResultProof(p, payload) {
 			fmt.Println("MerkleTree verification failed")
-			return
+			continue
 		}
 
-		if !seen[int(m.Sender)] {
-			l = append(l, int(m.Sender))
-			seen[int(m.Sender)] = true
+		if !verifyAccUpdate(payload, lastAcc(accs, shard)) {
+			fmt.Println("Accumulator update verification failed")
+			continue
+		}
+
+		// 每个分片只接受一个结果
+		if !seen[shard] {
+			l = append(l, shard)
+			seen[shard] = true
+			accs[shard] = payload.Acc
 
 			for _, tx := range payload.Txs {
-				err := txPool.AddTransaction(tx, int((m.Sender-m.Sender%p.N)/p.N))
+				err := txPool.AddTransaction(tx, shard, e)
 				if err != nil {
 					fmt.Println("Failed to add transaction to pool:", err)
 				}
 			}
+			// 输入分片拒绝的交易, 或输出分片因超时中止的交易: 输出分片将其移出交易池, 输入分片退还锁定的输入
+			for _, tx := range payload.Rejected {
+				if !contains(txs.InputShards(tx), shard) && txs.OutputShard(tx) != shard {
+					fmt.Println("Ignoring rejection from shard", shard, "which is not involved in the transaction")
+					continue
+				}
+				txPool.AbortTransaction(tx, e)
+				state.Abort(tx)
+			}
 		}
-		if len(l) >= int(p.M) { // 也会收到自己的 所以应该是 m 而非 m-1
+		if len(l) >= int(p.M) { // 也会收到自己分片的 所以应该是 m 而非 m-1
 			break
 		}
 	}
 
 	completedTransactions := txPool.CheckAndRemoveTransactions()
+	// 中止超时仍未收齐输入分片结果的交易
+	txPool.Expire(e)
 	// 将完成的交易发送到 InputResultTobeDoneChannel
 	InputResultTobeDoneChannel <- completedTransactions
 
 	return
 }
 
-func KronosProcess(p *party.HonestParty, epoch int, itx_inputChannel chan []string, ctx_inputChannel chan []string, outputChannel chan []string, timeChannel chan time.Time, block_delay_channel chan time.Duration, round_delay_channel chan time.Duration, extra_delay_channel chan time.Duration, WaitTime int) {
+// epochMessageTypes 是 ID 以 epoch 开头的消息类型, 每个 epoch 结束后回收更早 epoch 的消息
+var epochMessageTypes = []string{
+	"TXs_Inform", "InputBFT_Result", "Sig_Inform", "Sigmsg",
+	"Prepare", "Prepare_Vote", "Precommit", "Precommit_Vote", "Commit", "New_View", "Timeout",
+	"Generic", "Generic_Vote", "Block_Response",
+}
+
+// KronosProcess 运行 epoch 个 epoch 的 Kronos. p.Ledger 不为 nil 时, 每个 epoch 结束后把本 epoch 提交的区块和检查点写入账本,
+// 启动时若账本中已有检查点则从中恢复并从下一个 epoch 继续
+func KronosProcess(p *party.HonestParty, epoch int, itx_inputChannel chan []*protobuf.Transaction, ctx_inputChannel chan []*protobuf.Transaction, outputChannel chan []*protobuf.Transaction, timeChannel chan time.Time, block_delay_channel chan time.Duration, round_delay_channel chan time.Duration, extra_delay_channel chan time.Duration, pool_stats_channel chan txs.PoolStats, WaitTime int) {
 	txPool := NewTransactionPool()
-	var TXsInformChannel = make(chan []string, 4096)
-	var InputResultTobeDoneChannel = make(chan []string, 4096)
+	var TXsInformChannel = make(chan []*protobuf.Transaction, 4096)
+	var InputResultTobeDoneChannel = make(chan []*protobuf.Transaction, 4096)
 	suite := bn256.NewSuite()
-	acc_setup := crypto.TrustedSetup()
+	cs := NewChainState() // 片内共识的区块链状态, 跨 epoch 保存
+	state := txs.NewState(int(p.Snumber))
+	start := uint32(1)
+	// 收齐各分片上一个 epoch 的结果后完成的跨片交易, 在本 epoch 提议
+	var txs_pool_finished []*protobuf.Transaction
+	// 收齐各输入分片结果、尚未在区块中入账的跨片交易, 只有这些交易可以在本分片为输出入账
+	var txs_proven []*protobuf.Transaction
+	// 每个分片上一次被接受的累加器值, 下一个 epoch 的更新必须从这个值开始
+	shard_accs := make(map[int][]byte)
+	if p.Ledger != nil {
+		cp, err := LoadCheckpoint(p.Ledger)
+		if err != nil {
+			fmt.Println("Failed to load checkpoint:", err)
+			return
+		}
+		if cp != nil {
+			cs, txPool, p.Acc = cp.Chain, cp.Pool, cp.Acc
+			if cp.State != nil {
+				state = cp.State
+			}
+			txs_pool_finished, txs_proven = cp.Finished, cp.Finished
+			if cp.ShardAccs != nil {
+				shard_accs = cp.ShardAccs
+			}
+			start = cp.Epoch + 1
+			fmt.Println("Recovered from checkpoint, epoch", cp.Epoch, "height", cs.Tip().Height, p.PID)
+		}
+	}
+	// 同一个累加器跨 epoch 保存全部元素, 可以为任何已提交的交易生成 membership witness
+	if p.Acc == nil {
+		p.Acc = crypto.NewAccumulator(nil, crypto.HashToPrimeFromSha256, AccSetup)
+	}
+	// 超时的交易退还本分片锁定的输入, 并随本 epoch 的 InputBFT_Result 作为拒绝发送给各分片
+	var txs_expired []*protobuf.Transaction
+	txPool.OnExpire(func(tx *protobuf.Transaction) {
+		state.Abort(tx)
+		txs_expired = append(txs_expired, tx)
+	})
+	// 为落后的片内节点提供区块同步
+	go SyncServer(p, cs)
+	var chs *ChainedHotStuff
+	if UseChainedHotStuff {
+		chs = NewChainedHotStuff(p, cs, false)
+	}
 	timeChannel <- time.Now()
-	for e := uint32(1); e <= uint32(epoch); e++ {
-		var txs_in []string            //放入片内共识的交易整体
-		var txs_ctx_in []string        //别的分片发来的,本分片为输入分片的交易;是放入片内共识交易的跨片部分
-		var txs_itx []string           //从inputchannel来,本分片的片内交易;是放入片内共识交易的片内部分
-		var txs_pool_finished []string //从缓冲池来,输入分片已经处理完的,本分片作为输出分片的交易;是放入片内共识交易的片内部分
+	for e := start; e <= uint32(epoch); e++ {
+		var txs_in []*protobuf.Transaction     //放入片内共识的交易整体
+		var txs_ctx_in []*protobuf.Transaction //别的分片发来的,本分片为输入分片的交易;是放入片内共识交易的跨片部分
+		var txs_itx []*protobuf.Transaction    //从inputchannel来,本分片的片内交易;是放入片内共识交易的片内部分
 
-		var txs_ctx map[int][]string //从inputchannel来,按输入分片分类后的跨片交易;是TXs_Inform的内容
+		var txs_ctx map[int][]*protobuf.Transaction //从inputchannel来,按输入分片分类后的跨片交易;是TXs_Inform的内容
 
-		var txs_out []string          //从片内共识里拿取的交易整体
-		var txs_ctx2 map[int][]string //从片内共识来,按输出分片分类后的跨片交易
-		var txs_itx2 []string         //从片内共识来,进行分类后的片内交易
+		var txs_out []*protobuf.Transaction          //从片内共识里拿取的交易整体
+		var txs_ctx2 map[int][]*protobuf.Transaction //从片内共识来,按输出分片分类后的跨片交易
+		var txs_itx2 []*protobuf.Transaction         //从片内共识来,进行分类后的片内交易
+		var txs_rejected []*protobuf.Transaction     //从片内共识来,本分片作为输入分片拒绝的跨片交易
 
 		var is_coordinator bool
 
@@ -164,12 +463,11 @@ func KronosProcess(p *party.HonestParty, epoch int, itx_inputChannel chan []stri
 		}
 
 		epoch_start_time := time.Now()
+		// 清理早于交易池超时窗口的已完成和已中止记录, 释放不会再被中止的锁定, 避免状态和检查点无限增长
+		state.Prune(e, PendingTimeout)
 
-		if e > 1 {
-			InpufBFT_Result_Handler(p, e-1, InputResultTobeDoneChannel, txPool)
-			txs_pool_finished = <-InputResultTobeDoneChannel
-			txs_in = append(txs_in, txs_pool_finished...)
-		}
+		// 从缓冲池来,输入分片已经处理完的,本分片作为输出分片的交易;是放入片内共识交易的片内部分
+		txs_in = append(txs_in, txs_pool_finished...)
 
 		//获取新跨片交易,把跨片交易按输入分片分类后发给对应分片
 		TXsInformSender_start_time := time.Now()
@@ -192,12 +490,21 @@ func KronosProcess(p *party.HonestParty, epoch int, itx_inputChannel chan []stri
 		txs_ctx_in = <-TXsInformChannel
 		txs_in = append(txs_in, txs_ctx_in...)
 
-		inputChannel := make(chan []string, 4096)
-		receiveChannel := make(chan []string, 4096)
+		inputChannel := make(chan []*protobuf.Transaction, 4096)
+		receiveChannel := make(chan []*protobuf.Transaction, 4096)
 		inputChannel <- txs_in
 
-		HotStuffProcess(p, int(e), inputChannel, receiveChannel, false)
-		txs_out = <-receiveChannel
+		if UseChainedHotStuff {
+			// 链式模式下 txs_out 是本 epoch 按三链规则提交的区块中的交易
+			ChainedHotStuffProcess(chs, int(e), inputChannel, receiveChannel)
+		} else {
+			HotStuffProcess(p, cs, int(e), inputChannel, receiveChannel, false)
+		}
+		// 执行提交的交易: 片内交易直接生效, 跨片交易在输入分片锁定输入、在输出分片入账,
+		// 双花等执行失败的交易不会被输出, 其中本分片拒绝的跨片交易作为拒绝结果发送给各分片
+		txs_out, txs_rejected, txs_proven = executeTxs(state, <-receiveChannel, txs_proven)
+		txs_rejected = append(txs_rejected, txs_expired...)
+		txs_expired = nil
 		txs_ctx2, txs_itx2 = CategorizeTransactionsByOutputShard(txs_out)
 
 		//对于片内交易和输出分片为自己的交易,直接输出,作为吞吐量计算
@@ -209,22 +516,31 @@ func KronosProcess(p *party.HonestParty, epoch int, itx_inputChannel chan []stri
 		// 更新累加器
 		// 合并 txs_ctx2[int(p.Snumber)] 和 txs_itx2
 		txs_ctx2[int(p.Snumber)] = append(txs_ctx2[int(p.Snumber)], txs_itx2...)
-		// 调用 FastAcc 快速计算累加器
-		acc := crypto.FastAcc(txs_ctx2[int(p.Snumber)], crypto.HashToPrimeFromSha256, acc_setup)
-		p.Acc = acc
+		// 把本 epoch 提交的每笔交易并入上一个 epoch 的累加器, 累加器的值覆盖此前所有 epoch
+		// 并附上更新的 PoE, 随 InputBFT_Result 发给各分片验证
+		accAdded := accElements(p.Acc, txs_ctx2[int(p.Snumber)])
+		accFrom := p.Acc.Value().Bytes()
+		accProof, accExp, err := p.Acc.AddAndProve(accAdded)
+		if err != nil {
+			// 去重后不应失败; 跳过本 epoch 的交易会让累加器与其他节点不一致, 只能停止
+			log.Fatalf("epoch %d: failed to update the accumulator: %v", e, err)
+		}
+		accTo := p.Acc.Value().Bytes()
 
 		// 清空 txs_ctx2[int(p.Snumber)]
 		txs_ctx2[int(p.Snumber)] = nil
 
-		//对于跨片交易,建立默克尔树,并对树根签名
-		mktree, _ := crypto.NewMerkleTree(utils.MapToSlice(txs_ctx2, int(p.M)))
-		Root := mktree.GetMerkleTreeRoot()
-		sigRoot, _ := bls.Sign(suite, p.SK, Root)
+		//对于跨片交易和本分片拒绝的交易,建立交易级默克尔树,并对树根签名
+		counts := resultCounts(txs_ctx2, int(p.M))
+		mktree := crypto.NewTxMerkleTree(resultLeaves(counts, txs_ctx2, txs_rejected))
+		Root := mktree.Root()
+		digest := resultDigest(e, p.Snumber, Root, accDigest(accFrom, accTo, accExp.Bytes()))
+		sigRoot, _ := bls.Sign(suite, p.SK, digest)
 
 		/*
 			如果自己是跨片协调者:
 				1:片内广播Sig_Inform表明身份
-				2:监听收集片内其他节点对于树根的签名,收齐后聚合
+				2:监听收集片内其他节点对于树根的签名,收齐 2f 个后聚合
 				3:分别向各个分片广播InputBFT_Result
 			如果自己不是跨片交易协调者:
 				监听Sig_Inform消息,收到后发送Sigmsg给协调者
@@ -235,51 +551,33 @@ func KronosProcess(p *party.HonestParty, epoch int, itx_inputChannel chan []stri
 			})
 			p.Intra_Broadcast(SigInformMessage)
 
-			var l []int
-			seen := make(map[int]bool)
-			var signatures [][]byte
-			var pubkeys []kyber.Point
-			for {
-				m := <-p.GetMessage("Sigmsg", utils.Uint32ToBytes(e))
-				payload := (core.Decapsulation("Sigmsg", m)).(*protobuf.Sigmsg)
-
-				if !bytes.Equal(payload.Root, Root) {
-					fmt.Println("Invalid Mktree Root(Unequal Root)")
-					return
-				}
-				if !seen[int(m.Sender)] {
-					l = append(l, int(m.Sender))
-					seen[int(m.Sender)] = true
-					signatures = append(signatures, payload.Sig)
-					pubkeys = append(pubkeys, p.PK[m.Sender])
-				}
-
-				if len(l) >= int(p.N)-1 {
-					break
-				}
-
-				signatures = append(signatures, sigRoot)
-				pubkeys = append(pubkeys, p.PK[p.PID])
-			}
-			aggSig, _ := bls.AggregateSignatures(suite, signatures...)
-			aggPubKey := bls.AggregatePublicKeys(suite, pubkeys...)
-			err := bls.Verify(suite, aggPubKey, Root, aggSig)
+			signers, aggSig, err := collectRootSignatures(p, e, digest, sigRoot)
 			if err != nil {
+				// 每个签名都已单独验证, 聚合失败只可能是本节点自己的签名有误, 此时不发送结果但继续本 epoch 的流程
 				fmt.Println("Invalid Mktree Root(Invalid aggSig)", err)
-				return
-			}
-
-			for i := uint32(0); i < p.M; i++ {
-				path, indicator := mktree.GetMerkleTreeProof(int(i))
-				TXsInformMesssage := core.Encapsulation("InputBFT_Result", utils.Uint32ToBytes(e), p.PID, &protobuf.InputBFT_Result{
-					Txs:       txs_ctx2[int(i)],
-					Root:      Root,
-					Path:      path,
-					Indicator: indicator,
-					Aggsig:    aggSig,
-					Aggpk:     utils.PointToBytes(aggPubKey),
-				})
-				p.Shard_Broadcast(TXsInformMesssage, i)
+			} else {
+				for i := uint32(0); i < p.M; i++ {
+					// 每个输出分片只收到自己的交易和拒绝的交易, 以及它们的多重证明
+					proof, err := mktree.Prove(resultIndices(counts, int(i), len(txs_rejected)))
+					if err != nil {
+						fmt.Println("Failed to prove the result for shard", i, err)
+						continue
+					}
+					TXsInformMesssage := core.Encapsulation("InputBFT_Result", utils.Uint32ToBytes(e), p.PID, &protobuf.InputBFT_Result{
+						Txs:      txs_ctx2[int(i)],
+						Root:     Root,
+						Aggsig:   aggSig,
+						Rejected: txs_rejected,
+						Signers:  signers,
+						Counts:   counts,
+						Proof:    proof.Hashes,
+						AccFrom:  accFrom,
+						Acc:      accTo,
+						AccExp:   accExp.Bytes(),
+						AccProof: accProof.Q.Bytes(),
+					})
+					p.Shard_Broadcast(TXsInformMesssage, i)
+				}
 			}
 
 		} else {
@@ -291,9 +589,50 @@ func KronosProcess(p *party.HonestParty, epoch int, itx_inputChannel chan []stri
 			p.Send(SigMessage, m.Sender)
 		}
 
+		// 收齐各分片本 epoch 的结果后再保存检查点, 完成的交易随检查点保存, 重启后不必等待重启前发出的结果
+		InpufBFT_Result_Handler(p, e, InputResultTobeDoneChannel, txPool, state, shard_accs)
+		txs_pool_finished = <-InputResultTobeDoneChannel
+		txs_proven = append(txs_proven, txs_pool_finished...)
+
+		if p.Ledger != nil {
+			if err := SaveCheckpoint(p.Ledger, e, cs, txPool, state, p.Acc, accAdded, shard_accs, txs_proven); err != nil {
+				fmt.Println("Failed to save checkpoint:", err)
+			}
+		}
+
+		p.CollectEpochs(epochMessageTypes, e)
+
+		stats := txPool.Stats(e)
+		stats.StatePruned = state.Pruned()
+		pool_stats_channel <- stats
 		round_delay_channel <- time.Since(epoch_start_time)
 		timeChannel <- time.Now()
 	}
+	if UseChainedHotStuff {
+		// 最后三个区块需要后续的空区块推动才能满足三链规则被提交
+		for e := uint32(epoch) + 1; e <= uint32(epoch)+3; e++ {
+			if e < start {
+				continue
+			}
+			inputChannel := make(chan []*protobuf.Transaction, 4096)
+			receiveChannel := make(chan []*protobuf.Transaction, 4096)
+			inputChannel <- []*protobuf.Transaction{}
+			ChainedHotStuffProcess(chs, int(e), inputChannel, receiveChannel)
+			// 收尾阶段不再发送 InputBFT_Result, 本分片拒绝的交易不会通知其他分片
+			var txs_out []*protobuf.Transaction
+			txs_out, _, txs_proven = executeTxs(state, <-receiveChannel, txs_proven)
+			txs_ctx2, txs_itx2 := CategorizeTransactionsByOutputShard(txs_out)
+			outputChannel <- txs_itx2
+			outputChannel <- txs_ctx2[int(p.Snumber)]
+			if p.Ledger != nil {
+				if err := SaveCheckpoint(p.Ledger, e, cs, txPool, state, p.Acc, nil, shard_accs, txs_proven); err != nil {
+					fmt.Println("Failed to save checkpoint:", err)
+				}
+			}
+			p.CollectEpochs(epochMessageTypes, e)
+		}
+		timeChannel <- time.Now()
+	}
 	// time.Sleep(time.Second * 15)
 	time.Sleep(time.Second * (time.Duration(WaitTime / 10)))
 }
@@ -0,0 +1,502 @@
+package bft
+
+import (
+	"Chamael/internal/party"
+	"Chamael/pkg/core"
+	"Chamael/pkg/crypto"
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/txs"
+	"Chamael/pkg/utils"
+	"Chamael/pkg/utils/db"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bits-and-blooms/bitset"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
+)
+
+// newMemoryParties 创建 N*M 个通过内存网络相连的节点, signed 时启用消息签名
+func newMemoryParties(t *testing.T, N, F, M int, signed bool) []*party.HonestParty {
+	suite := pairing.NewSuiteBn256()
+	var pks, sks []string
+	for i := 0; i < N*M; i++ {
+		sk, pk := bls.NewKeyPair(suite, suite.RandomStream())
+		skBytes, _ := sk.MarshalBinary()
+		pkBytes, _ := pk.MarshalBinary()
+		sks = append(sks, base64.StdEncoding.EncodeToString(skBytes))
+		pks = append(pks, base64.StdEncoding.EncodeToString(pkBytes))
+	}
+
+	net := core.NewMemoryNetwork(N * M)
+	var ps []*party.HonestParty
+	for i := 0; i < N*M; i++ {
+		p := party.NewHonestParty(uint32(N), uint32(F), uint32(M), uint32(i), uint32(i/N), uint32(i%N), nil, nil, pks, sks[i], false)
+		p.SetTransport(net.Transport(uint32(i)))
+		if signed {
+			p.EnableSignatures()
+		}
+		if err := p.InitReceiveChannel(); err != nil {
+			t.Fatalf("failed to init receive channel: %v", err)
+		}
+		ps = append(ps, p)
+	}
+	for _, p := range ps {
+		if err := p.InitSendChannel(); err != nil {
+			t.Fatalf("failed to init send channel: %v", err)
+		}
+	}
+	return ps
+}
+
+// kronosInputs 为节点 p 生成 epochs 个 epoch 的片内交易和跨片交易, rrate 为跨片交易中有效输入的百分比,
+// extra 加入第一个 epoch 的跨片交易
+func kronosInputs(p *party.HonestParty, epochs, rrate int, extra []*protobuf.Transaction) (chan []*protobuf.Transaction, chan []*protobuf.Transaction) {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	itx := make(chan []*protobuf.Transaction, epochs)
+	ctx := make(chan []*protobuf.Transaction, epochs)
+	for e := 0; e < epochs; e++ {
+		itx <- []*protobuf.Transaction{txs.InterTxGenerator(32, int(p.Snumber), int(p.PID), chars)}
+		batch := []*protobuf.Transaction{txs.CrossTxGenerator(32, int(p.M), rrate, int(p.PID), chars)}
+		if e == 0 {
+			batch = append(batch, extra...)
+		}
+		ctx <- batch
+	}
+	return itx, ctx
+}
+
+// attachLedgers 为每个节点打开独立的账本, 测试结束时关闭
+func attachLedgers(t *testing.T, ps []*party.HonestParty) {
+	for _, p := range ps {
+		store, err := db.OpenBlockStore(filepath.Join(t.TempDir(), "blocks.db"))
+		if err != nil {
+			t.Fatalf("failed to open block store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		p.Ledger = store
+	}
+}
+
+// startKronos 运行节点 p 的 Kronos 到第 epochs 个 epoch, 丢弃各项统计
+func startKronos(p *party.HonestParty, epochs int, itx, ctx, output chan []*protobuf.Transaction) {
+	KronosProcess(p, epochs, itx, ctx, output, make(chan time.Time, 4096),
+		make(chan time.Duration, 4096), make(chan time.Duration, 4096), make(chan time.Duration, 4096), make(chan txs.PoolStats, 4096), 0)
+}
+
+// runKronos 在内存网络上运行多分片 Kronos, 检查同一分片的节点输出一致, 各节点的检查点保存在各自的账本中.
+// rrate 为生成的跨片交易中有效输入的百分比, extra 由节点 0 在第一个 epoch 发出, 返回各节点每次输出的交易
+func runKronos(t *testing.T, signed bool, rrate int, extra ...*protobuf.Transaction) ([]*party.HonestParty, [][][]string) {
+	N, F, M, epochs := 4, 1, 3, 3
+	ps := newMemoryParties(t, N, F, M, signed)
+	attachLedgers(t, ps)
+
+	outputs := make([]chan []*protobuf.Transaction, N*M)
+	done := make(chan int, N*M)
+	for _, p := range ps {
+		var injected []*protobuf.Transaction
+		if p.PID == 0 {
+			injected = extra
+		}
+		itx, ctx := kronosInputs(p, epochs, rrate, injected)
+		outputs[p.PID] = make(chan []*protobuf.Transaction, 4096)
+		go func(p *party.HonestParty) {
+			startKronos(p, epochs, itx, ctx, outputs[p.PID])
+			done <- int(p.PID)
+		}(p)
+	}
+
+	for i := 0; i < N*M; i++ {
+		select {
+		case <-done:
+		case <-time.After(60 * time.Second):
+			t.Fatal("Kronos did not finish")
+		}
+	}
+
+	// 每个 epoch 输出片内交易和本分片入账的跨片交易两批, 链式模式另有三个推动提交的空区块 epoch
+	batches := 2 * epochs
+	if UseChainedHotStuff {
+		batches += 2 * 3
+	}
+	results := make([][][]string, N*M)
+	for i := range ps {
+		close(outputs[i])
+		for batch := range outputs[i] {
+			results[i] = append(results[i], txs.Encode(batch))
+		}
+		if len(results[i]) != batches {
+			t.Errorf("node %d: expected %d output batches, got %d", i, batches, len(results[i]))
+		}
+	}
+	for i := range ps {
+		leader := i - i%N
+		if !reflect.DeepEqual(results[i], results[leader]) {
+			t.Errorf("node %d output differs from node %d in the same shard", i, leader)
+		}
+	}
+	return ps, results
+}
+
+func TestKronosInMemory(t *testing.T) {
+	ps, _ := runKronos(t, false, 100)
+
+	// 账本中每个区块附带提交它的 commitQC, 同一 epoch 提交的区块共用该 epoch 结束时的累加器值
+	for _, p := range ps {
+		records := 0
+		p.Ledger.Iterate(1, func(r *db.BlockRecord) bool {
+			records++
+			hash := BlockHash(r.Block)
+			if r.QC == nil || r.QC.Phase != 2 || !bytes.Equal(r.QC.BlockHash, hash) ||
+				verifyAggregate(p, r.QC.Signers, voteDigest(hash, 2, r.QC.Epoch), r.QC.Aggsig, false) != nil {
+				t.Errorf("node %d: expected a valid commitQC for the block at height %d", p.PID, r.Height)
+			}
+			if r.Acc == nil {
+				t.Errorf("node %d: expected the accumulator value for the block at height %d", p.PID, r.Height)
+			}
+			return true
+		})
+		if records == 0 {
+			t.Errorf("node %d: expected the committed blocks in the ledger", p.PID)
+		}
+	}
+}
+
+// 片内共识使用链式 HotStuff: 同一分片输出一致, 所有 epoch 的区块都在收尾阶段结束前被提交
+func TestKronosChainedInMemory(t *testing.T) {
+	UseChainedHotStuff = true
+	defer func() { UseChainedHotStuff = false }()
+	ps, results := runKronos(t, false, 100)
+
+	for _, p := range ps {
+		cp, err := LoadCheckpoint(p.Ledger)
+		if err != nil || cp == nil || cp.Chain == nil {
+			t.Fatalf("node %d: failed to load the checkpoint: %v", p.PID, err)
+		}
+		// 收尾的 3 个空区块推动提交了 epoch 3 的区块, 空区块本身尚未满足三链规则
+		if tip := cp.Chain.Tip(); tip.Height != 3 {
+			t.Errorf("node %d: expected the block of epoch 3 to be the committed tip, got %d", p.PID, tip.Height)
+		}
+		total := 0
+		for _, batch := range results[p.PID] {
+			total += len(batch)
+		}
+		if total < 3 {
+			t.Errorf("node %d: expected the intra-shard transactions of every epoch to be output, got %d", p.PID, total)
+		}
+	}
+}
+
+// 一笔输出到分片 2 的交易在分片 0 的输入有效、在分片 1 的输入透支: 分片 1 拒绝后分片 0 退还锁定的输入,
+// 余额回到锁定前的值, 没有节点输出这笔交易; 其余跨片交易的输入一半有效, 协议不会因拒绝而停顿
+func TestKronosAbortsRejectedTransactions(t *testing.T) {
+	owner, sk, _ := ed25519.GenerateKey(nil)
+	tx := &protobuf.Transaction{
+		Inputs: []*protobuf.TxInput{
+			{Shard: 0, Object: owner, Amount: 10},
+			{Shard: 1, Object: owner, Amount: txs.GenesisBalance + 1},
+		},
+		Outputs: []*protobuf.TxOutput{{Shard: 2, Object: []byte("receiver"), Amount: 10}},
+	}
+	txs.Sign(tx, sk)
+	ps, results := runKronos(t, false, 50, tx)
+
+	encoded := txs.Encode([]*protobuf.Transaction{tx})[0]
+	for i, batches := range results {
+		for _, batch := range batches {
+			for _, out := range batch {
+				if out == encoded {
+					t.Errorf("node %d output the rejected transaction", i)
+				}
+			}
+		}
+	}
+	for _, p := range ps {
+		cp, err := LoadCheckpoint(p.Ledger)
+		if err != nil || cp == nil || cp.State == nil {
+			t.Fatalf("node %d: failed to load the checkpoint: %v", p.PID, err)
+		}
+		switch p.Snumber {
+		case 1:
+			if cp.State.Balance(owner) != txs.GenesisBalance {
+				t.Errorf("node %d: overspent account changed to %d", p.PID, cp.State.Balance(owner))
+			}
+			continue
+		case 2:
+			if cp.State.Balance([]byte("receiver")) != txs.GenesisBalance {
+				t.Errorf("node %d: receiver was credited to %d", p.PID, cp.State.Balance([]byte("receiver")))
+			}
+			continue
+		}
+		// 分片 0 在区块中执行过这笔交易, 即锁定过输入
+		committed := false
+		p.Ledger.Iterate(1, func(r *db.BlockRecord) bool {
+			for _, btx := range r.Block.Txs {
+				committed = committed || bytes.Equal(btx.Id, tx.Id)
+			}
+			return true
+		})
+		if !committed {
+			t.Errorf("node %d: expected the transaction to be committed in shard 0", p.PID)
+		}
+		if cp.State.Locked(tx.Id) || cp.State.Balance(owner) != txs.GenesisBalance {
+			t.Errorf("node %d: expected the locked input to be refunded, balance %d", p.PID, cp.State.Balance(owner))
+		}
+	}
+}
+
+// 节点在 epoch 2 结束后重启, 重启前收到的消息全部丢失; 从账本的检查点恢复后继续参与之后的 epoch,
+// 不等待重启前发出的结果, 输出和累加器与同一分片的其他节点一致
+func TestKronosRestartFromCheckpoint(t *testing.T) {
+	N, F, M, epochs, restart := 4, 1, 2, 4, 2
+	ps := newMemoryParties(t, N, F, M, false)
+	attachLedgers(t, ps)
+
+	outputs := make([]chan []*protobuf.Transaction, N*M)
+	done := make(chan int, N*M)
+	for _, p := range ps {
+		itx, ctx := kronosInputs(p, epochs, 100, nil)
+		outputs[p.PID] = make(chan []*protobuf.Transaction, 4096)
+		go func(p *party.HonestParty) {
+			if p.PID == 1 {
+				startKronos(p, restart, itx, ctx, outputs[p.PID])
+				// 模拟重启: 丢弃重启前收到的各分片结果和内存中的累加器
+				p.CollectEpochs([]string{"InputBFT_Result"}, uint32(restart)+1)
+				p.Acc = nil
+			}
+			startKronos(p, epochs, itx, ctx, outputs[p.PID])
+			done <- int(p.PID)
+		}(p)
+	}
+	for i := 0; i < N*M; i++ {
+		select {
+		case <-done:
+		case <-time.After(60 * time.Second):
+			t.Fatal("Kronos did not finish after the restart")
+		}
+	}
+
+	results := make([][][]string, 2)
+	for i, pid := range []int{0, 1} {
+		close(outputs[pid])
+		for batch := range outputs[pid] {
+			results[i] = append(results[i], txs.Encode(batch))
+		}
+	}
+	if len(results[1]) != 2*epochs || !reflect.DeepEqual(results[0], results[1]) {
+		t.Errorf("restarted node output %d batches differing from node 0", len(results[1]))
+	}
+	if ps[1].Acc == nil || ps[1].Acc.Value().Cmp(ps[0].Acc.Value()) != 0 {
+		t.Fatalf("restarted node has an accumulator differing from node 0")
+	}
+	// 重启后的累加器由检查点恢复了全部元素, 仍能为重启前提交的交易生成 witness
+	first := results[1][0][0]
+	w, err := ps[1].Acc.ProveMembership(first)
+	if err != nil || !crypto.VerifyElementMembership(AccSetup, crypto.HashToPrimeFromSha256, ps[1].Acc.Value(), first, w) {
+		t.Errorf("expected a membership proof for a transaction committed in epoch 1, got %v", err)
+	}
+}
+
+// 提议中直接出现的跨片交易没有各输入分片的结果证明, 输出分片不为其入账
+func TestExecuteTxsCreditsOnlyProvenOutputs(t *testing.T) {
+	state := txs.NewState(2)
+	tx := newCrossTx([]uint32{0, 1}, 2)
+	account := tx.Outputs[0].Object
+
+	executed, _, _ := executeTxs(state, []*protobuf.Transaction{tx}, nil)
+	if len(executed) != 0 || state.Balance(account) != txs.GenesisBalance {
+		t.Fatalf("expected an unproven transaction not to credit anything, balance %d", state.Balance(account))
+	}
+
+	later := newCrossTx([]uint32{0}, 2)
+	executed, _, remaining := executeTxs(state, []*protobuf.Transaction{tx}, []*protobuf.Transaction{tx, later})
+	if len(executed) != 1 || state.Balance(account) != txs.GenesisBalance+tx.Outputs[0].Amount {
+		t.Errorf("expected the proven transaction to be credited, balance %d", state.Balance(account))
+	}
+	if len(remaining) != 1 || remaining[0] != later {
+		t.Errorf("expected the proven transaction not yet in a block to remain, got %d", len(remaining))
+	}
+}
+
+func TestKronosSignedMessages(t *testing.T) {
+	ps, _ := runKronos(t, true, 100)
+	for _, p := range ps {
+		stats := p.DispatchStats()
+		if stats.Reasons[core.DropBadSignature] != 0 || stats.Reasons[core.DropReplay] != 0 {
+			t.Errorf("node %d rejected honest messages: %v", p.PID, stats.Reasons)
+		}
+	}
+}
+
+// InputBFT_Result 的签名只能由源分片 2f+1 个登记的公钥验证, 自带公钥或签名者不足的结果被拒绝
+func TestVerifyShardSignature(t *testing.T) {
+	N, F, M := 4, 1, 2
+	ps := newMemoryParties(t, N, F, M, false)
+	suite := bn256.NewSuite()
+	root := []byte("root")
+	acc := accDigest([]byte("from"), []byte("to"), []byte("exp"))
+	digest := resultDigest(3, 1, root, acc)
+
+	sign := func(digest []byte, pids ...int) ([]byte, []byte) {
+		bm := bitset.New(uint(N))
+		var sigs [][]byte
+		for _, pid := range pids {
+			sig, _ := bls.Sign(suite, ps[pid].SK, digest)
+			sigs = append(sigs, sig)
+			bm.Set(uint(pid % N))
+		}
+		aggsig, _ := bls.AggregateSignatures(suite, sigs...)
+		signers, _ := bm.MarshalBinary()
+		return signers, aggsig
+	}
+
+	signers, aggsig := sign(digest, 4, 5, 6)
+	if err := verifyShardSignature(ps[0], 3, 1, signers, root, acc, aggsig); err != nil {
+		t.Errorf("expected a quorum of shard 1 to verify, got %v", err)
+	}
+	if err := verifyShardSignature(ps[0], 3, 0, signers, root, acc, aggsig); err == nil {
+		t.Error("expected signatures of shard 1 to be rejected as shard 0")
+	}
+	if err := verifyShardSignature(ps[0], 3, 1, signers, []byte("other"), acc, aggsig); err == nil {
+		t.Error("expected a signature over another root to be rejected")
+	}
+	if err := verifyShardSignature(ps[0], 4, 1, signers, root, acc, aggsig); err == nil {
+		t.Error("expected a signature from another epoch to be rejected")
+	}
+	if err := verifyShardSignature(ps[0], 3, 1, signers, root, accDigest([]byte("from"), []byte("other"), []byte("exp")), aggsig); err == nil {
+		t.Error("expected a signature over another accumulator update to be rejected")
+	}
+
+	// 只对树根签名的结果不能通过验证
+	signers, aggsig = sign(root, 4, 5, 6)
+	if err := verifyShardSignature(ps[0], 3, 1, signers, root, acc, aggsig); err == nil {
+		t.Error("expected a signature over the bare root to be rejected")
+	}
+
+	// 一个节点用自己的私钥伪造全部签名者
+	forged, _ := bls.Sign(suite, ps[4].SK, digest)
+	if err := verifyShardSignature(ps[0], 3, 1, signers, root, acc, forged); err == nil {
+		t.Error("expected a signature forged by one node to be rejected")
+	}
+
+	signers, aggsig = sign(digest, 4, 5)
+	if err := verifyShardSignature(ps[0], 3, 1, signers, root, acc, aggsig); err == nil {
+		t.Error("expected fewer than 2f+1 signers to be rejected")
+	}
+}
+
+// 协调者丢弃其他分片节点的签名和无效签名后继续收集, 最终的聚合签名可以通过验证
+func TestCollectRootSignatures(t *testing.T) {
+	N, F, M := 4, 1, 2
+	ps := newMemoryParties(t, N, F, M, false)
+	suite := bn256.NewSuite()
+	root := []byte("root")
+	acc := accDigest([]byte("from"), []byte("to"), []byte("exp"))
+	digest := resultDigest(1, 0, root, acc)
+	sendSig := func(from int, sig []byte) {
+		ps[from].Send(core.Encapsulation("Sigmsg", utils.Uint32ToBytes(1), ps[from].PID, &protobuf.Sigmsg{Root: root, Sig: sig}), 0)
+	}
+	sign := func(pid int, digest []byte) []byte {
+		sig, _ := bls.Sign(suite, ps[pid].SK, digest)
+		return sig
+	}
+
+	// 其他分片的节点, 签错内容的节点, 以及重复发送的节点都不计入
+	sendSig(4, sign(4, digest))
+	sendSig(1, sign(1, root))
+	sendSig(2, sign(2, digest))
+	sendSig(2, sign(2, digest))
+	sendSig(3, sign(3, digest))
+
+	signers, aggsig, err := collectRootSignatures(ps[0], 1, digest, sign(0, digest))
+	if err != nil {
+		t.Fatalf("failed to collect signatures: %v", err)
+	}
+	var bm bitset.BitSet
+	if err := bm.UnmarshalBinary(signers); err != nil || bm.Count() != 3 || bm.Test(1) {
+		t.Errorf("unexpected signers %v", bm.String())
+	}
+	if err := verifyShardSignature(ps[4], 1, 0, signers, root, acc, aggsig); err != nil {
+		t.Errorf("expected the collected signature to verify, got %v", err)
+	}
+}
+
+// 接收方用 PoE 检查源分片累加器的更新, 篡改更新后的值或指数, 或不从上一次接受的值开始时验证失败
+func TestVerifyAccUpdate(t *testing.T) {
+	acc := crypto.NewAccumulator([]string{"tx-0"}, crypto.HashToPrimeFromSha256, AccSetup)
+	from := acc.Value().Bytes()
+	proof, x, err := acc.AddAndProve([]string{"tx-1", "tx-2"})
+	if err != nil {
+		t.Fatalf("failed to update the accumulator: %v", err)
+	}
+	valid := &protobuf.InputBFT_Result{
+		AccFrom:  from,
+		Acc:      acc.Value().Bytes(),
+		AccExp:   x.Bytes(),
+		AccProof: proof.Q.Bytes(),
+	}
+	if !verifyAccUpdate(valid, from) {
+		t.Fatal("expected a valid accumulator update to verify")
+	}
+
+	forgedAcc := proto.Clone(valid).(*protobuf.InputBFT_Result)
+	forgedAcc.Acc = from
+	if verifyAccUpdate(forgedAcc, from) {
+		t.Error("expected an update with a forged accumulator value to be rejected")
+	}
+	forgedExp := proto.Clone(valid).(*protobuf.InputBFT_Result)
+	forgedExp.AccExp = crypto.GenRepresentatives([]string{"tx-1"}, crypto.HashToPrimeFromSha256)[0].Bytes()
+	if verifyAccUpdate(forgedExp, from) {
+		t.Error("expected an update with a forged exponent to be rejected")
+	}
+
+	// 源分片不能从上一次被接受的值以外的值开始更新
+	other := crypto.NewAccumulator([]string{"tx-9"}, crypto.HashToPrimeFromSha256, AccSetup).Value().Bytes()
+	if verifyAccUpdate(valid, other) {
+		t.Error("expected an update that does not continue the last accepted accumulator to be rejected")
+	}
+}
+
+// 输出分片只收到自己的交易和多重证明, 协调者少发交易时验证失败
+func TestVerifyResultProof(t *testing.T) {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	byShard := map[int][]*protobuf.Transaction{
+		0: {txs.CrossTxGenerator(32, 3, 100, 0, chars)},
+		1: {txs.CrossTxGenerator(32, 3, 100, 0, chars), txs.CrossTxGenerator(32, 3, 100, 0, chars)},
+		2: {txs.CrossTxGenerator(32, 3, 100, 0, chars)},
+	}
+	rejected := []*protobuf.Transaction{txs.CrossTxGenerator(32, 3, 0, 0, chars)}
+	counts := resultCounts(byShard, 3)
+	tree := crypto.NewTxMerkleTree(resultLeaves(counts, byShard, rejected))
+	proof, err := tree.Prove(resultIndices(counts, 1, len(rejected)))
+	if err != nil {
+		t.Fatalf("failed to prove: %v", err)
+	}
+
+	p := &party.HonestParty{M: 3, Snumber: 1}
+	result := &protobuf.InputBFT_Result{Txs: byShard[1], Root: tree.Root(), Rejected: rejected, Counts: counts, Proof: proof.Hashes}
+	if !verifyResultProof(p, result) {
+		t.Fatal("valid result did not verify")
+	}
+
+	omitted := proto.Clone(result).(*protobuf.InputBFT_Result)
+	omitted.Txs = omitted.Txs[:1]
+	if verifyResultProof(p, omitted) {
+		t.Error("result missing a transaction verified")
+	}
+	recounted := proto.Clone(result).(*protobuf.InputBFT_Result)
+	recounted.Txs, recounted.Counts = recounted.Txs[:1], []uint32{1, 1, 1}
+	if verifyResultProof(p, recounted) {
+		t.Error("result with altered counts verified")
+	}
+	if verifyResultProof(&party.HonestParty{M: 3, Snumber: 0}, result) {
+		t.Error("result for shard 1 verified at shard 0")
+	}
+}
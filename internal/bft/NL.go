@@ -18,9 +18,18 @@ import (
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
 	"gopkg.in/yaml.v2"
 )
 
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"NoLiveness":  func() proto.Message { return new(protobuf.NoLiveness) },
+		"NL_Response": func() proto.Message { return new(protobuf.NL_Response) },
+		"NL_Confirm":  func() proto.Message { return new(protobuf.NL_Confirm) },
+	})
+}
+
 type NLConfig struct {
 	NLShardID int      `yaml:"NLShardID"`
 	H         int      `yaml:"h"`
@@ -80,9 +89,14 @@ func NLFinder(p *party.HonestParty, nlConfig *NLConfig) {
 	if p.Debug {
 		fmt.Println("Received NL_ResponseMessage", p.PID)
 	}
-	payload := (core.Decapsulation("NL_Response", NLResponseMessage)).(*protobuf.NL_Response)
+	raw, err := core.Decapsulation("NL_Response", NLResponseMessage)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	payload := raw.(*protobuf.NL_Response)
 
-	err := bls.Verify(suite, utils.BytesToPoint(payload.Aggpk), append(utils.Uint32ToBytes(payload.H), payload.A...), payload.Aggsig)
+	err = bls.Verify(suite, utils.BytesToPoint(payload.Aggpk), append(utils.Uint32ToBytes(payload.H), payload.A...), payload.Aggsig)
 	if err != nil {
 		log.Println("invalid signature of NL_Response message", err)
 		return
@@ -100,12 +114,12 @@ func NLFinder(p *party.HonestParty, nlConfig *NLConfig) {
 	p.Broadcast(NLConfirmMessage)
 
 	// Step3: 进入全局BFT
-	inputChannel := make(chan []string, 4096)
-	receiveChannel := make(chan []string, 4096)
+	inputChannel := make(chan []*protobuf.Transaction, 4096)
+	receiveChannel := make(chan []*protobuf.Transaction, 4096)
 	e := uint32(1)
-	// inputChannel <- []string{"test for NL"}
+	// inputChannel <- []*protobuf.Transaction{{Data: []byte("test for NL")}}
 	fmt.Println("Enter HotStuffProcess", p.PID)
-	HotStuffProcess(p, int(e), inputChannel, receiveChannel, true)
+	HotStuffProcess(p, NewChainState(), int(e), inputChannel, receiveChannel, true)
 	res := <-receiveChannel
 	timeEnd := time.Now()
 	// 输出结果
@@ -136,7 +150,12 @@ func NLHelper(p *party.HonestParty, nlConfig *NLConfig) {
 
 	for {
 		m := <-p.GetMessage("NoLiveness", utils.Uint32ToBytes(1))
-		payload := (core.Decapsulation("NoLiveness", m)).(*protobuf.NoLiveness)
+		raw, err := core.Decapsulation("NoLiveness", m)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.NoLiveness)
 
 		// fmt.Println("Received NoLivenessMessage:", uint32(payload.ShardID), uint32(payload.H), payload.A, payload.Sig)
 
@@ -145,7 +164,7 @@ func NLHelper(p *party.HonestParty, nlConfig *NLConfig) {
 			continue
 		}
 
-		err := bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(payload.H), payload.A...), payload.Sig)
+		err = bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(payload.H), payload.A...), payload.Sig)
 		if err != nil {
 			log.Println("invalid signature of NoLiveness message", err)
 			continue
@@ -184,14 +203,19 @@ func NLHelper(p *party.HonestParty, nlConfig *NLConfig) {
 	seen = make(map[int]bool)
 	for {
 		m := <-p.GetMessage("NL_Confirm", utils.Uint32ToBytes(1))
-		payload := (core.Decapsulation("NL_Confirm", m)).(*protobuf.NL_Confirm)
+		raw, err := core.Decapsulation("NL_Confirm", m)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.NL_Confirm)
 
 		if payload.ShardID != uint32(nlConfig.NLShardID) || payload.H != uint32(nlConfig.H) || !bytes.Equal(payload.A, A_bytes) {
 			log.Println("Received unexpected NL_Confirm message")
 			continue
 		}
 
-		err := bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(uint32(payload.H)), payload.A...), payload.Sig)
+		err = bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(uint32(payload.H)), payload.A...), payload.Sig)
 		if err != nil {
 			log.Println("invalid signature of NL_Confirm message", err)
 			continue
@@ -206,15 +230,15 @@ func NLHelper(p *party.HonestParty, nlConfig *NLConfig) {
 		}
 	}
 	// 全局BFT
-	inputChannel := make(chan []string, 4096)
-	receiveChannel := make(chan []string, 4096)
+	inputChannel := make(chan []*protobuf.Transaction, 4096)
+	receiveChannel := make(chan []*protobuf.Transaction, 4096)
 	e := uint32(1)
 	if (e-1)%(p.N*p.M) == p.PID {
-		inputChannel <- []string{"This is the result for NL"}
+		inputChannel <- []*protobuf.Transaction{{Data: []byte("This is the result for NL")}}
 	}
-	// inputChannel <- []string{"test for NL"}
+	// inputChannel <- []*protobuf.Transaction{{Data: []byte("test for NL")}}
 	fmt.Println("Enter HotStuffProcess", p.PID)
-	HotStuffProcess(p, int(e), inputChannel, receiveChannel, true)
+	HotStuffProcess(p, NewChainState(), int(e), inputChannel, receiveChannel, true)
 	res := <-receiveChannel
 	timeEnd := time.Now()
 	// 输出结果
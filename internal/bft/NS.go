@@ -18,9 +18,17 @@ import (
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
 	"gopkg.in/yaml.v2"
 )
 
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"NoSafety":  func() proto.Message { return new(protobuf.NoSafety) },
+		"NS_Choice": func() proto.Message { return new(protobuf.NS_Choice) },
+	})
+}
+
 type NSConfig struct {
 	NSShard int      `yaml:"NSShard"`
 	H       int      `yaml:"H"`
@@ -127,15 +135,15 @@ func NSFinder(p *party.HonestParty, NSConfig *NSConfig) {
 	p.Broadcast(NSChoiceMessage)
 
 	// step3: run global BFT
-	inputChannel := make(chan []string, 4096)
-	receiveChannel := make(chan []string, 4096)
+	inputChannel := make(chan []*protobuf.Transaction, 4096)
+	receiveChannel := make(chan []*protobuf.Transaction, 4096)
 	e := uint32(1)
 	intersection := nodes1_bm.Intersection(nodes2_bm)
 	input_str := "<NS BadNodes " + intersection.String() + " Choice " + NSConfig.A1.String() + ">"
-	inputChannel <- []string{input_str}
+	inputChannel <- []*protobuf.Transaction{{Data: []byte(input_str)}}
 
 	fmt.Println("Enter HotStuffProcess", p.PID)
-	HotStuffProcess(p, int(e), inputChannel, receiveChannel, true)
+	HotStuffProcess(p, NewChainState(), int(e), inputChannel, receiveChannel, true)
 	res := <-receiveChannel
 	timeEnd := time.Now()
 	log.Println("NSFinder result:", res, p.PID)
@@ -158,7 +166,12 @@ func NSHelperIntra(p *party.HonestParty) {
 	timeStart := time.Now()
 	// step1: receive NoSafety message
 	m := <-p.GetMessage("NoSafety", utils.Uint32ToBytes(1))
-	payload := (core.Decapsulation("NoSafety", m)).(*protobuf.NoSafety)
+	raw, err := core.Decapsulation("NoSafety", m)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	payload := raw.(*protobuf.NoSafety)
 
 	var nodes1_bm, nodes2_bm bitset.BitSet
 	nodes1_bm.UnmarshalBinary(payload.Nodes1)
@@ -178,17 +191,17 @@ func NSHelperIntra(p *party.HonestParty) {
 	p.Broadcast(NSChoiceMessage)
 
 	// step3: run global BFT
-	inputChannel := make(chan []string, 4096)
-	receiveChannel := make(chan []string, 4096)
+	inputChannel := make(chan []*protobuf.Transaction, 4096)
+	receiveChannel := make(chan []*protobuf.Transaction, 4096)
 	e := uint32(1)
 	intersection := nodes1_bm.Intersection(&nodes2_bm)
 	// 把 payload.A1 转化为 big.Int
 	A1_big := new(big.Int).SetBytes(payload.A1)
 	input_str := "<NS BadNodes " + intersection.String() + " Choice " + A1_big.String() + ">"
-	inputChannel <- []string{input_str}
+	inputChannel <- []*protobuf.Transaction{{Data: []byte(input_str)}}
 
 	fmt.Println("Enter HotStuffProcess", p.PID)
-	HotStuffProcess(p, int(e), inputChannel, receiveChannel, true)
+	HotStuffProcess(p, NewChainState(), int(e), inputChannel, receiveChannel, true)
 	res := <-receiveChannel
 	timeEnd := time.Now()
 	log.Println("NSHelperIntra result:", res, p.PID)
@@ -211,7 +224,12 @@ func NSHelperCross(p *party.HonestParty) {
 	timeStart := time.Now()
 	// step1: receive NoSafety message
 	m := <-p.GetMessage("NoSafety", utils.Uint32ToBytes(1))
-	payload := (core.Decapsulation("NoSafety", m)).(*protobuf.NoSafety)
+	raw, err := core.Decapsulation("NoSafety", m)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	payload := raw.(*protobuf.NoSafety)
 	H := payload.H
 	ShardID := payload.ShardID
 	A1_big := new(big.Int).SetBytes(payload.A1)
@@ -228,14 +246,19 @@ func NSHelperCross(p *party.HonestParty) {
 
 	for {
 		m := <-p.GetMessage("NS_Choice", utils.Uint32ToBytes(1))
-		payload := (core.Decapsulation("NS_Choice", m)).(*protobuf.NS_Choice)
+		raw, err := core.Decapsulation("NS_Choice", m)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.NS_Choice)
 
 		if payload.ShardID != ShardID || payload.H != H {
 			log.Println("Received unexpected NS_Choice message")
 			continue
 		}
 
-		err := bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(uint32(payload.H)), payload.AChoice...), payload.Sig)
+		err = bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(uint32(payload.H)), payload.AChoice...), payload.Sig)
 		if err != nil {
 			log.Println("invalid signature of NS_Choice message", err)
 			continue
@@ -255,15 +278,15 @@ func NSHelperCross(p *party.HonestParty) {
 	}
 
 	// step3: run global BFT
-	inputChannel := make(chan []string, 4096)
-	receiveChannel := make(chan []string, 4096)
+	inputChannel := make(chan []*protobuf.Transaction, 4096)
+	receiveChannel := make(chan []*protobuf.Transaction, 4096)
 	e := uint32(1)
 	intersection := nodes1_bm.Intersection(&nodes2_bm)
 	input_str := "<NS BadNodes " + intersection.String() + " Choice " + AChoice + ">"
-	inputChannel <- []string{input_str}
+	inputChannel <- []*protobuf.Transaction{{Data: []byte(input_str)}}
 
 	fmt.Println("Enter HotStuffProcess", p.PID)
-	HotStuffProcess(p, int(e), inputChannel, receiveChannel, true)
+	HotStuffProcess(p, NewChainState(), int(e), inputChannel, receiveChannel, true)
 	res := <-receiveChannel
 	timeEnd := time.Now()
 	log.Println("NSHelperCross result:", res, p.PID)
@@ -18,9 +18,18 @@ import (
 	"github.com/bits-and-blooms/bitset"
 	"go.dedis.ch/kyber/v3/pairing/bn256"
 	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
 	"gopkg.in/yaml.v2"
 )
 
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"ReConfig":    func() proto.Message { return new(protobuf.ReConfig) },
+		"RC_CheckOK":  func() proto.Message { return new(protobuf.RC_CheckOK) },
+		"RC_NewEpoch": func() proto.Message { return new(protobuf.RC_NewEpoch) },
+	})
+}
+
 type RCConfig struct {
 	RCShardID int      `yaml:"RCShardID"`
 	H         int      `yaml:"h"`
@@ -91,14 +100,19 @@ func RCHelper(p *party.HonestParty, rcConfig *RCConfig) {
 
 	for {
 		m := <-p.GetMessage("ReConfig", utils.Uint32ToBytes(1))
-		payload := (core.Decapsulation("ReConfig", m)).(*protobuf.ReConfig)
+		raw, err := core.Decapsulation("ReConfig", m)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.ReConfig)
 
 		if payload.ShardID != uint32(rcConfig.RCShardID) || payload.H != uint32(rcConfig.H) || !bytes.Equal(payload.A, A_bytes) {
 			log.Println("Received unexpected ReConfig message")
 			continue
 		}
 
-		err := bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(uint32(payload.H)), payload.A...), payload.Sig)
+		err = bls.Verify(suite, p.PK[m.Sender], append(utils.Uint32ToBytes(uint32(payload.H)), payload.A...), payload.Sig)
 		if err != nil {
 			log.Println("invalid signature of ReConfig message", err)
 			continue
@@ -145,14 +159,19 @@ func RCHelper(p *party.HonestParty, rcConfig *RCConfig) {
 
 	for {
 		m := <-p.GetMessage("RC_CheckOK", utils.Uint32ToBytes(1))
-		payload := (core.Decapsulation("RC_CheckOK", m)).(*protobuf.RC_CheckOK)
+		raw, err := core.Decapsulation("RC_CheckOK", m)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.RC_CheckOK)
 
 		if payload.ShardID != uint32(rcConfig.RCShardID) || payload.H != uint32(rcConfig.H) || !bytes.Equal(payload.A, A_bytes) {
 			log.Println("Received unexpected RC_CheckOK message")
 			continue
 		}
 
-		err := bls.Verify(suite, p.PK[m.Sender], append(payload.A, payload.NewNodes...), payload.Sig)
+		err = bls.Verify(suite, p.PK[m.Sender], append(payload.A, payload.NewNodes...), payload.Sig)
 		if err != nil {
 			log.Println("invalid signature of RC_CheckOK message", err)
 			continue
@@ -189,14 +208,19 @@ func RCHelper(p *party.HonestParty, rcConfig *RCConfig) {
 
 	for {
 		m := <-p.GetMessage("RC_NewEpoch", utils.Uint32ToBytes(1))
-		payload := (core.Decapsulation("RC_NewEpoch", m)).(*protobuf.RC_NewEpoch)
+		raw, err := core.Decapsulation("RC_NewEpoch", m)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.RC_NewEpoch)
 
 		if payload.ShardID != uint32(rcConfig.RCShardID) {
 			log.Println("Received unexpected RC_NewEpoch message")
 			continue
 		}
 
-		err := bls.Verify(suite, p.PK[m.Sender], payload.NewNodes, payload.Sig)
+		err = bls.Verify(suite, p.PK[m.Sender], payload.NewNodes, payload.Sig)
 		if err != nil {
 			log.Println("invalid signature of RC_NewEpoch message", err)
 			continue
@@ -1,50 +1,76 @@
 package bft
 
 import (
+	"Chamael/pkg/protobuf"
 	"Chamael/pkg/txs"
-	"crypto/sha256"
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
 	"sync"
+
+	"google.golang.org/protobuf/proto"
 )
 
+// ErrConflictingCopy 输入分片发来的交易与交易池中已有的副本不一致
+var ErrConflictingCopy = errors.New("input shard reported a different copy of the transaction")
+
+// PendingTimeout 交易第一次进入交易池后, 最多再等待多少个 epoch 的输入分片结果, 超时后被中止
+var PendingTimeout = uint32(5)
+
 // 交易池数据结构
 type TransactionPool struct {
 	mu           sync.Mutex
 	transactions map[string]*TransactionRecord
+	aborted      map[string]uint32 // 被拒绝或超时而中止的交易 -> 中止时的 epoch, 期间收到的其他分片的结果直接忽略
+	expired      uint64            // 超时被中止的交易总数
+	pruned       uint64            // 超过 PendingTimeout 后被清理的中止记录总数
+	onExpire     func(tx *protobuf.Transaction)
 }
 
 // 交易记录数据结构
 type TransactionRecord struct {
-	Transaction    *txs.Transaction
+	Transaction    *protobuf.Transaction `json:"-"` // 第一个输入分片发来的交易的副本, 完成后输出
+	Raw            []byte                // Transaction 的确定性编码, 用于比较其他输入分片发来的副本, 恢复交易池时从中解码出 Transaction
 	ReceivedShards []int
+	Epoch          uint32 // 第一次收到该交易的 epoch
+	Deadline       uint32 // 到这个 epoch 仍未收齐输入分片的结果则中止
 }
 
 // 创建一个新的交易池
 func NewTransactionPool() *TransactionPool {
 	return &TransactionPool{
 		transactions: make(map[string]*TransactionRecord),
+		aborted:      make(map[string]uint32),
 	}
 }
 
-// 添加交易到交易池
-func (tp *TransactionPool) AddTransaction(tx string, shardID int) error {
+// 添加交易到交易池, e 为收到的输入分片结果所属的 epoch
+func (tp *TransactionPool) AddTransaction(tx *protobuf.Transaction, shardID int, e uint32) error {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
 
-	// 提取交易详情
-	transactionDetails, err := txs.ExtractTransactionDetails(tx)
-	if err != nil {
+	if err := txs.Verify(tx); err != nil {
 		return err
 	}
 
 	// 唯一标识交易的键
-	txKey := generateTransactionKey(tx)
+	txKey := hex.EncodeToString(tx.Id)
+	if _, ok := tp.aborted[txKey]; ok {
+		return nil
+	}
+
+	raw := txs.Marshal(tx)
 
 	// 检查交易池中是否已存在
 	if record, exists := tp.transactions[txKey]; exists {
+		// 各输入分片发来的副本必须逐字节相同, 不一致的副本不计入, 交易最终超时中止
+		if !bytes.Equal(record.Raw, raw) {
+			return fmt.Errorf("%w: %s from shard %d", ErrConflictingCopy, txKey, shardID)
+		}
 		// 更新已接收到的分片列表
 		if !contains(record.ReceivedShards, shardID) {
 			record.ReceivedShards = append(record.ReceivedShards, shardID)
@@ -52,8 +78,11 @@ func (tp *TransactionPool) AddTransaction(tx string, shardID int) error {
 	} else {
 		// 新交易，加入交易池
 		tp.transactions[txKey] = &TransactionRecord{
-			Transaction:    transactionDetails,
+			Transaction:    proto.Clone(tx).(*protobuf.Transaction),
+			Raw:            raw,
 			ReceivedShards: []int{shardID},
+			Epoch:          e,
+			Deadline:       e + PendingTimeout,
 		}
 	}
 
@@ -61,28 +90,18 @@ func (tp *TransactionPool) AddTransaction(tx string, shardID int) error {
 }
 
 // 检查交易池并移除满足条件的交易
-func (tp *TransactionPool) CheckAndRemoveTransactions() []string {
+func (tp *TransactionPool) CheckAndRemoveTransactions() []*protobuf.Transaction {
 	tp.mu.Lock()
 	defer tp.mu.Unlock()
 
-	var completedTransactions []string
+	var completedTransactions []*protobuf.Transaction
 
 	for key, record := range tp.transactions {
 		// 判断是否所有输入分片都已发送了该交易
 		sort.Ints(record.ReceivedShards)
-		sort.Ints(record.Transaction.InputShard)
-		if reflect.DeepEqual(record.ReceivedShards, record.Transaction.InputShard) {
-			// 条件满足，构建格式化字符串
-			formattedTx := fmt.Sprintf(
-				"<Dummy TX: %s, Userset: 10, Input Shard: %v, Input Valid: %v, Output Shard: %d, Output Valid: %d>",
-				key,
-				record.Transaction.InputShard,
-				record.Transaction.InputValid,
-				record.Transaction.OutputShard,
-				record.Transaction.OutputValid,
-			)
+		if reflect.DeepEqual(record.ReceivedShards, txs.InputShards(record.Transaction)) {
 			// 加入完成列表
-			completedTransactions = append(completedTransactions, formattedTx)
+			completedTransactions = append(completedTransactions, record.Transaction)
 			// 从交易池中移除
 			delete(tp.transactions, key)
 		}
@@ -91,6 +110,124 @@ func (tp *TransactionPool) CheckAndRemoveTransactions() []string {
 	return completedTransactions
 }
 
+// 中止在 epoch e 被输入分片拒绝的交易: 从交易池中移除, 并忽略之后收到的该交易
+func (tp *TransactionPool) AbortTransaction(tx *protobuf.Transaction, e uint32) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	txKey := hex.EncodeToString(tx.Id)
+	delete(tp.transactions, txKey)
+	tp.aborted[txKey] = e
+}
+
+// 设置交易超时被中止时的回调, 用于退还锁定的输入并通知其他分片
+func (tp *TransactionPool) OnExpire(callback func(tx *protobuf.Transaction)) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	tp.onExpire = callback
+}
+
+// 中止到 epoch e 仍未完成的交易, 按交易ID顺序对每笔交易调用 OnExpire 设置的回调并返回这些交易.
+// 中止超过 PendingTimeout 个 epoch 的记录同时被清理, 此时各输入分片的结果早已到达或超时, 不必再忽略
+func (tp *TransactionPool) Expire(e uint32) []*protobuf.Transaction {
+	tp.mu.Lock()
+	for key, aborted := range tp.aborted {
+		if aborted+PendingTimeout < e {
+			delete(tp.aborted, key)
+			tp.pruned++
+		}
+	}
+	var keys []string
+	for key, record := range tp.transactions {
+		if record.Deadline <= e {
+			keys = append(keys, key)
+		}
+	}
+	// 同一分片的节点以相同的顺序中止, 保证随后发送的拒绝列表一致
+	sort.Strings(keys)
+	var expired []*protobuf.Transaction
+	for _, key := range keys {
+		expired = append(expired, tp.transactions[key].Transaction)
+		delete(tp.transactions, key)
+		tp.aborted[key] = e
+	}
+	tp.expired += uint64(len(expired))
+	callback := tp.onExpire
+	tp.mu.Unlock()
+
+	if callback != nil {
+		for _, tx := range expired {
+			callback(tx)
+		}
+	}
+	return expired
+}
+
+// 统计 epoch e 时交易池中尚未完成的交易, 以及每个输入分片上等待最久的交易已等待的 epoch 数
+func (tp *TransactionPool) Stats(e uint32) txs.PoolStats {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+
+	stats := txs.PoolStats{
+		Pending:   len(tp.transactions),
+		Expired:   tp.expired,
+		Aborted:   len(tp.aborted),
+		Pruned:    tp.pruned,
+		OldestAge: make(map[int]uint32),
+	}
+	for _, record := range tp.transactions {
+		var age uint32
+		if e > record.Epoch {
+			age = e - record.Epoch
+		}
+		for _, shard := range txs.InputShards(record.Transaction) {
+			if !contains(record.ReceivedShards, shard) && age >= stats.OldestAge[shard] {
+				stats.OldestAge[shard] = age
+			}
+		}
+	}
+	return stats
+}
+
+// poolSnapshot 交易池的序列化格式
+type poolSnapshot struct {
+	Transactions map[string]*TransactionRecord
+	Aborted      map[string]uint32
+	Expired      uint64
+	Pruned       uint64
+}
+
+// 序列化交易池中尚未完成的交易, 用于节点重启后恢复
+func (tp *TransactionPool) Snapshot() ([]byte, error) {
+	tp.mu.Lock()
+	defer tp.mu.Unlock()
+	return json.Marshal(poolSnapshot{Transactions: tp.transactions, Aborted: tp.aborted, Expired: tp.expired, Pruned: tp.pruned})
+}
+
+// 从 Snapshot 的结果恢复交易池
+func RestoreTransactionPool(data []byte) (*TransactionPool, error) {
+	tp := NewTransactionPool()
+	if len(data) == 0 {
+		return tp, nil
+	}
+	var snap poolSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	for key, record := range snap.Transactions {
+		record.Transaction = new(protobuf.Transaction)
+		if err := proto.Unmarshal(record.Raw, record.Transaction); err != nil {
+			return nil, err
+		}
+		tp.transactions[key] = record
+	}
+	for key, e := range snap.Aborted {
+		tp.aborted[key] = e
+	}
+	tp.expired, tp.pruned = snap.Expired, snap.Pruned
+	return tp, nil
+}
+
 // 判断切片是否包含指定元素
 func contains(slice []int, value int) bool {
 	for _, v := range slice {
@@ -101,12 +238,6 @@ func contains(slice []int, value int) bool {
 	return false
 }
 
-// 生成交易的唯一标识键
-func generateTransactionKey(tx string) string {
-	hash := sha256.Sum256([]byte(tx))
-	return hex.EncodeToString(hash[:]) // 返回交易的 SHA-256 哈希值作为唯一键
-}
-
 // 打印交易池详情
 func (tp *TransactionPool) PrintTxPoolDetail() {
 	tp.mu.Lock()
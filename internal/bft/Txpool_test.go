@@ -1,23 +1,41 @@
 package bft
 
 import (
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/txs"
+	"bytes"
+	"crypto/ed25519"
+	"errors"
 	"testing"
+
+	"google.golang.org/protobuf/proto"
 )
 
+// newCrossTx 生成输入分片为 inputShards, 输出分片为 outputShard, 由新账户签名的交易
+func newCrossTx(inputShards []uint32, outputShard uint32) *protobuf.Transaction {
+	owner, sk, _ := ed25519.GenerateKey(nil)
+	tx := &protobuf.Transaction{Outputs: []*protobuf.TxOutput{{Shard: outputShard, Object: []byte("out"), Amount: 1}}}
+	for _, shard := range inputShards {
+		tx.Inputs = append(tx.Inputs, &protobuf.TxInput{Shard: shard, Object: owner, Amount: 1})
+	}
+	txs.Sign(tx, sk)
+	return tx
+}
+
 // 测试交易池的添加和移除功能
 func TestTransactionPool(t *testing.T) {
 	// 创建交易池
 	pool := NewTransactionPool()
 
 	// 示例交易
-	tx1 := "<Dummy TX: CZZDB66YF4F42B1I6W0FM2OHTIC96SRM, Userset: 10, Input Shard: [0 1 2], Input Valid: [1], Output Shard: 1, Output Valid: 0 >"
+	tx1 := newCrossTx([]uint32{0, 1, 2}, 3)
 
 	// 添加交易到分片 0 和 1
-	err := pool.AddTransaction(tx1, 0)
+	err := pool.AddTransaction(tx1, 0, 1)
 	if err != nil {
 		t.Fatalf("failed to add transaction to shard 0: %v", err)
 	}
-	err = pool.AddTransaction(tx1, 1)
+	err = pool.AddTransaction(tx1, 1, 1)
 	if err != nil {
 		t.Fatalf("failed to add transaction to shard 1: %v", err)
 	}
@@ -28,15 +46,15 @@ func TestTransactionPool(t *testing.T) {
 	}
 
 	// 添加剩余的分片
-	err = pool.AddTransaction(tx1, 2)
+	err = pool.AddTransaction(tx1, 2, 1)
 	if err != nil {
 		t.Fatalf("failed to add transaction to shard 2: %v", err)
 	}
 
 	// 检查并移除完成的交易
 	completed := pool.CheckAndRemoveTransactions()
-	if len(completed) != 1 {
-		t.Errorf("expected 1 completed transaction, got %d", len(completed))
+	if len(completed) != 1 || !bytes.Equal(txs.Marshal(completed[0]), txs.Marshal(tx1)) {
+		t.Errorf("expected the original transaction to complete, got %v", completed)
 	}
 
 	// 检查池中剩余交易
@@ -44,3 +62,108 @@ func TestTransactionPool(t *testing.T) {
 		t.Errorf("expected 0 transactions in pool, got %d", len(pool.transactions))
 	}
 }
+
+// 内容与ID不一致的交易不能进入交易池
+func TestTransactionPoolRejectsInvalidTransactions(t *testing.T) {
+	pool := NewTransactionPool()
+	tx := newCrossTx([]uint32{0}, 1)
+	tx.Nonce++
+	if err := pool.AddTransaction(tx, 0, 1); err == nil {
+		t.Error("expected a transaction with a stale id to be rejected")
+	}
+}
+
+// 被拒绝的交易从交易池中移除, 之后其他输入分片的结果不会让它完成
+func TestTransactionPoolAbort(t *testing.T) {
+	pool := NewTransactionPool()
+	tx := newCrossTx([]uint32{0, 1}, 2)
+	if err := pool.AddTransaction(tx, 0, 1); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pool.AbortTransaction(tx, 1)
+	if err := pool.AddTransaction(tx, 1, 1); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if completed := pool.CheckAndRemoveTransactions(); len(completed) != 0 || len(pool.transactions) != 0 {
+		t.Errorf("expected the aborted transaction to be dropped, got %v", completed)
+	}
+}
+
+// 超时未收齐输入分片结果的交易被中止, 统计中给出每个输入分片上等待最久的交易
+func TestTransactionPoolExpire(t *testing.T) {
+	pool := NewTransactionPool()
+	var expired []*protobuf.Transaction
+	pool.OnExpire(func(tx *protobuf.Transaction) {
+		expired = append(expired, tx)
+	})
+
+	old := newCrossTx([]uint32{0, 1}, 3)
+	young := newCrossTx([]uint32{1, 2}, 3)
+	if err := pool.AddTransaction(old, 0, 1); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddTransaction(young, 2, 3); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+
+	stats := pool.Stats(4)
+	if stats.Pending != 2 || stats.OldestAge[1] != 3 || stats.OldestAge[0] != 0 || len(stats.OldestAge) != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if got := pool.Expire(1 + PendingTimeout); len(got) != 1 || !bytes.Equal(got[0].Id, old.Id) || len(expired) != 1 {
+		t.Fatalf("expected only the old transaction to expire, got %v", got)
+	}
+	// 超时后才到达的结果不会让交易完成
+	if err := pool.AddTransaction(old, 1, 2+PendingTimeout); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if completed := pool.CheckAndRemoveTransactions(); len(completed) != 0 {
+		t.Errorf("expected the expired transaction to stay aborted, got %v", completed)
+	}
+	if stats := pool.Stats(2 + PendingTimeout); stats.Pending != 1 || stats.Expired != 1 || stats.Aborted != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// 中止超过 PendingTimeout 个 epoch 的记录被清理, 只保留刚超时的交易
+	pool.Expire(2 + 2*PendingTimeout)
+	if stats := pool.Stats(2 + 2*PendingTimeout); stats.Pending != 0 || stats.Expired != 2 || stats.Aborted != 1 || stats.Pruned != 1 {
+		t.Errorf("unexpected stats after pruning %+v", stats)
+	}
+}
+
+// 输入分片发来的副本与已有副本不一致时不计入, 交易池输出的始终是原始交易
+func TestTransactionPoolRejectsConflictingCopies(t *testing.T) {
+	pool := NewTransactionPool()
+	tx := newCrossTx([]uint32{0, 1}, 2)
+	if err := pool.AddTransaction(tx, 0, 1); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+
+	// ID 不覆盖签名, 替换签名后的副本 ID 不变, 但签名无效
+	forged := proto.Clone(tx).(*protobuf.Transaction)
+	forged.Sig = make([]byte, len(tx.Sig))
+	if err := pool.AddTransaction(forged, 1, 1); !errors.Is(err, txs.ErrBadSig) {
+		t.Fatalf("expected ErrBadSig, got %v", err)
+	}
+	if completed := pool.CheckAndRemoveTransactions(); len(completed) != 0 {
+		t.Fatalf("expected the conflicting copy not to count, got %v", completed)
+	}
+
+	// 恢复后的交易池仍保存原始交易
+	data, err := pool.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+	restored, err := RestoreTransactionPool(data)
+	if err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if err := restored.AddTransaction(tx, 1, 2); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	completed := restored.CheckAndRemoveTransactions()
+	if len(completed) != 1 || !bytes.Equal(txs.Marshal(completed[0]), txs.Marshal(tx)) {
+		t.Errorf("expected the original transaction to complete, got %v", completed)
+	}
+}
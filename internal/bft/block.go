@@ -0,0 +1,327 @@
+package bft
+
+import (
+	"Chamael/internal/party"
+	"Chamael/pkg/crypto"
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/txs"
+	"Chamael/pkg/utils"
+	"Chamael/pkg/utils/db"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/bits-and-blooms/bitset"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+)
+
+// genesis 创世区块, 高度为 0
+var genesis = &protobuf.Block{Height: 0}
+
+// genesisHash 创世区块的哈希
+var genesisHash = BlockHash(genesis)
+
+// NewBlock 由 Leader 生成区块, TxRoot 为交易列表的默克尔树根
+func NewBlock(height uint32, parent []byte, proposer uint32, batch []*protobuf.Transaction, justify *protobuf.QuorumCert) *protobuf.Block {
+	return &protobuf.Block{
+		Height:   height,
+		Parent:   parent,
+		Proposer: proposer,
+		TxRoot:   txsRoot(batch),
+		Justify:  justify,
+		Txs:      batch,
+	}
+}
+
+// txsRoot 交易确定性编码的默克尔树根
+func txsRoot(batch []*protobuf.Transaction) []byte {
+	return crypto.TxsRoot(txs.Encode(batch))
+}
+
+// ProveBlockTx 区块中第 i 笔交易对区块 TxRoot 的包含证明
+func ProveBlockTx(b *protobuf.Block, i int) (*crypto.MerkleMultiProof, error) {
+	return crypto.NewTxMerkleTree(txs.Encode(b.Txs)).ProveTx(i)
+}
+
+// VerifyBlockTx 验证交易 tx 包含在 TxRoot 为 root 的区块中, 客户端不需要区块的其他交易
+func VerifyBlockTx(root []byte, tx *protobuf.Transaction, proof *crypto.MerkleMultiProof) bool {
+	return crypto.VerifyMultiProof(root, proof, txs.Encode([]*protobuf.Transaction{tx}))
+}
+
+// BlockHash 区块哈希: sha256(height||parent||proposer||txRoot||justify.blockHash||justify.epoch||justify.view),
+// 交易内容经由 txRoot 绑定
+func BlockHash(b *protobuf.Block) []byte {
+	hash := sha256.Sum256(utils.MessageEncap([][]byte{
+		utils.Uint32ToBytes(b.Height),
+		b.Parent,
+		utils.Uint32ToBytes(b.Proposer),
+		b.TxRoot,
+		b.Justify.GetBlockHash(),
+		utils.Uint32ToBytes(b.Justify.GetEpoch()),
+		utils.Uint32ToBytes(b.Justify.GetView()),
+	}))
+	return hash[:]
+}
+
+// VerifyBlockTxs 检查区块中的交易与 TxRoot 是否一致
+func VerifyBlockTxs(b *protobuf.Block) bool {
+	return bytes.Equal(b.TxRoot, txsRoot(b.Txs))
+}
+
+// voteDigest 投票的签名内容: blockHash||phase||epoch, phase 1 为 Prepare 投票, 2 为 Precommit 投票
+func voteDigest(hash []byte, phase uint32, e uint32) []byte {
+	return utils.MessageEncap([][]byte{hash, utils.Uint32ToBytes(phase), utils.Uint32ToBytes(e)})
+}
+
+// signerIndex 参与者在签名位图中的位置: 全局共识为 PID, 片内共识为 SID
+func signerIndex(p *party.HonestParty, pid uint32, isGlobal bool) uint {
+	if isGlobal {
+		return uint(pid)
+	}
+	return uint(pid % p.N)
+}
+
+// verifyAggregate 由签名位图从参与者登记的公钥重建聚合公钥, 要求至少 2f+1 个签名者, 并验证 aggsig 是对 digest 的聚合签名
+func verifyAggregate(p *party.HonestParty, signers []byte, digest []byte, aggsig []byte, isGlobal bool) error {
+	suite := bn256.NewSuite()
+	var signers_bm bitset.BitSet
+	if err := signers_bm.UnmarshalBinary(signers); err != nil {
+		return err
+	}
+	size, first := p.N, p.Snumber*p.N
+	if isGlobal {
+		size, first = p.N*p.M, 0
+	}
+	var pubkeys []kyber.Point
+	for i, e := signers_bm.NextSet(0); e; i, e = signers_bm.NextSet(i + 1) {
+		if i >= uint(size) {
+			return fmt.Errorf("signer %d is not a member of the committee", i)
+		}
+		pubkeys = append(pubkeys, p.PK[first+uint32(i)])
+	}
+	if len(pubkeys) < quorum(p, isGlobal) {
+		return fmt.Errorf("%d signers, need %d", len(pubkeys), quorum(p, isGlobal))
+	}
+	return bls.Verify(suite, bls.AggregatePublicKeys(suite, pubkeys...), digest, aggsig)
+}
+
+// verifyQC 验证 QC: 由对 blockHash||1||epoch 的 Prepare 投票聚合而成, 且签名者是本次共识的 2f+1 个参与者
+func verifyQC(p *party.HonestParty, qc *protobuf.QuorumCert, isGlobal bool) bool {
+	return verifyAggregate(p, qc.Signers, voteDigest(qc.BlockHash, 1, qc.Epoch), qc.Aggsig, isGlobal) == nil
+}
+
+// ChainState 保存一个共识实例(片内或全局)跨 epoch 的区块链状态
+// 同步服务与共识并发访问, 所有方法都持有 mu
+type ChainState struct {
+	mu        sync.RWMutex
+	blocks    map[string]*protobuf.Block // hash -> block
+	committed map[string]bool            // 已提交区块的 hash
+	tip       *protobuf.Block            // 最近提交的区块
+	highQC    *protobuf.QuorumCert       // 见过的最高 QC
+	lockedQC  *protobuf.QuorumCert       // 锁定的 QC
+
+	qcs       map[string]*protobuf.QuorumCert // 区块 hash -> 见过的该区块的 QC
+	commitQCs map[string]*protobuf.QuorumCert // 区块 hash -> 提交该区块的 commitQC, 写入账本后删除
+	pending   []*protobuf.Block               // 已提交但尚未写入账本的区块
+}
+
+// NewChainState 创建只包含已提交创世区块的区块链状态
+func NewChainState() *ChainState {
+	cs := &ChainState{
+		blocks:    make(map[string]*protobuf.Block),
+		committed: make(map[string]bool),
+		tip:       genesis,
+		qcs:       make(map[string]*protobuf.QuorumCert),
+		commitQCs: make(map[string]*protobuf.QuorumCert),
+	}
+	cs.blocks[string(genesisHash)] = genesis
+	cs.committed[string(genesisHash)] = true
+	return cs
+}
+
+// AddBlock 记录收到的区块
+func (cs *ChainState) AddBlock(b *protobuf.Block) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.blocks[string(BlockHash(b))] = b
+}
+
+// GetBlock 按哈希查找区块, 不存在时返回 nil
+func (cs *ChainState) GetBlock(hash []byte) *protobuf.Block {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.blocks[string(hash)]
+}
+
+// Tip 返回最近提交的区块
+func (cs *ChainState) Tip() *protobuf.Block {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.tip
+}
+
+// IsCommitted 判断区块是否已提交
+func (cs *ChainState) IsCommitted(hash []byte) bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.committed[string(hash)]
+}
+
+// Extends 判断区块 b 是否在 hash 所指区块之后的分支上
+func (cs *ChainState) Extends(b *protobuf.Block, hash []byte) bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.extends(b, hash)
+}
+
+func (cs *ChainState) extends(b *protobuf.Block, hash []byte) bool {
+	for b != nil {
+		if bytes.Equal(BlockHash(b), hash) {
+			return true
+		}
+		b = cs.blocks[string(b.Parent)]
+	}
+	return false
+}
+
+// SafeNode 安全规则: 区块必须扩展已提交的区块,
+// 且扩展了 lockedQC 所在分支(安全性), 或其 justify 比 lockedQC 更高且是有效的 QC(活性)
+func (cs *ChainState) SafeNode(p *party.HonestParty, b *protobuf.Block, isGlobal bool) bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if !cs.extends(b, BlockHash(cs.tip)) {
+		return false
+	}
+	if cs.lockedQC == nil {
+		return true
+	}
+	return cs.extends(b, cs.lockedQC.BlockHash) || (qcHigher(b.Justify, cs.lockedQC) && verifyQC(p, b.Justify, isGlobal))
+}
+
+// Commit 按高度顺序提交 b 及其尚未提交的祖先区块, 返回本次提交的区块
+func (cs *ChainState) Commit(b *protobuf.Block) []*protobuf.Block {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	var chain []*protobuf.Block
+	for b != nil && !cs.committed[string(BlockHash(b))] {
+		chain = append(chain, b)
+		b = cs.blocks[string(b.Parent)]
+	}
+	var committed []*protobuf.Block
+	for i := len(chain) - 1; i >= 0; i-- {
+		cs.committed[string(BlockHash(chain[i]))] = true
+		committed = append(committed, chain[i])
+	}
+	if len(committed) > 0 {
+		cs.tip = committed[len(committed)-1]
+	}
+	cs.pending = append(cs.pending, committed...)
+	return committed
+}
+
+// Persist 把上次 Persist 之后提交的区块连同证明其被提交的 QC 和累加器值 acc 写入账本.
+// 两阶段 HotStuff 保存 commitQC; 链式 HotStuff(以及随后代一起提交的祖先区块)没有单独的 commitQC, 保存区块自身的 QC,
+// 其后两个区块的 justify 与它构成三链. 累加器每个 epoch 更新一次, acc 是本 epoch 结束时的值, 本次写入的区块共用
+func (cs *ChainState) Persist(store *db.BlockStore, acc *big.Int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for i, b := range cs.pending {
+		hash := string(BlockHash(b))
+		qc := cs.commitQCs[hash]
+		if qc == nil {
+			qc = cs.qcs[hash]
+		}
+		if err := store.PutBlock([]byte(hash), b, qc, acc); err != nil {
+			cs.pending = cs.pending[i:]
+			return err
+		}
+		delete(cs.commitQCs, hash)
+	}
+	cs.pending = nil
+	return nil
+}
+
+// uncommitted 返回高于 tip 且尚未提交的区块, 随检查点保存
+func (cs *ChainState) uncommitted() []*protobuf.Block {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	var blocks []*protobuf.Block
+	for hash, b := range cs.blocks {
+		if !cs.committed[hash] && b.Height > cs.tip.Height {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// restoreTip 恢复检查点时把账本中最后提交的区块及其 QC 作为 tip
+func (cs *ChainState) restoreTip(b *protobuf.Block, qc *protobuf.QuorumCert) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	hash := string(BlockHash(b))
+	cs.blocks[hash] = b
+	cs.committed[hash] = true
+	cs.tip = b
+	cs.recordQC(qc)
+}
+
+// recordQC 记录区块的 QC, 提交时随区块写入账本, 调用者持有 mu
+func (cs *ChainState) recordQC(qc *protobuf.QuorumCert) {
+	if qc != nil {
+		cs.qcs[string(qc.BlockHash)] = qc
+	}
+}
+
+// RecordCommitQC 记录两阶段 HotStuff 中提交区块的 commitQC, 区块写入账本时代替其 prepareQC
+func (cs *ChainState) RecordCommitQC(qc *protobuf.QuorumCert) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.commitQCs[string(qc.BlockHash)] = qc
+}
+
+// HighQC 返回见过的最高 QC
+func (cs *ChainState) HighQC() *protobuf.QuorumCert {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.highQC
+}
+
+// UpdateHighQC 若 qc 比 highQC 更高则更新 highQC
+func (cs *ChainState) UpdateHighQC(qc *protobuf.QuorumCert) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.recordQC(qc)
+	if qcHigher(qc, cs.highQC) {
+		cs.highQC = qc
+	}
+}
+
+// LockedQC 返回锁定的 QC
+func (cs *ChainState) LockedQC() *protobuf.QuorumCert {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.lockedQC
+}
+
+// UpdateLockedQC 若 qc 比 lockedQC 更高则更新 lockedQC
+func (cs *ChainState) UpdateLockedQC(qc *protobuf.QuorumCert) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.recordQC(qc)
+	if qcHigher(qc, cs.lockedQC) {
+		cs.lockedQC = qc
+	}
+}
+
+// committedTxs 把区块中的交易按顺序拼接
+func committedTxs(blocks []*protobuf.Block) []*protobuf.Transaction {
+	batch := []*protobuf.Transaction{}
+	for _, b := range blocks {
+		batch = append(batch, b.Txs...)
+	}
+	return batch
+}
@@ -0,0 +1,146 @@
+package bft
+
+import (
+	"Chamael/internal/party"
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"testing"
+
+	"github.com/bits-and-blooms/bitset"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+)
+
+// signQC 由 signers 对 blockHash||1||epoch 签名组成片内共识的 QC
+func signQC(signers []*party.HonestParty, hash []byte, e uint32, v uint32) *protobuf.QuorumCert {
+	suite := bn256.NewSuite()
+	var sigs [][]byte
+	signers_bm := bitset.New(uint(signers[0].N))
+	for _, p := range signers {
+		sig, _ := bls.Sign(suite, p.SK, voteDigest(hash, 1, e))
+		sigs = append(sigs, sig)
+		signers_bm.Set(uint(p.SID))
+	}
+	aggSig, _ := bls.AggregateSignatures(suite, sigs...)
+	bm, _ := signers_bm.MarshalBinary()
+	return &protobuf.QuorumCert{Epoch: e, View: v, Aggsig: aggSig, BlockHash: hash, Signers: bm}
+}
+
+// 测试 lockedQC 安全规则: 锁定区块后拒绝与之冲突的提议
+func TestSafeNodeRejectsConflictingProposal(t *testing.T) {
+	ps := newMemoryParties(t, 4, 1, 1, false)
+	p := ps[0]
+	cs := NewChainState()
+
+	// 视图 0 中 Leader 提议区块 A, 副本收到 prepareQC 后锁定 A
+	a := NewBlock(1, genesisHash, 0, []*protobuf.Transaction{{Data: []byte("tx-a")}}, nil)
+	if !cs.SafeNode(p, a, false) {
+		t.Fatalf("expected block A to be safe before locking")
+	}
+	cs.AddBlock(a)
+	cs.UpdateLockedQC(signQC(ps[:3], BlockHash(a), 1, 0))
+
+	// 恶意 Leader 在同一高度提议与 A 冲突的区块 A'
+	conflicting := NewBlock(1, genesisHash, 0, []*protobuf.Transaction{{Data: []byte("tx-a'")}}, nil)
+	if cs.SafeNode(p, conflicting, false) {
+		t.Errorf("expected conflicting block to be refused")
+	}
+	if CheckProposal(p, cs, conflicting, false) {
+		t.Errorf("expected CheckProposal to refuse conflicting block")
+	}
+
+	// 扩展 A 的区块可以投票
+	b := NewBlock(2, BlockHash(a), 1, []*protobuf.Transaction{{Data: []byte("tx-b")}}, cs.LockedQC())
+	if !cs.SafeNode(p, b, false) {
+		t.Errorf("expected block extending the locked block to be safe")
+	}
+
+	// justify 比 lockedQC 更高的冲突区块满足活性规则
+	higher := NewBlock(1, genesisHash, 1, []*protobuf.Transaction{{Data: []byte("tx-c")}}, signQC(ps[1:], genesisHash, 1, 1))
+	if !cs.SafeNode(p, higher, false) {
+		t.Errorf("expected block with higher justify to be safe")
+	}
+}
+
+// 测试伪造或 Leader 自签的 justify 不能用于活性规则
+func TestSafeNodeRejectsForgedJustify(t *testing.T) {
+	ps := newMemoryParties(t, 4, 1, 2, false)
+	p := ps[0]
+	cs := NewChainState()
+	a := NewBlock(1, genesisHash, 0, []*protobuf.Transaction{{Data: []byte("tx-a")}}, nil)
+	cs.AddBlock(a)
+	cs.UpdateLockedQC(signQC(ps[:3], BlockHash(a), 1, 0))
+
+	// 位图声称 3 个签名者, 但只有 Leader 自己签名
+	forged := signQC(ps[1:2], genesisHash, 1, 1)
+	forged.Signers = signQC(ps[1:4], genesisHash, 1, 1).Signers
+	justifies := map[string]*protobuf.QuorumCert{
+		"forged":      forged,
+		"self-signed": signQC(ps[1:2], genesisHash, 1, 1),
+		"other shard": signQC(ps[4:7], genesisHash, 1, 1),
+	}
+	for name, qc := range justifies {
+		b := NewBlock(1, genesisHash, 1, []*protobuf.Transaction{{Data: []byte("tx-c")}}, qc)
+		if cs.SafeNode(p, b, false) {
+			t.Errorf("expected block with %s justify to be refused by SafeNode", name)
+		}
+		if CheckProposal(p, cs, b, false) {
+			t.Errorf("expected block with %s justify to be refused by CheckProposal", name)
+		}
+	}
+
+	valid := NewBlock(1, genesisHash, 1, []*protobuf.Transaction{{Data: []byte("tx-c")}}, signQC(ps[1:4], genesisHash, 1, 1))
+	if !CheckProposal(p, cs, valid, false) {
+		t.Errorf("expected block with a justify signed by 2f+1 parties to be accepted")
+	}
+}
+
+// 测试已提交的区块不会被冲突分支覆盖
+func TestSafeNodeRequiresCommittedPrefix(t *testing.T) {
+	cs := NewChainState()
+	a := NewBlock(1, genesisHash, 0, []*protobuf.Transaction{{Data: []byte("tx-a")}}, nil)
+	cs.AddBlock(a)
+	if committed := cs.Commit(a); len(committed) != 1 || cs.Tip() != a {
+		t.Fatalf("expected block A to be committed as tip")
+	}
+
+	fork := NewBlock(1, genesisHash, 1, []*protobuf.Transaction{{Data: []byte("tx-fork")}}, nil)
+	if cs.SafeNode(nil, fork, false) {
+		t.Errorf("expected block not extending the committed tip to be refused")
+	}
+	if !cs.SafeNode(nil, NewBlock(2, BlockHash(a), 1, nil, nil), false) {
+		t.Errorf("expected block extending the committed tip to be safe")
+	}
+}
+
+// 测试区块哈希绑定 justify 的 epoch 和 view
+func TestBlockHashCommitsToJustify(t *testing.T) {
+	batch := []*protobuf.Transaction{{Data: []byte("tx-a")}}
+	a := NewBlock(2, genesisHash, 0, batch, &protobuf.QuorumCert{Epoch: 1, View: 0, BlockHash: genesisHash})
+	for _, qc := range []*protobuf.QuorumCert{{Epoch: 1, View: 1, BlockHash: genesisHash}, {Epoch: 2, View: 0, BlockHash: genesisHash}} {
+		if bytes.Equal(BlockHash(a), BlockHash(NewBlock(2, genesisHash, 0, batch, qc))) {
+			t.Errorf("expected a justify of epoch %d view %d to change the block hash", qc.Epoch, qc.View)
+		}
+	}
+}
+
+// 客户端只用区块的 TxRoot 和证明验证单笔交易
+func TestBlockTxInclusionProof(t *testing.T) {
+	batch := []*protobuf.Transaction{{Data: []byte("tx-a")}, {Data: []byte("tx-b")}, {Data: []byte("tx-c")}}
+	b := NewBlock(1, genesisHash, 0, batch, nil)
+	for i, tx := range batch {
+		proof, err := ProveBlockTx(b, i)
+		if err != nil {
+			t.Fatalf("failed to prove transaction %d: %v", i, err)
+		}
+		if !VerifyBlockTx(b.TxRoot, tx, proof) {
+			t.Errorf("inclusion proof of transaction %d did not verify", i)
+		}
+		if VerifyBlockTx(b.TxRoot, &protobuf.Transaction{Data: []byte("tx-d")}, proof) {
+			t.Errorf("inclusion proof of transaction %d verified another transaction", i)
+		}
+	}
+	if _, err := ProveBlockTx(b, len(batch)); err == nil {
+		t.Error("expected an error for a transaction outside the block")
+	}
+}
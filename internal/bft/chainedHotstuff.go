@@ -0,0 +1,250 @@
+package bft
+
+import (
+	"Chamael/internal/party"
+	"Chamael/pkg/core"
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"fmt"
+
+	"github.com/bits-and-blooms/bitset"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"Generic":      func() proto.Message { return new(protobuf.Generic) },
+		"Generic_Vote": func() proto.Message { return new(protobuf.Generic_Vote) },
+	})
+}
+
+// UseChainedHotStuff 为 true 时 Kronos 的片内共识使用链式 HotStuff
+var UseChainedHotStuff = false
+
+// ChainedHotStuff 保存链式 HotStuff 跨 epoch 的状态
+// 每个 epoch 只执行一轮 Generic 阶段, 本轮形成的 QC 同时作为下一个区块的 prepareQC,
+// 区块与其父区块、祖父区块构成连续三链时提交最早的区块
+type ChainedHotStuff struct {
+	p        *party.HonestParty
+	isGlobal bool
+
+	cs    *ChainState       // highQC 即 genericQC, lockedQC 由两链规则更新
+	views map[string]uint32 // 区块被提议时的视图, 用于组成 QC
+}
+
+// NewChainedHotStuff 在区块链状态 cs 之上创建链式 HotStuff 实例
+func NewChainedHotStuff(p *party.HonestParty, cs *ChainState, isGlobal bool) *ChainedHotStuff {
+	return &ChainedHotStuff{
+		p:        p,
+		isGlobal: isGlobal,
+		cs:       cs,
+		views:    make(map[string]uint32),
+	}
+}
+
+// update 收到新区块 b* 后更新 genericQC / lockedQC, 返回本次提交的区块
+// 区块的 parent 总是其 justify 所指区块, 因此连续三链要求高度连续
+func (c *ChainedHotStuff) update(b *protobuf.Block) []*protobuf.Block {
+	c.cs.UpdateHighQC(b.Justify)
+	b2 := c.cs.GetBlock(b.Justify.GetBlockHash())
+	if b2 == nil || b2.Justify == nil {
+		return nil
+	}
+	// 两链: 锁定 b2 的 justify
+	c.cs.UpdateLockedQC(b2.Justify)
+	b1 := c.cs.GetBlock(b2.Justify.BlockHash)
+	if b1 == nil || b1.Justify == nil {
+		return nil
+	}
+	b0 := c.cs.GetBlock(b1.Justify.BlockHash)
+	if b0 == nil || b2.Height != b1.Height+1 || b1.Height != b0.Height+1 {
+		return nil
+	}
+	// 三链: 提交 b0 及其尚未提交的祖先
+	return c.cs.Commit(b0)
+}
+
+// Generic_BroadCast Leader 收集上一高度区块的 2f+1 条 Generic_Vote 组成 QC, 以此为 justify 广播新区块
+func Generic_BroadCast(c *ChainedHotStuff, pm *Pacemaker, txs []*protobuf.Transaction) bool {
+	p := c.p
+	suite := bn256.NewSuite()
+	justify := pm.HighQC()
+
+	// 视图 0 中等待投票组成上一区块的 QC; 视图切换后的新 Leader 直接使用 Timeout 消息中最高的 QC
+	if pm.epoch > 1 && pm.View() == 0 {
+		seen := make(map[string]map[uint32]bool)
+		signatures := make(map[string][][]byte)
+		signers_bm := make(map[string]*bitset.BitSet)
+	Loop:
+		for {
+			select {
+			case m := <-p.GetMessage("Generic_Vote", pm.ID()):
+				raw, err := core.Decapsulation("Generic_Vote", m)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				payload := raw.(*protobuf.Generic_Vote)
+				key := string(payload.BlockHash)
+				b := c.cs.GetBlock(payload.BlockHash)
+				if b == nil || !inCommittee(p, m.Sender, c.isGlobal) {
+					continue
+				}
+				if seen[key] == nil {
+					seen[key] = make(map[uint32]bool)
+					signers_bm[key] = bitset.New(uint(p.N))
+				}
+				if seen[key][m.Sender] {
+					continue
+				}
+				if bls.Verify(suite, p.PK[m.Sender], voteDigest(payload.BlockHash, 1, b.Height), payload.Sig) != nil {
+					fmt.Println("Generic_Vote verification failed(Malicious Participator)")
+					continue
+				}
+				seen[key][m.Sender] = true
+				signatures[key] = append(signatures[key], payload.Sig)
+				signers_bm[key].Set(signerIndex(p, m.Sender, c.isGlobal))
+				if len(seen[key]) >= quorum(p, c.isGlobal) {
+					aggSig, _ := bls.AggregateSignatures(suite, signatures[key]...)
+					signers, _ := signers_bm[key].MarshalBinary()
+					// QC 的 epoch 是被认证区块所在的 epoch, Generic_Process 只接受高度等于当前 epoch 的区块,
+					// 因此与区块高度相同, qcHigher 和 verifyQC 在两种模式下含义一致
+					justify = &protobuf.QuorumCert{
+						Epoch:     b.Height,
+						View:      c.views[key],
+						Aggsig:    aggSig,
+						BlockHash: payload.BlockHash,
+						Signers:   signers,
+					}
+					break Loop
+				}
+			case <-pm.TimerC():
+				pm.OnLocalTimeout()
+			case m := <-pm.TimeoutMessages():
+				if pm.OnTimeout(m) {
+					return false
+				}
+			}
+		}
+	}
+
+	parent := genesisHash
+	if justify != nil {
+		parent = justify.BlockHash
+	}
+	GenericMessage := core.Encapsulation("Generic", pm.ID(), p.PID, &protobuf.Generic{
+		Block: NewBlock(pm.epoch, parent, p.PID, txs, justify),
+	})
+	hsBroadcast(p, GenericMessage, c.isGlobal)
+	return true
+}
+
+// Generic_Process 接收当前视图 Leader 的区块, 检查安全规则后投票给下一高度的 Leader
+// 接受区块后返回本次提交的交易和 true, 视图切换时返回 false
+func Generic_Process(c *ChainedHotStuff, pm *Pacemaker) ([]*protobuf.Transaction, bool) {
+	p := c.p
+	suite := bn256.NewSuite()
+	for {
+		select {
+		case m := <-p.GetMessage("Generic", pm.ID()):
+			if m.Sender != pm.Leader() {
+				continue
+			}
+			raw, err := core.Decapsulation("Generic", m)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			payload := raw.(*protobuf.Generic)
+			b := payload.Block
+			if b == nil || b.Height != pm.epoch || b.Proposer != m.Sender {
+				continue
+			}
+			if !VerifyBlockTxs(b) {
+				fmt.Println("Generic block txRoot mismatch(Malicious Leader)", b.Height)
+				return nil, false
+			}
+			if b.Justify == nil {
+				if !bytes.Equal(b.Parent, genesisHash) {
+					fmt.Println("Generic block without justify(Malicious Leader)")
+					return nil, false
+				}
+			} else if !bytes.Equal(b.Parent, b.Justify.BlockHash) || !verifyQC(p, b.Justify, c.isGlobal) {
+				fmt.Println("Generic justify verification failed(Malicious Leader)")
+				return nil, false
+			}
+			// 父区块未知说明本节点落后, 同步缺失的区块并按顺序更新三链状态
+			var synced []*protobuf.Block
+			if c.cs.GetBlock(b.Parent) == nil && b.Justify != nil {
+				for _, sb := range SyncTo(p, c.cs, pm.epoch, b.Justify, syncPeers(p, m.Sender, c.isGlobal), c.isGlobal) {
+					synced = append(synced, c.update(sb)...)
+				}
+			}
+			if c.cs.GetBlock(b.Parent) == nil {
+				fmt.Println("Generic block with unknown parent", b.Height)
+				return nil, false
+			}
+			if !c.cs.SafeNode(p, b, c.isGlobal) {
+				fmt.Println("Generic block conflicts with lockedQC(Malicious Leader)", b.Height)
+				return nil, false
+			}
+			hash := BlockHash(b)
+			c.cs.AddBlock(b)
+			c.views[string(hash)] = pm.View()
+			committed := committedTxs(append(synced, c.update(b)...))
+
+			if !pm.TimedOut() {
+				sig, _ := bls.Sign(suite, p.SK, voteDigest(hash, 1, b.Height)) //sign(blockHash||1||height)
+				Generic_VoteMessage := core.Encapsulation("Generic_Vote", hsID(pm.epoch+1, 0), p.PID, &protobuf.Generic_Vote{
+					BlockHash: hash,
+					Sig:       sig,
+				})
+				p.Send(Generic_VoteMessage, leaderOf(p, pm.epoch+1, 0, c.isGlobal))
+			}
+			return committed, true
+		case <-pm.TimerC():
+			pm.OnLocalTimeout()
+		case m := <-pm.TimeoutMessages():
+			if pm.OnTimeout(m) {
+				return nil, false
+			}
+		}
+	}
+}
+
+// ChainedHotStuffProcess 执行高度为 epoch 的一轮链式 HotStuff, 把本轮提交的交易(可能为空)放入输出通道
+func ChainedHotStuffProcess(c *ChainedHotStuff, epoch int, inputChannel chan []*protobuf.Transaction, outputChannel chan []*protobuf.Transaction) {
+	e := uint32(epoch)
+	var txs []*protobuf.Transaction
+
+	pm := NewPacemaker(c.p, e, c.isGlobal)
+	pm.UpdateHighQC(c.cs.HighQC())
+	defer pm.Stop()
+
+	for {
+		if pm.IsLeader() && txs == nil {
+			if pm.View() == 0 {
+				txs = <-inputChannel
+			} else {
+				select {
+				case txs = <-inputChannel:
+				default:
+					txs = []*protobuf.Transaction{}
+				}
+			}
+		}
+		pm.StartTimer()
+		if pm.IsLeader() && !Generic_BroadCast(c, pm, txs) {
+			pm.WaitViewChange()
+			continue
+		}
+		committed, ok := Generic_Process(c, pm)
+		if ok {
+			outputChannel <- committed
+			return
+		}
+		pm.WaitViewChange()
+	}
+}
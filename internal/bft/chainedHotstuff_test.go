@@ -0,0 +1,86 @@
+package bft
+
+import (
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"testing"
+)
+
+// chainedBlock 以 parent 的 QC 为 justify 创建高度为 height 的区块, parent 为 nil 时扩展创世区块
+func chainedBlock(cs *ChainState, height uint32, parent *protobuf.Block) *protobuf.Block {
+	var justify *protobuf.QuorumCert
+	parentHash := genesisHash
+	if parent != nil {
+		parentHash = BlockHash(parent)
+		justify = &protobuf.QuorumCert{Epoch: parent.Height, BlockHash: parentHash}
+	}
+	b := NewBlock(height, parentHash, 0, []*protobuf.Transaction{{Data: []byte{byte(height)}}}, justify)
+	cs.AddBlock(b)
+	return b
+}
+
+// 测试链式 HotStuff 的两链锁定和三链提交规则
+func TestChainedHotStuffThreeChainCommit(t *testing.T) {
+	cs := NewChainState()
+	c := NewChainedHotStuff(nil, cs, false)
+
+	b1 := chainedBlock(cs, 1, nil)
+	b2 := chainedBlock(cs, 2, b1)
+	if committed := c.update(b2); len(committed) != 0 {
+		t.Fatalf("expected no commit with a one-chain, got %d blocks", len(committed))
+	}
+	if !bytes.Equal(cs.HighQC().GetBlockHash(), BlockHash(b1)) || cs.LockedQC() != nil {
+		t.Fatalf("expected the QC of b1 to become the genericQC without locking")
+	}
+
+	// 两链 b1 <- b2 <- b3: 锁定 b1, 尚不提交
+	b3 := chainedBlock(cs, 3, b2)
+	if committed := c.update(b3); len(committed) != 0 {
+		t.Fatalf("expected no commit with a two-chain, got %d blocks", len(committed))
+	}
+	if !bytes.Equal(cs.LockedQC().GetBlockHash(), BlockHash(b1)) {
+		t.Errorf("expected b1 to be locked by the two-chain")
+	}
+
+	// 三链 b1 <- b2 <- b3 <- b4: 锁定 b2, 提交 b1
+	b4 := chainedBlock(cs, 4, b3)
+	committed := c.update(b4)
+	if len(committed) != 1 || committed[0] != b1 || cs.Tip() != b1 {
+		t.Fatalf("expected the three-chain to commit b1, got %d blocks", len(committed))
+	}
+	if !bytes.Equal(cs.LockedQC().GetBlockHash(), BlockHash(b2)) {
+		t.Errorf("expected b2 to be locked after the three-chain")
+	}
+
+	// 与锁定的 b2 冲突的区块不满足安全规则
+	fork := NewBlock(3, BlockHash(b1), 1, []*protobuf.Transaction{{Data: []byte("fork")}}, nil)
+	if cs.SafeNode(nil, fork, false) {
+		t.Errorf("expected a block conflicting with the locked b2 to be refused")
+	}
+}
+
+// 测试高度不连续的链(中间有视图切换跳过的高度)不满足三链规则, 之后的连续三链一并提交祖先
+func TestChainedHotStuffRequiresConsecutiveHeights(t *testing.T) {
+	cs := NewChainState()
+	c := NewChainedHotStuff(nil, cs, false)
+
+	b1 := chainedBlock(cs, 1, nil)
+	b2 := chainedBlock(cs, 2, b1)
+	b4 := chainedBlock(cs, 4, b2)
+	b5 := chainedBlock(cs, 5, b4)
+	b6 := chainedBlock(cs, 6, b5)
+	for _, b := range []*protobuf.Block{b2, b4, b5, b6} {
+		if committed := c.update(b); len(committed) != 0 {
+			t.Fatalf("expected no commit at height %d across the gap, got %d blocks", b.Height, len(committed))
+		}
+	}
+	if !bytes.Equal(cs.LockedQC().GetBlockHash(), BlockHash(b4)) {
+		t.Errorf("expected b4 to be locked")
+	}
+
+	// b4 <- b5 <- b6 连续, 提交 b4 及其祖先 b1, b2
+	committed := c.update(chainedBlock(cs, 7, b6))
+	if len(committed) != 3 || committed[0] != b1 || committed[1] != b2 || committed[2] != b4 {
+		t.Fatalf("expected b1, b2 and b4 to be committed in order, got %d blocks", len(committed))
+	}
+}
@@ -0,0 +1,265 @@
+package bft
+
+import (
+	"Chamael/internal/party"
+	"Chamael/pkg/core"
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"fmt"
+	"time"
+
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"New_View": func() proto.Message { return new(protobuf.New_View) },
+		"Timeout":  func() proto.Message { return new(protobuf.Timeout) },
+	})
+}
+
+// ViewTimeout 视图 0 的超时时间,此后每切换一次视图超时时间翻倍
+var ViewTimeout = 5 * time.Second
+
+// maxBackoff 超时时间最多翻倍的次数
+const maxBackoff = 6
+
+// Pacemaker 负责单个 epoch 内的视图推进:视图计时、Timeout 消息的收集与 Leader 轮换
+type Pacemaker struct {
+	p        *party.HonestParty
+	epoch    uint32
+	view     uint32
+	isGlobal bool
+
+	timer    *time.Timer
+	timedOut bool                         // 本视图是否已发出 Timeout 消息
+	hasTC    bool                         // 本视图是否已收齐 2f+1 条 Timeout 消息
+	timeouts map[uint32]*protobuf.Timeout // 本视图收到的 Timeout 消息, sender -> Timeout
+	highQC   *protobuf.QuorumCert         // 本节点见过的最高 QC
+}
+
+// NewPacemaker 创建 epoch e 的 Pacemaker,从视图 0 开始
+func NewPacemaker(p *party.HonestParty, e uint32, isGlobal bool) *Pacemaker {
+	return &Pacemaker{
+		p:        p,
+		epoch:    e,
+		view:     0,
+		isGlobal: isGlobal,
+		timeouts: make(map[uint32]*protobuf.Timeout),
+	}
+}
+
+// hsID HotStuff 消息的 ID: epoch||view
+func hsID(e uint32, v uint32) []byte {
+	return utils.MessageEncap([][]byte{utils.Uint32ToBytes(e), utils.Uint32ToBytes(v)})
+}
+
+// quorum 返回 2f+1, 全局共识中 f 按全部 N*M 个节点计算
+func quorum(p *party.HonestParty, isGlobal bool) int {
+	if isGlobal {
+		F := (int(p.N*p.M) - 1) / 3
+		return 2*F + 1
+	}
+	return 2*int(p.F) + 1
+}
+
+// weakQuorum 返回 f+1
+func weakQuorum(p *party.HonestParty, isGlobal bool) int {
+	if isGlobal {
+		F := (int(p.N*p.M) - 1) / 3
+		return F + 1
+	}
+	return int(p.F) + 1
+}
+
+// hsBroadcast 全局共识时全局广播,片内共识时片内广播
+func hsBroadcast(p *party.HonestParty, m *protobuf.Message, isGlobal bool) {
+	if isGlobal {
+		p.Broadcast(m)
+	} else {
+		p.Intra_Broadcast(m)
+	}
+}
+
+// inCommittee 判断 pid 是否属于本次共识的参与者
+func inCommittee(p *party.HonestParty, pid uint32, isGlobal bool) bool {
+	if isGlobal {
+		return pid < p.N*p.M
+	}
+	return pid/p.N == p.Snumber
+}
+
+// leaderOf 返回 (e, v) 的 Leader 的 PID
+func leaderOf(p *party.HonestParty, e uint32, v uint32, isGlobal bool) uint32 {
+	if isGlobal {
+		return (e - 1 + v) % (p.N * p.M)
+	}
+	return p.Snumber*p.N + (e-1+v)%p.N
+}
+
+// qcHigher 判断 a 是否比 b 更高(先比较 epoch,再比较 view)
+func qcHigher(a *protobuf.QuorumCert, b *protobuf.QuorumCert) bool {
+	if a == nil {
+		return false
+	}
+	if b == nil {
+		return true
+	}
+	if a.Epoch != b.Epoch {
+		return a.Epoch > b.Epoch
+	}
+	return a.View > b.View
+}
+
+// timeoutDigest Timeout 消息的签名内容: epoch||view||highQC.epoch||highQC.view
+func timeoutDigest(e uint32, v uint32, qc *protobuf.QuorumCert) []byte {
+	return utils.MessageEncap([][]byte{utils.Uint32ToBytes(e), utils.Uint32ToBytes(v), utils.Uint32ToBytes(qc.GetEpoch()), utils.Uint32ToBytes(qc.GetView())})
+}
+
+// View 返回当前视图编号
+func (pm *Pacemaker) View() uint32 {
+	return pm.view
+}
+
+// ID 返回当前 (epoch, view) 的消息 ID
+func (pm *Pacemaker) ID() []byte {
+	return hsID(pm.epoch, pm.view)
+}
+
+// Leader 返回当前视图 Leader 的 PID
+// 全局共识选择 PID = (e-1+v)%(N*M), 片内共识选择 SID = (e-1+v)%N
+func (pm *Pacemaker) Leader() uint32 {
+	return leaderOf(pm.p, pm.epoch, pm.view, pm.isGlobal)
+}
+
+// IsLeader 判断自己是否是当前视图的 Leader
+func (pm *Pacemaker) IsLeader() bool {
+	return pm.Leader() == pm.p.PID
+}
+
+// StartTimer 为当前视图启动计时器
+func (pm *Pacemaker) StartTimer() {
+	backoff := pm.view
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	if pm.timer != nil {
+		pm.timer.Stop()
+	}
+	pm.timer = time.NewTimer(ViewTimeout << backoff)
+}
+
+// Stop 停止计时器
+func (pm *Pacemaker) Stop() {
+	if pm.timer != nil {
+		pm.timer.Stop()
+	}
+}
+
+// TimerC 返回当前视图计时器的通道
+func (pm *Pacemaker) TimerC() <-chan time.Time {
+	return pm.timer.C
+}
+
+// TimeoutMessages 返回当前视图 Timeout 消息的通道
+func (pm *Pacemaker) TimeoutMessages() chan *protobuf.Message {
+	return pm.p.GetMessage("Timeout", pm.ID())
+}
+
+// TimedOut 判断本节点是否已在当前视图超时
+func (pm *Pacemaker) TimedOut() bool {
+	return pm.timedOut
+}
+
+// HighQC 返回本节点见过的最高 QC
+func (pm *Pacemaker) HighQC() *protobuf.QuorumCert {
+	return pm.highQC
+}
+
+// UpdateHighQC 若 qc 比 highQC 更高则更新 highQC
+func (pm *Pacemaker) UpdateHighQC(qc *protobuf.QuorumCert) {
+	if qcHigher(qc, pm.highQC) {
+		pm.highQC = qc
+	}
+}
+
+// OnLocalTimeout 本地计时器超时:对 epoch||view||highQC 签名并广播 Timeout 消息,每个视图只广播一次
+func (pm *Pacemaker) OnLocalTimeout() {
+	if pm.timedOut {
+		return
+	}
+	pm.timedOut = true
+	fmt.Println("View", pm.epoch, pm.view, "timeout, leader:", pm.Leader())
+
+	suite := bn256.NewSuite()
+	sig, _ := bls.Sign(suite, pm.p.SK, timeoutDigest(pm.epoch, pm.view, pm.highQC))
+	TimeoutMessage := core.Encapsulation("Timeout", pm.ID(), pm.p.PID, &protobuf.Timeout{
+		Epoch:  pm.epoch,
+		View:   pm.view,
+		HighQC: pm.highQC,
+		Sig:    sig,
+	})
+	hsBroadcast(pm.p, TimeoutMessage, pm.isGlobal)
+}
+
+// OnTimeout 处理一条 Timeout 消息
+// 收到 f+1 条时自己也发出 Timeout,收到 2f+1 条时返回 true,表示可以进入下一视图
+func (pm *Pacemaker) OnTimeout(m *protobuf.Message) bool {
+	suite := bn256.NewSuite()
+	raw, err := core.Decapsulation("Timeout", m)
+	if err != nil {
+		fmt.Println(err)
+		return pm.hasTC
+	}
+	payload := raw.(*protobuf.Timeout)
+	if payload.Epoch != pm.epoch || payload.View != pm.view || !inCommittee(pm.p, m.Sender, pm.isGlobal) {
+		return pm.hasTC
+	}
+	err = bls.Verify(suite, pm.p.PK[m.Sender], timeoutDigest(payload.Epoch, payload.View, payload.HighQC), payload.Sig)
+	if err != nil {
+		fmt.Println("Timeout signature verification failed:", err)
+		return pm.hasTC
+	}
+	if _, ok := pm.timeouts[m.Sender]; !ok {
+		pm.timeouts[m.Sender] = payload
+	}
+
+	if len(pm.timeouts) >= weakQuorum(pm.p, pm.isGlobal) {
+		pm.OnLocalTimeout()
+	}
+	if len(pm.timeouts) >= quorum(pm.p, pm.isGlobal) {
+		pm.hasTC = true
+	}
+	return pm.hasTC
+}
+
+// WaitViewChange 等待收齐 2f+1 条 Timeout 消息后进入下一视图
+func (pm *Pacemaker) WaitViewChange() {
+	pm.OnLocalTimeout()
+	for !pm.hasTC {
+		m := <-pm.TimeoutMessages()
+		pm.OnTimeout(m)
+	}
+	pm.AdvanceView()
+}
+
+// AdvanceView 进入下一视图,新 Leader 从收到的 Timeout 消息中选取最高的有效 QC
+func (pm *Pacemaker) AdvanceView() {
+	for sender, t := range pm.timeouts {
+		if !qcHigher(t.HighQC, pm.highQC) {
+			continue
+		}
+		if !verifyQC(pm.p, t.HighQC, pm.isGlobal) {
+			fmt.Println("Invalid highQC in Timeout from", sender, "(Malicious Participator)")
+			continue
+		}
+		pm.UpdateHighQC(t.HighQC)
+	}
+	pm.view++
+	pm.timedOut = false
+	pm.hasTC = false
+	pm.timeouts = make(map[uint32]*protobuf.Timeout)
+	fmt.Println("New view", pm.epoch, pm.view, "leader:", pm.Leader())
+}
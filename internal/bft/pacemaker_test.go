@@ -0,0 +1,28 @@
+package bft
+
+import (
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"testing"
+)
+
+// 测试视图切换时忽略 Timeout 消息中伪造的 highQC
+func TestAdvanceViewIgnoresForgedHighQC(t *testing.T) {
+	ps := newMemoryParties(t, 4, 1, 1, false)
+	pm := NewPacemaker(ps[0], 1, false)
+	valid := signQC(ps[:3], []byte("block-a"), 1, 0)
+	pm.UpdateHighQC(valid)
+
+	// 自签的 QC 比 highQC 更高, 但签名者不足 2f+1
+	pm.timeouts[1] = &protobuf.Timeout{Epoch: 1, View: 0, HighQC: signQC(ps[1:2], []byte("block-b"), 1, 1)}
+	pm.AdvanceView()
+	if !bytes.Equal(pm.HighQC().GetBlockHash(), valid.BlockHash) {
+		t.Fatalf("expected the forged highQC to be ignored")
+	}
+
+	pm.timeouts[2] = &protobuf.Timeout{Epoch: 1, View: 1, HighQC: signQC(ps[1:4], []byte("block-c"), 1, 1)}
+	pm.AdvanceView()
+	if !bytes.Equal(pm.HighQC().GetBlockHash(), []byte("block-c")) {
+		t.Errorf("expected the highQC signed by 2f+1 parties to be adopted")
+	}
+}
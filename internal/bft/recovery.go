@@ -0,0 +1,278 @@
+package bft
+
+import (
+	"Chamael/pkg/crypto"
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/txs"
+	"Chamael/pkg/utils"
+	"Chamael/pkg/utils/db"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 检查点在账本 meta 表中的键
+const (
+	metaEpoch     = "epoch"     // 最后完成的 epoch
+	metaLockedQC  = "lockedQC"  // 锁定的 QC
+	metaHighQC    = "highQC"    // 见过的最高 QC
+	metaBlocks    = "blocks"    // 已收到但尚未提交的区块
+	metaPool      = "pool"      // 跨片交易池
+	metaState     = "state"     // 分片账户状态
+	metaAcc       = "acc"       // 累加器的值
+	metaAccAdded  = "accAdded"  // 加上 epoch 后为该 epoch 并入累加器的元素, 重启时依次重新累加
+	metaFinished  = "finished"  // 收齐输入分片结果、尚未入账的跨片交易
+	metaShardAccs = "shardAccs" // 每个分片上一次被接受的累加器值
+)
+
+// Checkpoint 节点重启时从账本恢复的状态
+type Checkpoint struct {
+	Epoch uint32 // 最后完成的 epoch, 重启后从 Epoch+1 继续
+	Chain *ChainState
+	Pool  *TransactionPool
+	State *txs.State          // 旧检查点中没有账户状态时为 nil
+	Acc   *crypto.Accumulator // 由各 epoch 保存的元素重新累加, 保留全部历史
+
+	Finished  []*protobuf.Transaction // 收齐输入分片结果、尚未入账的跨片交易, 重启后重新提议
+	ShardAccs map[int][]byte          // 每个分片上一次被接受的累加器值, 旧检查点中没有时为 nil
+}
+
+// SaveCheckpoint 在 epoch e 结束时把本 epoch 提交的区块写入账本, 并保存重启所需的其他状态,
+// added 是本 epoch 并入累加器 acc 的元素, shardAccs 是每个分片上一次被接受的累加器值,
+// finished 是收齐了各输入分片结果、尚未入账的跨片交易
+func SaveCheckpoint(store *db.BlockStore, e uint32, cs *ChainState, pool *TransactionPool, state *txs.State, acc *crypto.Accumulator, added []string, shardAccs map[int][]byte, finished []*protobuf.Transaction) error {
+	var value *big.Int
+	if acc != nil {
+		value = acc.Value()
+	}
+	if err := cs.Persist(store, value); err != nil {
+		return err
+	}
+
+	kv := make(map[string][]byte)
+	kv[metaEpoch] = utils.Uint32ToBytes(e)
+	for key, qc := range map[string]*protobuf.QuorumCert{metaLockedQC: cs.LockedQC(), metaHighQC: cs.HighQC()} {
+		var data []byte
+		if qc != nil {
+			var err error
+			if data, err = proto.Marshal(qc); err != nil {
+				return err
+			}
+		}
+		kv[key] = data
+	}
+
+	// 锁定的区块可能尚未提交, 链式模式下还有等待三链的区块, 一并保存
+	var blocks []proto.Message
+	for _, b := range cs.uncommitted() {
+		blocks = append(blocks, b)
+	}
+	var err error
+	if kv[metaBlocks], err = marshalList(blocks); err != nil {
+		return err
+	}
+	var completed []proto.Message
+	for _, tx := range finished {
+		completed = append(completed, tx)
+	}
+	if kv[metaFinished], err = marshalList(completed); err != nil {
+		return err
+	}
+
+	if kv[metaPool], err = pool.Snapshot(); err != nil {
+		return err
+	}
+	if kv[metaState], err = state.Snapshot(); err != nil {
+		return err
+	}
+	if acc != nil {
+		kv[metaAcc] = value.Bytes()
+	}
+	if kv[metaShardAccs], err = json.Marshal(shardAccs); err != nil {
+		return err
+	}
+	// 累加器的元素按 epoch 分开保存, 每个 epoch 只写入新加入的元素
+	if len(added) > 0 {
+		elements := make([][]byte, len(added))
+		for i, v := range added {
+			elements[i] = []byte(v)
+		}
+		if kv[accAddedKey(e)], err = json.Marshal(elements); err != nil {
+			return err
+		}
+	}
+	return store.PutMeta(kv)
+}
+
+// LoadCheckpoint 从账本恢复节点状态, 账本中没有检查点时返回 nil
+func LoadCheckpoint(store *db.BlockStore) (*Checkpoint, error) {
+	epoch, err := store.GetMeta(metaEpoch)
+	if err != nil || epoch == nil {
+		return nil, err
+	}
+	cp := &Checkpoint{
+		Epoch: utils.BytesToUint32(epoch),
+		Chain: NewChainState(),
+	}
+	cs := cp.Chain
+
+	// 最后提交的区块作为新的 tip, 更早的区块只保存在账本中
+	height, err := store.LastHeight()
+	if err != nil {
+		return nil, err
+	}
+	if height > 0 {
+		r, err := store.GetBlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		cs.restoreTip(r.Block, r.QC)
+	}
+
+	data, err := store.GetMeta(metaBlocks)
+	if err != nil {
+		return nil, err
+	}
+	var blocks []*protobuf.Block
+	err = unmarshalList(data, func() proto.Message {
+		b := new(protobuf.Block)
+		blocks = append(blocks, b)
+		return b
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pending blocks: %v", err)
+	}
+	for _, b := range blocks {
+		cs.AddBlock(b)
+	}
+
+	for key, update := range map[string]func(*protobuf.QuorumCert){metaLockedQC: cs.UpdateLockedQC, metaHighQC: cs.UpdateHighQC} {
+		data, err := store.GetMeta(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			continue
+		}
+		qc := new(protobuf.QuorumCert)
+		if err := proto.Unmarshal(data, qc); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %v", key, err)
+		}
+		update(qc)
+	}
+
+	data, err = store.GetMeta(metaPool)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Pool, err = RestoreTransactionPool(data); err != nil {
+		return nil, fmt.Errorf("failed to decode transaction pool: %v", err)
+	}
+
+	data, err = store.GetMeta(metaState)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if cp.State, err = txs.RestoreState(data); err != nil {
+			return nil, fmt.Errorf("failed to decode shard state: %v", err)
+		}
+	}
+
+	data, err = store.GetMeta(metaAcc)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		if cp.Acc, err = restoreAccumulator(store, cp.Epoch, new(big.Int).SetBytes(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err = store.GetMeta(metaShardAccs)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &cp.ShardAccs); err != nil {
+			return nil, fmt.Errorf("failed to decode shard accumulators: %v", err)
+		}
+	}
+
+	data, err = store.GetMeta(metaFinished)
+	if err != nil {
+		return nil, err
+	}
+	err = unmarshalList(data, func() proto.Message {
+		tx := new(protobuf.Transaction)
+		cp.Finished = append(cp.Finished, tx)
+		return tx
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode finished transactions: %v", err)
+	}
+	return cp, nil
+}
+
+// accAddedKey epoch e 并入累加器的元素在 meta 表中的键
+func accAddedKey(e uint32) string {
+	return fmt.Sprintf("%s/%d", metaAccAdded, e)
+}
+
+// restoreAccumulator 按 epoch 顺序重新累加第 1 到 epoch 个 epoch 保存的元素, 结果必须等于检查点中的值 value
+func restoreAccumulator(store *db.BlockStore, epoch uint32, value *big.Int) (*crypto.Accumulator, error) {
+	var set []string
+	for e := uint32(1); e <= epoch; e++ {
+		data, err := store.GetMeta(accAddedKey(e))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			continue
+		}
+		var elements [][]byte
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return nil, fmt.Errorf("failed to decode accumulator elements of epoch %d: %v", e, err)
+		}
+		for _, v := range elements {
+			set = append(set, string(v))
+		}
+	}
+	acc := crypto.NewAccumulator(set, crypto.HashToPrimeFromSha256, AccSetup)
+	if acc.Value().Cmp(value) != 0 {
+		return nil, fmt.Errorf("accumulator elements do not add up to the saved value")
+	}
+	return acc, nil
+}
+
+// marshalList 把一组消息编码为 JSON 数组, 每个元素是一条消息的 protobuf 编码
+func marshalList(msgs []proto.Message) ([]byte, error) {
+	list := make([][]byte, 0, len(msgs))
+	for _, m := range msgs {
+		data, err := proto.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, data)
+	}
+	return json.Marshal(list)
+}
+
+// unmarshalList 解码 marshalList 的结果, 每个元素解码到 next 返回的新消息中
+func unmarshalList(data []byte, next func() proto.Message) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var list [][]byte
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, data := range list {
+		if err := proto.Unmarshal(data, next()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
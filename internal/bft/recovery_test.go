@@ -0,0 +1,99 @@
+package bft
+
+import (
+	"Chamael/pkg/crypto"
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/txs"
+	"Chamael/pkg/utils/db"
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 测试检查点的保存与恢复: 提交高度、lockedQC、未提交区块、交易池、账户状态、累加器和已完成的跨片交易
+func TestCheckpointRecovery(t *testing.T) {
+	store, err := db.OpenBlockStore(filepath.Join(t.TempDir(), "blocks.db"))
+	if err != nil {
+		t.Fatalf("failed to open block store: %v", err)
+	}
+	defer store.Close()
+
+	if cp, err := LoadCheckpoint(store); err != nil || cp != nil {
+		t.Fatalf("expected no checkpoint in empty store, got %v err %v", cp, err)
+	}
+
+	cs := NewChainState()
+	a := NewBlock(1, genesisHash, 0, []*protobuf.Transaction{{Data: []byte("tx-a")}}, nil)
+	cs.AddBlock(a)
+	cs.UpdateLockedQC(&protobuf.QuorumCert{Epoch: 1, BlockHash: BlockHash(a)})
+	cs.Commit(a)
+	// b 已被锁定但尚未提交
+	b := NewBlock(2, BlockHash(a), 1, []*protobuf.Transaction{{Data: []byte("tx-b")}}, cs.LockedQC())
+	cs.AddBlock(b)
+	cs.UpdateLockedQC(&protobuf.QuorumCert{Epoch: 2, BlockHash: BlockHash(b)})
+
+	pool := NewTransactionPool()
+	tx := newCrossTx([]uint32{0, 1}, 2)
+	if err := pool.AddTransaction(tx, 0, 1); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+
+	state := txs.NewState(1)
+	if err := state.Execute(tx); err != nil {
+		t.Fatalf("failed to lock the inputs: %v", err)
+	}
+
+	finished := newCrossTx([]uint32{0}, 1)
+	// 累加器的元素按 epoch 保存
+	acc := crypto.NewAccumulator([]string{"tx-a"}, crypto.HashToPrimeFromSha256, AccSetup)
+	if err := SaveCheckpoint(store, 1, cs, pool, state, acc, []string{"tx-a"}, nil, nil); err != nil {
+		t.Fatalf("failed to save checkpoint: %v", err)
+	}
+	if err := acc.Add([]string{"tx-b"}); err != nil {
+		t.Fatalf("failed to update the accumulator: %v", err)
+	}
+	if err := SaveCheckpoint(store, 2, cs, pool, state, acc, []string{"tx-b"}, map[int][]byte{1: []byte("acc-1")}, []*protobuf.Transaction{finished}); err != nil {
+		t.Fatalf("failed to save checkpoint: %v", err)
+	}
+
+	cp, err := LoadCheckpoint(store)
+	if err != nil || cp == nil {
+		t.Fatalf("failed to load checkpoint: %v", err)
+	}
+	if cp.Epoch != 2 || cp.Acc == nil || cp.Acc.Value().Cmp(acc.Value()) != 0 {
+		t.Fatalf("unexpected epoch %d or accumulator", cp.Epoch)
+	}
+	if !bytes.Equal(cp.ShardAccs[1], []byte("acc-1")) {
+		t.Errorf("expected the accumulator accepted from shard 1 to be recovered, got %v", cp.ShardAccs)
+	}
+	// 恢复的累加器保留了 epoch 1 的元素
+	w, err := cp.Acc.ProveMembership("tx-a")
+	if err != nil || !crypto.VerifyElementMembership(AccSetup, crypto.HashToPrimeFromSha256, cp.Acc.Value(), "tx-a", w) {
+		t.Errorf("expected a membership proof for an element of epoch 1, got %v", err)
+	}
+	if len(cp.Finished) != 1 || !proto.Equal(cp.Finished[0], finished) {
+		t.Errorf("expected the finished transaction to be recovered, got %d", len(cp.Finished))
+	}
+	if cp.State == nil || !cp.State.Locked(tx.Id) {
+		t.Errorf("expected the locked inputs to be recovered")
+	}
+	if !bytes.Equal(BlockHash(cp.Chain.Tip()), BlockHash(a)) {
+		t.Errorf("expected block A as recovered tip, got height %d", cp.Chain.Tip().Height)
+	}
+	if !bytes.Equal(cp.Chain.LockedQC().GetBlockHash(), BlockHash(b)) || cp.Chain.GetBlock(BlockHash(b)) == nil {
+		t.Errorf("expected lock on uncommitted block B to be recovered")
+	}
+	// 恢复后仍然拒绝与锁定区块冲突的提议
+	if cp.Chain.SafeNode(nil, NewBlock(2, BlockHash(a), 2, []*protobuf.Transaction{{Data: []byte("tx-c")}}, nil), false) {
+		t.Errorf("expected conflicting block to be refused after recovery")
+	}
+
+	if err := cp.Pool.AddTransaction(tx, 1, 3); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if completed := cp.Pool.CheckAndRemoveTransactions(); len(completed) != 1 {
+		t.Errorf("expected pending transaction to complete after recovery, got %d", len(completed))
+	}
+}
@@ -0,0 +1,157 @@
+package bft
+
+import (
+	"Chamael/internal/party"
+	"Chamael/pkg/core"
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"bytes"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	core.RegisterMessages(map[string]func() proto.Message{
+		"Block_Request":  func() proto.Message { return new(protobuf.Block_Request) },
+		"Block_Response": func() proto.Message { return new(protobuf.Block_Response) },
+	})
+}
+
+// syncID Block_Request 消息的 ID, Block_Response 的 ID 见 syncResponseID
+var syncID = utils.Uint32ToBytes(0)
+
+// syncResponseID Block_Response 消息的 ID: 请求方的 epoch||请求的区块哈希,
+// 以 epoch 开头, 请求方的 epoch 结束后连同迟到的响应一起回收
+func syncResponseID(e uint32, hash []byte) []byte {
+	return append(utils.Uint32ToBytes(e), hash...)
+}
+
+// lookupBlock 供同步服务使用: 先查内存中的区块, 找不到时查账本
+func lookupBlock(p *party.HonestParty, cs *ChainState, hash []byte) *protobuf.Block {
+	b := cs.GetBlock(hash)
+	if b == nil && p.Ledger != nil {
+		if r, err := p.Ledger.GetBlockByHash(hash); err == nil && r != nil {
+			b = r.Block
+		}
+	}
+	return b
+}
+
+// SyncServer 响应其他节点的 Block_Request, 返回从高度 from 到 blockHash 所指区块的连续区块
+func SyncServer(p *party.HonestParty, cs *ChainState) {
+	for {
+		m := <-p.GetMessage("Block_Request", syncID)
+		raw, err := core.Decapsulation("Block_Request", m)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		payload := raw.(*protobuf.Block_Request)
+
+		var blocks []*protobuf.Block
+		hash := payload.BlockHash
+		for {
+			b := lookupBlock(p, cs, hash)
+			if b == nil || b.Height < payload.From {
+				break
+			}
+			blocks = append(blocks, b)
+			if b.Height == payload.From {
+				break
+			}
+			hash = b.Parent
+		}
+		// 按高度从低到高返回
+		for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+			blocks[i], blocks[j] = blocks[j], blocks[i]
+		}
+
+		Block_ResponseMessage := core.Encapsulation("Block_Response", syncResponseID(payload.Epoch, payload.BlockHash), p.PID, &protobuf.Block_Response{
+			Blocks: blocks,
+		})
+		p.Send(Block_ResponseMessage, m.Sender)
+	}
+}
+
+// verifyChain 检查同步得到的区块: 从 tip 开始高度连续、父哈希相连, 交易与 TxRoot 一致,
+// 每个区块的 justify 是本次共识参与者的有效 QC, 且最后一个区块正是 qc 所证明的区块
+func verifyChain(p *party.HonestParty, tip *protobuf.Block, qc *protobuf.QuorumCert, blocks []*protobuf.Block, isGlobal bool) bool {
+	if len(blocks) == 0 || !bytes.Equal(BlockHash(blocks[len(blocks)-1]), qc.BlockHash) {
+		return false
+	}
+	parent := tip
+	for _, b := range blocks {
+		if b.Height != parent.Height+1 || !bytes.Equal(b.Parent, BlockHash(parent)) {
+			return false
+		}
+		if !VerifyBlockTxs(b) {
+			return false
+		}
+		if b.Justify != nil && !verifyQC(p, b.Justify, isGlobal) {
+			return false
+		}
+		parent = b
+	}
+	return true
+}
+
+// SyncTo 在 epoch e 中从 peers 依次请求 tip 之后直到 qc 所指区块的连续区块, 验证通过后加入 cs
+// 返回按高度排列的区块, 所有节点都未能给出有效区块时返回 nil
+func SyncTo(p *party.HonestParty, cs *ChainState, e uint32, qc *protobuf.QuorumCert, peers []uint32, isGlobal bool) []*protobuf.Block {
+	if qc == nil || !verifyQC(p, qc, isGlobal) {
+		return nil
+	}
+	tip := cs.Tip()
+	Block_RequestMessage := core.Encapsulation("Block_Request", syncID, p.PID, &protobuf.Block_Request{
+		BlockHash: qc.BlockHash,
+		From:      tip.Height + 1,
+		Epoch:     e,
+	})
+
+	for _, peer := range peers {
+		// 连接断开的节点(例如正在重启)收不到请求, 不必等待它超时
+		if peer == p.PID || p.LinkState(peer) == core.LinkDisconnected {
+			continue
+		}
+		fmt.Println("Sync blocks from", peer, "after height", tip.Height, p.PID)
+		p.Send(Block_RequestMessage, peer)
+		timer := time.NewTimer(ViewTimeout / 2)
+	Wait:
+		for {
+			select {
+			case m := <-p.GetMessage("Block_Response", syncResponseID(e, qc.BlockHash)):
+				raw, err := core.Decapsulation("Block_Response", m)
+				if err != nil {
+					fmt.Println(err)
+					continue
+				}
+				payload := raw.(*protobuf.Block_Response)
+				if !verifyChain(p, tip, qc, payload.Blocks, isGlobal) {
+					fmt.Println("Invalid Block_Response from", m.Sender)
+					continue
+				}
+				timer.Stop()
+				for _, b := range payload.Blocks {
+					cs.AddBlock(b)
+				}
+				return payload.Blocks
+			case <-timer.C:
+				break Wait
+			}
+		}
+	}
+	return nil
+}
+
+// syncPeers 同步时依次询问的节点: 先询问提议者, 再询问其他参与者
+func syncPeers(p *party.HonestParty, proposer uint32, isGlobal bool) []uint32 {
+	peers := []uint32{proposer}
+	for i := uint32(0); i < p.N*p.M; i++ {
+		if i != proposer && inCommittee(p, i, isGlobal) {
+			peers = append(peers, i)
+		}
+	}
+	return peers
+}
@@ -0,0 +1,63 @@
+package bft
+
+import (
+	"Chamael/pkg/core"
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// 测试同步时拒绝 justify 只有一个签名者的区块
+func TestSyncRejectsSingleSignerQC(t *testing.T) {
+	defer func(d time.Duration) { ViewTimeout = d }(ViewTimeout)
+	ViewTimeout = 200 * time.Millisecond
+	ps := newMemoryParties(t, 4, 1, 1, false)
+
+	b1 := NewBlock(1, genesisHash, 0, []*protobuf.Transaction{{Data: []byte("tx-1")}}, nil)
+	b2 := NewBlock(2, BlockHash(b1), 1, []*protobuf.Transaction{{Data: []byte("tx-2")}}, signQC(ps[:3], BlockHash(b1), 1, 0))
+	qc := signQC(ps[:3], BlockHash(b2), 2, 0)
+
+	// 节点 2 正常响应
+	good := NewChainState()
+	good.AddBlock(b1)
+	good.AddBlock(b2)
+	go SyncServer(ps[2], good)
+
+	// 节点 1 返回的 b2 的 justify 只由节点 1 自己签名
+	forged := proto.Clone(b2).(*protobuf.Block)
+	forged.Justify = signQC(ps[1:2], BlockHash(b1), 1, 0)
+	go func() {
+		for {
+			m := <-ps[1].GetMessage("Block_Request", syncID)
+			raw, err := core.Decapsulation("Block_Request", m)
+			if err != nil {
+				continue
+			}
+			payload := raw.(*protobuf.Block_Request)
+			ps[1].Send(core.Encapsulation("Block_Response", syncResponseID(payload.Epoch, payload.BlockHash), ps[1].PID, &protobuf.Block_Response{
+				Blocks: []*protobuf.Block{b1, forged},
+			}), m.Sender)
+		}
+	}()
+
+	cs := NewChainState()
+	if blocks := SyncTo(ps[0], cs, 3, signQC(ps[1:2], BlockHash(b2), 2, 0), []uint32{2}, false); blocks != nil {
+		t.Fatalf("expected a target QC with a single signer to be refused")
+	}
+	if blocks := SyncTo(ps[0], cs, 3, qc, []uint32{1}, false); blocks != nil || cs.GetBlock(BlockHash(b1)) != nil {
+		t.Fatalf("expected the blocks with a single-signer justify to be rejected")
+	}
+	blocks := SyncTo(ps[0], cs, 3, qc, []uint32{1, 2}, false)
+	if len(blocks) != 2 || !bytes.Equal(blocks[1].Justify.Signers, b2.Justify.Signers) {
+		t.Fatalf("expected the valid blocks from party 2, got %d blocks", len(blocks))
+	}
+
+	// 响应以请求方的 epoch 开头, epoch 结束后与 Kronos 的其他消息一起回收
+	ps[0].CollectEpochs(epochMessageTypes, 4)
+	if stats := ps[0].DispatchStats(); stats.Mailboxes != 0 {
+		t.Errorf("expected the Block_Response mailboxes to be collected, %d left", stats.Mailboxes)
+	}
+}
@@ -6,23 +6,22 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"sync"
 )
 
 // CommonParty is a struct of normal consensus parties
 type CommonParty struct {
-	N                 uint32
-	F                 uint32
-	m                 uint32 //分片个数
-	PID               uint32
-	Snumber           uint32 //节点所在的分片编号
-	SID               uint32 //节点在分片内的编号
-	ipList            []string
-	portList          []string
-	sendChannels      []chan *protobuf.Message
-	dispatcheChannels *sync.Map
-	ShardList         []int //节点负责沟通的分片
-	Debug             bool
+	N            uint32
+	F            uint32
+	m            uint32 //分片个数
+	PID          uint32
+	Snumber      uint32 //节点所在的分片编号
+	SID          uint32 //节点在分片内的编号
+	ipList       []string
+	portList     []string
+	sendChannels []chan *protobuf.Message
+	dispatcher   *core.Dispatcher
+	ShardList    []int //节点负责沟通的分片
+	Debug        bool
 }
 
 // NewCommonParty return a new common party object
@@ -45,7 +44,7 @@ func NewCommonParty(N uint32, F uint32, m uint32, pid uint32, snum uint32, sid u
 
 // InitReceiveChannel setup the listener and Init the receiveChannel
 func (p *CommonParty) InitReceiveChannel() error {
-	p.dispatcheChannels = core.MakeDispatcheChannels(core.MakeReceiveChannel(p.portList[p.PID], p.Debug, int(p.N)), p.N)
+	p.dispatcher = core.NewDispatcher(core.MakeReceiveChannel(p.portList[p.PID], p.Debug, int(p.N)), p.N*p.m, core.DefaultDispatchOptions)
 	return nil
 }
 
@@ -120,12 +119,7 @@ func (p *CommonParty) Shard_Broadcast(m *protobuf.Message, des uint32) error {
 
 // GetMessage Try to get a message according to messageType, ID
 func (p *CommonParty) GetMessage(messageType string, ID []byte) chan *protobuf.Message {
-	value1, _ := p.dispatcheChannels.LoadOrStore(messageType, new(sync.Map))
-
-	var value2 any
-	value2, _ = value1.(*sync.Map).LoadOrStore(string(ID), make(chan *protobuf.Message, 4096))
-
-	return value2.(chan *protobuf.Message)
+	return p.dispatcher.GetMessage(messageType, ID)
 }
 
 func (p *CommonParty) checkInit() bool {
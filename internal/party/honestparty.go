@@ -2,31 +2,33 @@ package party
 
 import (
 	"Chamael/pkg/core"
+	"Chamael/pkg/crypto"
 	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils/db"
 	"encoding/base64"
 	"errors"
-	"fmt"
-	"math/big"
-	"os"
-	"sync"
 
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/pairing"
 )
 
 type HonestParty struct {
-	N                 uint32
-	F                 uint32
-	M                 uint32 //分片个数
-	PID               uint32
-	Snumber           uint32 //节点所在的分片编号
-	SID               uint32 //节点在分片内的编号
-	ipList            []string
-	portList          []string
-	sendChannels      []chan *protobuf.Message
-	dispatcheChannels *sync.Map
-	Acc               *big.Int // 交易累加器
-	Debug             bool
+	N               uint32
+	F               uint32
+	M               uint32 //分片个数
+	PID             uint32
+	Snumber         uint32 //节点所在的分片编号
+	SID             uint32 //节点在分片内的编号
+	ipList          []string
+	portList        []string
+	transport       core.Transport
+	dispatchOptions core.DispatchOptions
+	envelope        *core.Envelope // 不为 nil 时对发出的消息签名并校验收到的消息
+	sendChannels    []chan *protobuf.Message
+	dispatcher      *core.Dispatcher
+	Acc             *crypto.Accumulator // 交易累加器, 保存此前所有 epoch 累加的交易, 可为其中任一笔生成 witness
+	Ledger          *db.BlockStore      // 已提交区块的账本, 同时保存重启所需的检查点; 为 nil 时不持久化
+	Debug           bool
 
 	PK []kyber.Point
 	SK kyber.Scalar
@@ -72,25 +74,56 @@ func NewHonestParty(N uint32, F uint32, m uint32, pid uint32, snum uint32, sid u
 	return &p
 }
 
+// SetTransport replaces the default TCP transport, please run this before initializing the channels
+func (p *HonestParty) SetTransport(t core.Transport) {
+	p.transport = t
+}
+
+func (p *HonestParty) getTransport() core.Transport {
+	if p.transport == nil {
+		p.transport = core.NewTCPTransport(p.PID, p.ipList, p.portList, p.Debug)
+	}
+	return p.transport
+}
+
+// LinkState returns the state of the link to party des, transports that do not track their links are always connected
+func (p *HonestParty) LinkState(des uint32) core.LinkState {
+	if monitor, ok := p.getTransport().(core.LinkMonitor); ok {
+		return monitor.LinkState(des)
+	}
+	return core.LinkConnected
+}
+
+// SetDispatchOptions sets the quotas of received messages, please run this before initializing the receive channel
+func (p *HonestParty) SetDispatchOptions(opts core.DispatchOptions) {
+	p.dispatchOptions = opts
+}
+
+// EnableSignatures signs every sent message and drops received messages with a bad signature or a replayed sequence number,
+// please run this before initializing the receive channel
+func (p *HonestParty) EnableSignatures() {
+	p.envelope = core.NewEnvelope(p.PID, p.SK, p.PK)
+	p.dispatchOptions.Verify = p.envelope.Open
+}
+
 // InitReceiveChannel setup the listener and Init the receiveChannel
 func (p *HonestParty) InitReceiveChannel() error {
-	p.dispatcheChannels = core.MakeDispatcheChannels(core.MakeReceiveChannel(p.portList[p.PID], p.Debug, int(p.N)), p.N*p.M)
+	receiveChannel, err := p.getTransport().Listen()
+	if err != nil {
+		return err
+	}
+	p.dispatcher = core.NewDispatcher(receiveChannel, p.N*p.M, p.dispatchOptions)
 	return nil
 }
 
 // InitSendChannel setup the sender and Init the sendChannel, please run this after initializing all party's receiveChannel
 func (p *HonestParty) InitSendChannel() error {
-	homeDir, err := os.UserHomeDir()
-	var dirname string
-	if err != nil {
-		return err
-	}
-	if p.Debug == true {
-		dirname = fmt.Sprintf(homeDir+"/Chamael/log/%s", p.ipList[p.PID]+":"+p.portList[p.PID])
-		os.Mkdir(dirname, 0755)
-	}
 	for i := uint32(0); i < p.N*p.M; i++ {
-		p.sendChannels[i] = core.MakeSendChannel(p.ipList[i], p.portList[i], dirname, p.Debug)
+		sendChannel, err := p.getTransport().Dial(i)
+		if err != nil {
+			return err
+		}
+		p.sendChannels[i] = sendChannel
 	}
 	return nil
 }
@@ -117,6 +150,9 @@ func (p *HonestParty) Send(m *protobuf.Message, des uint32) error {
 			p.CrossShardTraffic += messageSize
 		}
 
+		if p.envelope != nil {
+			p.envelope.Seal(m)
+		}
 		p.sendChannels[des] <- m
 		return nil
 	}
@@ -167,12 +203,17 @@ func (p *HonestParty) Shard_Broadcast(m *protobuf.Message, des uint32) error {
 
 // GetMessage Try to get a message according to messageType, ID
 func (p *HonestParty) GetMessage(messageType string, ID []byte) chan *protobuf.Message {
-	value1, _ := p.dispatcheChannels.LoadOrStore(messageType, new(sync.Map))
+	return p.dispatcher.GetMessage(messageType, ID)
+}
 
-	var value2 any
-	value2, _ = value1.(*sync.Map).LoadOrStore(string(ID), make(chan *protobuf.Message, 4096))
+// CollectEpochs drops the messages of the given types whose epoch is lower than before, these epochs are finished
+func (p *HonestParty) CollectEpochs(types []string, before uint32) {
+	p.dispatcher.Collect(types, before)
+}
 
-	return value2.(chan *protobuf.Message)
+// DispatchStats returns the statistics of received messages
+func (p *HonestParty) DispatchStats() core.DispatchStats {
+	return p.dispatcher.Stats()
 }
 
 func (p *HonestParty) checkInit() bool {
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"Chamael/pkg/crypto"
 	"encoding/base64"
 	"fmt"
 	"go.dedis.ch/kyber/v3/pairing"
@@ -32,8 +33,19 @@ type HonestConfig struct {
 	PK        []string `yaml:"PK"`
 	SK        string   `yaml:"SK"`
 	// server start time
-	PrepareTime int `yaml:"PrepareTime"`
-	WaitTime    int `yaml:"WaitTime"`
+	PrepareTime    int `yaml:"PrepareTime"`
+	WaitTime       int `yaml:"WaitTime"`
+	ViewTimeout    int `yaml:"ViewTimeout"`    //HotStuff视图0的超时时间(ms),缺省时使用bft.ViewTimeout
+	PendingTimeout int `yaml:"PendingTimeout"` //跨片交易等待输入分片结果的最大epoch数,超时后中止并退还输入,缺省时使用bft.PendingTimeout
+
+	HotStuffMode string `yaml:"HotStuffMode"` //片内共识模式: basic(缺省) 或 chained
+	DataDir      string `yaml:"DataDir"`      //账本与检查点所在目录,缺省为 ~/Chamael/db;目录中已有检查点时节点从中恢复
+	NetSim       string `yaml:"NetSim"`       //网络模拟配置文件(延迟、带宽、丢包、乱序、分区),缺省时直接使用TCP
+	TLS          bool   `yaml:"TLS"`          //使用与节点BLS密钥绑定的双向TLS连接,接收方以认证得到的节点编号作为消息发送者
+	SendPolicy   string `yaml:"SendPolicy"`   //连接断开期间发送的消息: buffer(缺省,缓存并在重连后补发) 或 drop(直接丢弃)
+	SendBuffer   int    `yaml:"SendBuffer"`   //每条连接断开期间最多缓存的消息数,缺省为 core.MAXMESSAGE
+	SignMessages bool   `yaml:"SignMessages"` //对每条消息的 type||id||sender||seq||data 签名,接收方校验签名并拒绝重放的消息
+	AccParams    string `yaml:"AccParams"`    //accSetup 生成的RSA累加器参数文件,启动时验证;缺省时使用 crypto.TrustedSetup 的演示参数
 
 	TestEpochs int `yaml:"TestEpochs"`
 }
@@ -84,6 +96,18 @@ ret:
 	return errors.Wrap(err, ConfigReadError.Error())
 }
 
+// AccSetup 读取并验证 AccParams 指定的RSA累加器参数, 未指定时返回演示参数
+func (c *HonestConfig) AccSetup() (*crypto.Setup, error) {
+	if c.AccParams == "" {
+		return crypto.TrustedSetup(), nil
+	}
+	setup, err := crypto.LoadSetup(c.AccParams)
+	if err != nil {
+		return nil, errors.Wrap(err, "load accumulator parameters "+c.AccParams)
+	}
+	return setup, nil
+}
+
 // Achieve numbers of total nodes
 // the return value is a positive integer
 func (c *HonestConfig) GetN() (int, error) {
@@ -2,6 +2,8 @@ package core
 
 import (
 	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"errors"
 	"sync"
 
 	"google.golang.org/protobuf/proto"
@@ -10,25 +12,276 @@ import (
 var Mu = new(sync.Mutex)
 var Traffic = 0
 
-// MakeDispatcheChannels dispatche messages from receiveChannel
-// and make a double layer Map : (messageType) --> (id) --> (channel)
-func MakeDispatcheChannels(receiveChannel chan *protobuf.Message, N uint32) *sync.Map {
-	dispatcheChannels := new(sync.Map)
+// DispatchOptions bounds the messages waiting to be read from the dispatcher
+type DispatchOptions struct {
+	MailboxQuota int // messages of one sender waiting in one (type, id) mailbox
+	PeerQuota    int // messages of one sender waiting in all mailboxes
 
-	go func() { //dispatcher
-		for {
-			m := <-(receiveChannel)
-			value1, _ := dispatcheChannels.LoadOrStore(m.Type, new(sync.Map))
+	// Verify, when not nil, checks every message before it is dispatched (see Envelope.Open)
+	Verify func(m *protobuf.Message) error
+}
+
+// DefaultDispatchOptions is used unless other options are given to NewDispatcher
+var DefaultDispatchOptions = DispatchOptions{
+	MailboxQuota: 128,
+	PeerQuota:    MAXMESSAGE,
+}
+
+// Reasons of dropping a message, used as keys of DispatchStats.Reasons
+const (
+	DropUnknownSender = "unknown sender"
+	DropMailboxQuota  = "mailbox quota"
+	DropPeerQuota     = "peer quota"
+	DropExpired       = "expired"
+	DropBadSignature  = "bad signature"
+	DropReplay        = "replay"
+)
+
+// DispatchStats is a snapshot of the dispatcher
+type DispatchStats struct {
+	Received  uint64
+	Mailboxes int               // (type, id) pairs currently kept
+	Queued    map[string]int    // messages waiting to be read, by type
+	Dropped   map[string]uint64 // dropped messages, by type
+	Reasons   map[string]uint64 // dropped messages, by reason
+}
 
-			var value2 any
-			value2, _ = value1.(*sync.Map).LoadOrStore(string(m.Id), make(chan *protobuf.Message, 4096))
+// Dispatcher routes received messages to a mailbox per (type, id).
+// The dispatcher never blocks: every mailbox owns its queue and a goroutine handing the messages to the reader,
+// and a sender exceeding its quota only loses its own messages, so one flooded type can not stall the others.
+// Mailboxes of finished epochs are removed by Collect.
+type Dispatcher struct {
+	opts       DispatchOptions
+	mu         sync.Mutex
+	mailboxes  map[string]map[string]*mailbox
+	expired    map[string]uint32 // messages of a type whose epoch is lower than expired[type] are dropped
+	peerQueued []int
+
+	received uint64
+	dropped  map[string]uint64
+	reasons  map[string]uint64
+}
 
-			value2.(chan *protobuf.Message) <- m
+type mailbox struct {
+	ch      chan *protobuf.Message
+	queue   []*protobuf.Message
+	senders map[uint32]int
+	wake    chan struct{}
+	done    chan struct{} // closed by Collect while holding d.mu
+}
 
+// NewDispatcher dispatches the messages of receiveChannel sent by parties 0..N-1
+func NewDispatcher(receiveChannel chan *protobuf.Message, N uint32, opts DispatchOptions) *Dispatcher {
+	if opts.MailboxQuota <= 0 {
+		opts.MailboxQuota = DefaultDispatchOptions.MailboxQuota
+	}
+	if opts.PeerQuota <= 0 {
+		opts.PeerQuota = DefaultDispatchOptions.PeerQuota
+	}
+	d := &Dispatcher{
+		opts:       opts,
+		mailboxes:  make(map[string]map[string]*mailbox),
+		expired:    make(map[string]uint32),
+		peerQueued: make([]int, N),
+		dropped:    make(map[string]uint64),
+		reasons:    make(map[string]uint64),
+	}
+	go func() { //dispatcher
+		for m := range receiveChannel {
 			Mu.Lock()
 			Traffic += proto.Size(m)
 			Mu.Unlock()
+
+			if d.opts.Verify != nil {
+				if err := d.opts.Verify(m); err != nil {
+					d.reject(m, err)
+					continue
+				}
+			}
+			d.dispatch(m)
 		}
 	}()
-	return dispatcheChannels
+	return d
+}
+
+// epochOf returns the epoch encoded in the first 4 bytes of an ID
+func epochOf(id []byte) (uint32, bool) {
+	if len(id) < 4 {
+		return 0, false
+	}
+	return utils.BytesToUint32(id[:4]), true
+}
+
+func (d *Dispatcher) isExpired(typ string, id []byte) bool {
+	before, ok := d.expired[typ]
+	if !ok {
+		return false
+	}
+	e, ok := epochOf(id)
+	return ok && e < before
+}
+
+func (d *Dispatcher) drop(m *protobuf.Message, reason string) {
+	d.dropped[m.Type]++
+	d.reasons[reason]++
+}
+
+// reject drops a message that failed verification
+func (d *Dispatcher) reject(m *protobuf.Message, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.received++
+	if errors.Is(err, ErrReplay) {
+		d.drop(m, DropReplay)
+	} else {
+		d.drop(m, DropBadSignature)
+	}
+}
+
+func (d *Dispatcher) dispatch(m *protobuf.Message) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.received++
+	if int(m.Sender) >= len(d.peerQueued) {
+		d.drop(m, DropUnknownSender)
+		return
+	}
+	if d.isExpired(m.Type, m.Id) {
+		d.drop(m, DropExpired)
+		return
+	}
+	if d.peerQueued[m.Sender] >= d.opts.PeerQuota {
+		d.drop(m, DropPeerQuota)
+		return
+	}
+	mb := d.mailbox(m.Type, m.Id)
+	if mb.senders[m.Sender] >= d.opts.MailboxQuota {
+		d.drop(m, DropMailboxQuota)
+		return
+	}
+	mb.queue = append(mb.queue, m)
+	mb.senders[m.Sender]++
+	d.peerQueued[m.Sender]++
+	select {
+	case mb.wake <- struct{}{}:
+	default:
+	}
+}
+
+// mailbox returns the mailbox of (typ, id), creating it if needed, d.mu must be held
+func (d *Dispatcher) mailbox(typ string, id []byte) *mailbox {
+	byID, ok := d.mailboxes[typ]
+	if !ok {
+		byID = make(map[string]*mailbox)
+		d.mailboxes[typ] = byID
+	}
+	mb, ok := byID[string(id)]
+	if !ok {
+		mb = &mailbox{
+			ch:      make(chan *protobuf.Message),
+			senders: make(map[uint32]int),
+			wake:    make(chan struct{}, 1),
+			done:    make(chan struct{}),
+		}
+		byID[string(id)] = mb
+		go d.forward(mb)
+	}
+	return mb
+}
+
+// forward hands the queued messages of mb to its reader one by one
+func (d *Dispatcher) forward(mb *mailbox) {
+	for {
+		d.mu.Lock()
+		if len(mb.queue) == 0 {
+			d.mu.Unlock()
+			select {
+			case <-mb.wake:
+				continue
+			case <-mb.done:
+				return
+			}
+		}
+		m := mb.queue[0]
+		d.mu.Unlock()
+
+		select {
+		case mb.ch <- m:
+			d.mu.Lock()
+			select {
+			case <-mb.done:
+				// Collect already released the queue
+				d.mu.Unlock()
+				return
+			default:
+			}
+			mb.queue[0] = nil
+			mb.queue = mb.queue[1:]
+			mb.senders[m.Sender]--
+			d.peerQueued[m.Sender]--
+			d.mu.Unlock()
+		case <-mb.done:
+			return
+		}
+	}
+}
+
+// GetMessage returns the channel of the messages of (messageType, ID)
+// the channel of a collected epoch never delivers anything
+func (d *Dispatcher) GetMessage(messageType string, ID []byte) chan *protobuf.Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.isExpired(messageType, ID) {
+		return make(chan *protobuf.Message)
+	}
+	return d.mailbox(messageType, ID).ch
+}
+
+// Collect removes the mailboxes of the given types whose ID starts with an epoch lower than before,
+// and drops the messages of these epochs received later
+func (d *Dispatcher) Collect(types []string, before uint32) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, typ := range types {
+		if d.expired[typ] < before {
+			d.expired[typ] = before
+		}
+		for id, mb := range d.mailboxes[typ] {
+			if !d.isExpired(typ, []byte(id)) {
+				continue
+			}
+			for _, m := range mb.queue {
+				d.peerQueued[m.Sender]--
+			}
+			close(mb.done)
+			delete(d.mailboxes[typ], id)
+		}
+	}
+}
+
+// Stats returns a snapshot of the dispatcher
+func (d *Dispatcher) Stats() DispatchStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := DispatchStats{
+		Received: d.received,
+		Queued:   make(map[string]int),
+		Dropped:  make(map[string]uint64),
+		Reasons:  make(map[string]uint64),
+	}
+	for typ, byID := range d.mailboxes {
+		s.Mailboxes += len(byID)
+		for _, mb := range byID {
+			if len(mb.queue) > 0 {
+				s.Queued[typ] += len(mb.queue)
+			}
+		}
+	}
+	for k, v := range d.dropped {
+		s.Dropped[k] = v
+	}
+	for k, v := range d.reasons {
+		s.Reasons[k] = v
+	}
+	return s
 }
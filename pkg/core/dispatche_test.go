@@ -0,0 +1,79 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"testing"
+	"time"
+)
+
+// waitReceived waits until the dispatcher has handled n messages
+func waitReceived(t *testing.T, d *Dispatcher, n uint64) DispatchStats {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if s := d.Stats(); s.Received >= n {
+			return s
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("dispatcher did not handle %d messages", n)
+	return DispatchStats{}
+}
+
+func TestDispatcherQuotaDoesNotStallOtherTypes(t *testing.T) {
+	in := make(chan *protobuf.Message, MAXMESSAGE)
+	d := NewDispatcher(in, 2, DispatchOptions{MailboxQuota: 4, PeerQuota: 6})
+
+	// 节点1灌入大量无人读取的消息, 只会丢弃它自己超出配额的部分
+	for i := 0; i < 20; i++ {
+		in <- &protobuf.Message{Type: "Flood", Id: utils.Uint32ToBytes(uint32(i / 10)), Sender: 1}
+	}
+	in <- &protobuf.Message{Type: "Vote", Id: utils.Uint32ToBytes(1), Sender: 0}
+	in <- &protobuf.Message{Type: "Vote", Id: utils.Uint32ToBytes(1), Sender: 5}
+
+	select {
+	case m := <-d.GetMessage("Vote", utils.Uint32ToBytes(1)):
+		if m.Sender != 0 {
+			t.Errorf("expected the vote of party 0, got %d", m.Sender)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the flooded type stalled the dispatcher")
+	}
+
+	s := waitReceived(t, d, 22)
+	if s.Queued["Flood"] != 6 {
+		t.Errorf("expected the peer quota to keep 6 messages, got %d", s.Queued["Flood"])
+	}
+	if s.Reasons[DropMailboxQuota] != 6 || s.Reasons[DropPeerQuota] != 8 || s.Reasons[DropUnknownSender] != 1 {
+		t.Errorf("unexpected drop reasons %v", s.Reasons)
+	}
+	if s.Dropped["Flood"] != 14 {
+		t.Errorf("expected 14 dropped Flood messages, got %d", s.Dropped["Flood"])
+	}
+}
+
+func TestDispatcherCollectEpochs(t *testing.T) {
+	in := make(chan *protobuf.Message, MAXMESSAGE)
+	d := NewDispatcher(in, 1, DefaultDispatchOptions)
+	for e := uint32(1); e <= 3; e++ {
+		in <- &protobuf.Message{Type: "Prepare", Id: utils.Uint32ToBytes(e)}
+	}
+	in <- &protobuf.Message{Type: "Block_Request", Id: utils.Uint32ToBytes(0)}
+	waitReceived(t, d, 4)
+
+	d.Collect([]string{"Prepare"}, 3)
+	in <- &protobuf.Message{Type: "Prepare", Id: utils.Uint32ToBytes(2)}
+	s := waitReceived(t, d, 5)
+	if s.Mailboxes != 2 || s.Queued["Prepare"] != 1 {
+		t.Errorf("expected only epoch 3 and Block_Request to be kept, got %d mailboxes, %v", s.Mailboxes, s.Queued)
+	}
+	if s.Reasons[DropExpired] != 1 {
+		t.Errorf("expected the late message to be dropped, got %v", s.Reasons)
+	}
+
+	select {
+	case <-d.GetMessage("Prepare", utils.Uint32ToBytes(3)):
+	case <-time.After(5 * time.Second):
+		t.Fatal("the message of epoch 3 was lost")
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+)
+
+// ReplayWindow is the number of sequence numbers below the highest one seen from a sender that are still accepted,
+// so that messages reordered by the network are not mistaken for replays
+var ReplayWindow = uint64(4096)
+
+var (
+	ErrBadSignature = errors.New("invalid message signature")
+	ErrReplay       = errors.New("replayed message")
+)
+
+// Envelope signs outgoing messages with the BLS key of this party and checks the messages of the others.
+// The signature covers type||id||sender||seq||data, and every sender numbers its messages
+// starting from the time it was created, so a restarted party keeps numbering above its old messages.
+type Envelope struct {
+	pid uint32
+	sk  kyber.Scalar
+	pk  []kyber.Point
+	seq uint64
+
+	mu      sync.Mutex
+	windows []replayWindow
+}
+
+// replayWindow remembers the sequence numbers seen in (highest-ReplayWindow, highest]
+type replayWindow struct {
+	highest uint64
+	seen    map[uint64]bool
+}
+
+// NewEnvelope creates the envelope of party pid, pk are the public keys of all parties
+func NewEnvelope(pid uint32, sk kyber.Scalar, pk []kyber.Point) *Envelope {
+	return &Envelope{
+		pid:     pid,
+		sk:      sk,
+		pk:      pk,
+		seq:     uint64(time.Now().UnixNano()),
+		windows: make([]replayWindow, len(pk)),
+	}
+}
+
+// envelopeDigest is the content signed by the sender, every field is length-prefixed
+func envelopeDigest(m *protobuf.Message) []byte {
+	h := sha256.New()
+	for _, field := range [][]byte{[]byte(m.Type), m.Id, utils.Uint32ToBytes(m.Sender), utils.Uint64ToBytes(m.Seq), m.Data} {
+		h.Write(utils.IntToBytes(len(field)))
+		h.Write(field)
+	}
+	return h.Sum(nil)
+}
+
+// Seal numbers and signs m, messages that are already signed (e.g. broadcast to several parties) are left unchanged
+func (e *Envelope) Seal(m *protobuf.Message) {
+	if len(m.Sig) > 0 {
+		return
+	}
+	m.Seq = atomic.AddUint64(&e.seq, 1)
+	sig, err := bls.Sign(bn256.NewSuite(), e.sk, envelopeDigest(m))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	m.Sig = sig
+}
+
+// Open checks the signature of m and rejects a sequence number already seen from its sender
+func (e *Envelope) Open(m *protobuf.Message) error {
+	if int(m.Sender) >= len(e.pk) {
+		return fmt.Errorf("%w: unknown sender %d", ErrBadSignature, m.Sender)
+	}
+	if err := bls.Verify(bn256.NewSuite(), e.pk[m.Sender], envelopeDigest(m), m.Sig); err != nil {
+		return fmt.Errorf("%w: %s from %d", ErrBadSignature, m.Type, m.Sender)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w := &e.windows[m.Sender]
+	if w.seen == nil {
+		w.seen = make(map[uint64]bool)
+	}
+	if m.Seq+ReplayWindow <= w.highest || w.seen[m.Seq] {
+		return fmt.Errorf("%w: %s from %d with seq %d", ErrReplay, m.Type, m.Sender, m.Seq)
+	}
+	w.seen[m.Seq] = true
+	if m.Seq > w.highest {
+		w.highest = m.Seq
+	}
+	if uint64(len(w.seen)) > 2*ReplayWindow {
+		for seq := range w.seen {
+			if seq+ReplayWindow <= w.highest {
+				delete(w.seen, seq)
+			}
+		}
+	}
+	return nil
+}
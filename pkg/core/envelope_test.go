@@ -0,0 +1,55 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"errors"
+	"testing"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/sign/bls"
+	"google.golang.org/protobuf/proto"
+)
+
+func newEnvelopes() []*Envelope {
+	suite := pairing.NewSuiteBn256()
+	sks := make([]kyber.Scalar, 2)
+	pks := make([]kyber.Point, 2)
+	for i := range sks {
+		sks[i], pks[i] = bls.NewKeyPair(suite, suite.RandomStream())
+	}
+	return []*Envelope{NewEnvelope(0, sks[0], pks), NewEnvelope(1, sks[1], pks)}
+}
+
+func TestEnvelopeRejectsForgeriesAndReplays(t *testing.T) {
+	es := newEnvelopes()
+	first := &protobuf.Message{Type: "TXs_Inform", Id: []byte{1}, Sender: 0, Data: []byte("txs")}
+	second := &protobuf.Message{Type: "TXs_Inform", Id: []byte{2}, Sender: 0, Data: []byte("txs")}
+	es[0].Seal(first)
+	es[0].Seal(second)
+
+	// 乱序到达的消息仍被接受
+	if err := es[1].Open(second); err != nil {
+		t.Fatalf("expected a valid message, got %v", err)
+	}
+	if err := es[1].Open(first); err != nil {
+		t.Fatalf("expected a reordered message to be accepted, got %v", err)
+	}
+	if err := es[1].Open(first); !errors.Is(err, ErrReplay) {
+		t.Errorf("expected ErrReplay, got %v", err)
+	}
+
+	tampered := proto.Clone(second).(*protobuf.Message)
+	tampered.Data = []byte("other txs")
+	if err := es[1].Open(tampered); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("expected ErrBadSignature for modified data, got %v", err)
+	}
+	spoofed := proto.Clone(second).(*protobuf.Message)
+	spoofed.Sender = 1
+	if err := es[1].Open(spoofed); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("expected ErrBadSignature for a spoofed sender, got %v", err)
+	}
+	if err := es[1].Open(&protobuf.Message{Type: "TXs_Inform", Sender: 0}); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("expected ErrBadSignature for an unsigned message, got %v", err)
+	}
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// LinkState is the state of the connection to a peer
+type LinkState int32
+
+const (
+	LinkConnecting   LinkState = iota // not connected yet
+	LinkConnected                     // messages are written to the peer
+	LinkDisconnected                  // the connection was lost and the link is reconnecting
+)
+
+func (s LinkState) String() string {
+	switch s {
+	case LinkConnected:
+		return "connected"
+	case LinkDisconnected:
+		return "disconnected"
+	default:
+		return "connecting"
+	}
+}
+
+// SendPolicy decides what happens to the messages sent while a link is not connected
+type SendPolicy int
+
+const (
+	// BufferWhileDown keeps up to BufferSize messages, dropping the oldest ones when full, and flushes them once connected
+	BufferWhileDown SendPolicy = iota
+	// DropWhileDown drops every message sent while the link is not connected
+	DropWhileDown
+)
+
+// LinkOptions configures how a link reconnects and what it does with messages meanwhile
+type LinkOptions struct {
+	MinBackoff time.Duration // delay before the first retry, doubled after every failed attempt
+	MaxBackoff time.Duration // upper bound of the delay between two attempts
+	Policy     SendPolicy
+	BufferSize int
+}
+
+// DefaultLinkOptions is used by transports unless SetLinkOptions is called
+var DefaultLinkOptions = LinkOptions{
+	MinBackoff: 10 * time.Millisecond,
+	MaxBackoff: 5 * time.Second,
+	Policy:     BufferWhileDown,
+	BufferSize: MAXMESSAGE,
+}
+
+// Link is a resilient send channel to one peer.
+// Messages pushed to C are written to the current connection; when the connection breaks
+// the link dials again with exponential backoff and handles the messages sent meanwhile according to its policy.
+// A message whose write failed is kept as if it was sent while disconnected, so it may be delivered twice
+// if the peer did read it before the connection broke.
+type Link struct {
+	C chan *protobuf.Message
+
+	addr    string
+	dial    func() (net.Conn, error)
+	opts    LinkOptions
+	logger  *log.Logger
+	state   int32
+	dropped uint64
+}
+
+// NewLink starts a link to addr, dial is called every time a new connection is needed
+// logger records every sent message when it is not nil
+func NewLink(addr string, dial func() (net.Conn, error), opts LinkOptions, logger *log.Logger) *Link {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultLinkOptions.MinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = DefaultLinkOptions.BufferSize
+	}
+	l := &Link{
+		C:      make(chan *protobuf.Message, MAXMESSAGE),
+		addr:   addr,
+		dial:   dial,
+		opts:   opts,
+		logger: logger,
+	}
+	go l.run()
+	return l
+}
+
+// State returns the current state of the link
+func (l *Link) State() LinkState {
+	return LinkState(atomic.LoadInt32(&l.state))
+}
+
+// Dropped returns the number of messages dropped because the link was not connected
+func (l *Link) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+func (l *Link) run() {
+	var conn net.Conn
+	var pending []*protobuf.Message
+	connected := make(chan net.Conn)
+	broken := make(chan net.Conn)
+	go l.connect(connected)
+
+	for {
+		select {
+		case c := <-connected:
+			if l.State() == LinkDisconnected {
+				log.Printf("The link to %s is reconnected", l.addr)
+			}
+			conn = c
+			atomic.StoreInt32(&l.state, int32(LinkConnected))
+			go watch(c, broken)
+			for len(pending) > 0 {
+				if err := l.write(conn, pending[0]); err != nil {
+					conn = l.lost(conn, connected, err)
+					break
+				}
+				pending = pending[1:]
+			}
+		case c := <-broken:
+			if c == conn {
+				conn = l.lost(conn, connected, io.EOF)
+			}
+		case m := <-l.C:
+			if l.logger != nil {
+				l.logger.Println(m)
+			}
+			if conn != nil {
+				err := l.write(conn, m)
+				if err == nil {
+					continue
+				}
+				conn = l.lost(conn, connected, err)
+			}
+			pending = l.hold(pending, m)
+		}
+	}
+}
+
+// lost closes a broken connection and starts reconnecting
+func (l *Link) lost(conn net.Conn, connected chan net.Conn, err error) net.Conn {
+	conn.Close()
+	atomic.StoreInt32(&l.state, int32(LinkDisconnected))
+	log.Printf("The link to %s has broken down (%v), reconnecting", l.addr, err)
+	go l.connect(connected)
+	return nil
+}
+
+// hold applies the send policy to a message that can not be written now
+func (l *Link) hold(pending []*protobuf.Message, m *protobuf.Message) []*protobuf.Message {
+	if l.opts.Policy == DropWhileDown {
+		atomic.AddUint64(&l.dropped, 1)
+		return pending
+	}
+	if len(pending) >= l.opts.BufferSize {
+		pending = pending[1:]
+		atomic.AddUint64(&l.dropped, 1)
+	}
+	return append(pending, m)
+}
+
+// connect dials until it succeeds, waiting a jittered exponential backoff between attempts
+func (l *Link) connect(connected chan net.Conn) {
+	backoff := l.opts.MinBackoff
+	for {
+		conn, err := l.dial()
+		if err == nil {
+			connected <- conn
+			return
+		}
+		time.Sleep(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		backoff *= 2
+		if backoff > l.opts.MaxBackoff {
+			backoff = l.opts.MaxBackoff
+		}
+	}
+}
+
+// write sends one length-prefixed message
+func (l *Link) write(conn net.Conn, m *protobuf.Message) error {
+	byt, err := proto.Marshal(m)
+	if err != nil {
+		log.Println("Failed to marshal the message to", l.addr, err)
+		return nil
+	}
+	_, err = conn.Write(append(utils.IntToBytes(len(byt)), byt...))
+	return err
+}
+
+// watch reports conn on broken once the peer closes it, peers never write on the connections they accept
+func watch(conn net.Conn, broken chan net.Conn) {
+	io.Copy(ioutil.Discard, conn)
+	broken <- conn
+}
+
+// linkSet keeps the links of a transport
+type linkSet struct {
+	opts  LinkOptions
+	links sync.Map
+}
+
+// SetLinkOptions configures the links created by later Dial calls
+func (s *linkSet) SetLinkOptions(opts LinkOptions) {
+	s.opts = opts
+}
+
+// LinkState returns the state of the link to party des
+func (s *linkSet) LinkState(des uint32) LinkState {
+	l, ok := s.links.Load(des)
+	if !ok {
+		return LinkConnecting
+	}
+	return l.(*Link).State()
+}
+
+func (s *linkSet) add(des uint32, l *Link) chan *protobuf.Message {
+	s.links.Store(des, l)
+	return l.C
+}
+
+// LinkMonitor is implemented by transports that report the state of their links
+type LinkMonitor interface {
+	LinkState(des uint32) LinkState
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// accept returns the messages of the next connection accepted by lis
+func accept(t *testing.T, lis net.Listener) (net.Conn, chan *protobuf.Message) {
+	conn, err := lis.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	ch := make(chan *protobuf.Message, MAXMESSAGE)
+	go receiveLoop(conn, ch, 1, nil, nil)
+	return conn, ch
+}
+
+func expectType(t *testing.T, ch chan *protobuf.Message, typ string) {
+	select {
+	case m := <-ch:
+		if m.Type != typ {
+			t.Fatalf("expected %s, got %s", typ, m.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("did not receive %s", typ)
+	}
+}
+
+func TestLinkBuffersAndReconnects(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer lis.Close()
+
+	// 前几次连接失败, 期间发送的消息被缓存
+	attempts := 0
+	l := NewLink(lis.Addr().String(), func() (net.Conn, error) {
+		attempts++
+		if attempts <= 3 {
+			return nil, errors.New("peer is down")
+		}
+		return net.Dial("tcp4", lis.Addr().String())
+	}, LinkOptions{MinBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond}, nil)
+	l.C <- &protobuf.Message{Type: "First"}
+	l.C <- &protobuf.Message{Type: "Second"}
+
+	conn, ch := accept(t, lis)
+	expectType(t, ch, "First")
+	expectType(t, ch, "Second")
+	if l.State() != LinkConnected {
+		t.Errorf("expected the link to be connected, got %v", l.State())
+	}
+
+	// 对端关闭连接后自动重连
+	conn.Close()
+	_, ch = accept(t, lis)
+	l.C <- &protobuf.Message{Type: "Third"}
+	expectType(t, ch, "Third")
+	if l.Dropped() != 0 {
+		t.Errorf("expected no dropped message, got %d", l.Dropped())
+	}
+}
+
+func TestLinkDropsWhileDown(t *testing.T) {
+	l := NewLink("nowhere", func() (net.Conn, error) {
+		return nil, errors.New("peer is down")
+	}, LinkOptions{MinBackoff: time.Millisecond, Policy: DropWhileDown}, nil)
+	for i := 0; i < 3; i++ {
+		l.C <- &protobuf.Message{Type: "Test"}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for l.Dropped() < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if l.Dropped() != 3 {
+		t.Errorf("expected 3 dropped messages, got %d", l.Dropped())
+	}
+	if l.State() != LinkConnecting {
+		t.Errorf("expected the link to be connecting, got %v", l.State())
+	}
+}
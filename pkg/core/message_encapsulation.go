@@ -2,60 +2,66 @@ package core
 
 import (
 	"Chamael/pkg/protobuf"
+	"errors"
+	"fmt"
 	"log"
+	"sync"
 
 	"google.golang.org/protobuf/proto"
 )
 
-// Encapsulation encapsulates a message to a general type(*protobuf.Message)
-func Encapsulation(messageType string, ID []byte, sender uint32, payloadMessage any) *protobuf.Message {
-	var data []byte
-	var err error
-	switch messageType {
-
-	case "New_View":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.New_View))
-	case "Prepare":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.Prepare))
-	case "Prepare_Vote":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.Prepare_Vote))
-	case "Precommit":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.Precommit))
-	case "Precommit_Vote":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.Precommit_Vote))
-	case "Commit":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.Commit))
-
-	case "TXs_Inform":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.TXs_Inform))
-	case "Sig_Inform":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.Sig_Inform))
-	case "Sigmsg":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.Sigmsg))
-	case "InputBFT_Result":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.InputBFT_Result))
+// ErrUnknownMessageType is returned by Decapsulation for a type nobody registered
+var ErrUnknownMessageType = errors.New("unknown message type")
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]func() proto.Message
+}{factories: make(map[string]func() proto.Message)}
+
+// RegisterMessage makes messageType usable by Encapsulation and Decapsulation,
+// factory returns an empty payload of the type. Protocols register their messages in init,
+// registering the same type twice panics.
+func RegisterMessage(messageType string, factory func() proto.Message) {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.factories[messageType]; ok {
+		panic("message type " + messageType + " is registered twice")
+	}
+	registry.factories[messageType] = factory
+}
 
-	case "NoLiveness":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.NoLiveness))
-	case "NL_Response":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.NL_Response))
-	case "NL_Confirm":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.NL_Confirm))
+// RegisterMessages registers every message type of factories
+func RegisterMessages(factories map[string]func() proto.Message) {
+	for messageType, factory := range factories {
+		RegisterMessage(messageType, factory)
+	}
+}
 
-	case "NoSafety":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.NoSafety))
-	case "NS_Choice":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.NS_Choice))
+// IsRegistered reports whether messageType has been registered
+func IsRegistered(messageType string) bool {
+	_, ok := factoryOf(messageType)
+	return ok
+}
 
-	case "ReConfig":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.ReConfig))
-	case "RC_CheckOK":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.RC_CheckOK))
-	case "RC_NewEpoch":
-		data, err = proto.Marshal((payloadMessage).(*protobuf.RC_NewEpoch))
+func factoryOf(messageType string) (func() proto.Message, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	factory, ok := registry.factories[messageType]
+	return factory, ok
+}
 
+// Encapsulation encapsulates a message to a general type(*protobuf.Message)
+// the payload must be of the registered type, anything else is a programming error
+func Encapsulation(messageType string, ID []byte, sender uint32, payloadMessage any) *protobuf.Message {
+	factory, ok := factoryOf(messageType)
+	if !ok {
+		log.Fatalln(ErrUnknownMessageType, messageType)
 	}
-
+	payload, ok := payloadMessage.(proto.Message)
+	if !ok || payload.ProtoReflect().Descriptor() != factory().ProtoReflect().Descriptor() {
+		log.Fatalf("payload %T does not match message type %s", payloadMessage, messageType)
+	}
+	data, err := proto.Marshal(payload)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -68,88 +74,15 @@ func Encapsulation(messageType string, ID []byte, sender uint32, payloadMessage
 }
 
 // Decapsulation decapsulates a message to it's original type
-func Decapsulation(messageType string, m *protobuf.Message) any {
-	switch messageType {
-	case "New_View":
-		var payloadMessage protobuf.New_View
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "Prepare":
-		var payloadMessage protobuf.Prepare
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "Prepare_Vote":
-		var payloadMessage protobuf.Prepare_Vote
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "Precommit":
-		var payloadMessage protobuf.Precommit
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "Precommit_Vote":
-		var payloadMessage protobuf.Precommit_Vote
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "Commit":
-		var payloadMessage protobuf.Commit
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-
-	case "TXs_Inform":
-		var payloadMessage protobuf.TXs_Inform
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "Sig_Inform":
-		var payloadMessage protobuf.Sig_Inform
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "Sigmsg":
-		var payloadMessage protobuf.Sigmsg
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "InputBFT_Result":
-		var payloadMessage protobuf.InputBFT_Result
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-
-	case "NoLiveness":
-		var payloadMessage protobuf.NoLiveness
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "NL_Response":
-		var payloadMessage protobuf.NL_Response
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "NL_Confirm":
-		var payloadMessage protobuf.NL_Confirm
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-
-	case "NoSafety":
-		var payloadMessage protobuf.NoSafety
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "NS_Choice":
-		var payloadMessage protobuf.NS_Choice
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-
-	case "ReConfig":
-		var payloadMessage protobuf.ReConfig
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "RC_CheckOK":
-		var payloadMessage protobuf.RC_CheckOK
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-	case "RC_NewEpoch":
-		var payloadMessage protobuf.RC_NewEpoch
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
-
-	default:
-		var payloadMessage protobuf.Message
-		proto.Unmarshal(m.Data, &payloadMessage)
-		return &payloadMessage
+// an unknown messageType or a payload that does not parse as it returns an error
+func Decapsulation(messageType string, m *protobuf.Message) (any, error) {
+	factory, ok := factoryOf(messageType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMessageType, messageType)
+	}
+	payload := factory()
+	if err := proto.Unmarshal(m.Data, payload); err != nil {
+		return nil, fmt.Errorf("malformed %s from %d: %v", messageType, m.Sender, err)
 	}
+	return payload, nil
 }
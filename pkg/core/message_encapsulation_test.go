@@ -0,0 +1,46 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	RegisterMessage("Test_Sigmsg", func() proto.Message { return new(protobuf.Sigmsg) })
+}
+
+func TestEncapsulationRoundTrip(t *testing.T) {
+	m := Encapsulation("Test_Sigmsg", []byte{1}, 3, &protobuf.Sigmsg{Root: []byte("root"), Sig: []byte("sig")})
+	raw, err := Decapsulation("Test_Sigmsg", m)
+	if err != nil {
+		t.Fatalf("failed to decapsulate: %v", err)
+	}
+	payload := raw.(*protobuf.Sigmsg)
+	if !bytes.Equal(payload.Root, []byte("root")) || !bytes.Equal(payload.Sig, []byte("sig")) {
+		t.Errorf("unexpected payload %v", payload)
+	}
+}
+
+func TestDecapsulationErrors(t *testing.T) {
+	if _, err := Decapsulation("Unregistered", &protobuf.Message{}); !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("expected ErrUnknownMessageType, got %v", err)
+	}
+	// 字段1的长度前缀超出数据长度
+	m := &protobuf.Message{Type: "Test_Sigmsg", Data: []byte{0x0a, 0x10, 0x01}}
+	if _, err := Decapsulation("Test_Sigmsg", m); err == nil {
+		t.Error("expected an error for a malformed payload")
+	}
+}
+
+func TestRegisterMessageTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a type twice to panic")
+		}
+	}()
+	RegisterMessage("Test_Sigmsg", func() proto.Message { return new(protobuf.Sigmsg) })
+}
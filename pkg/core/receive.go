@@ -47,42 +47,54 @@ func MakeReceiveChannel(port string, Debug bool, num int) chan *protobuf.Message
 		for {
 			//The handle func run forever
 			conn, err3 = lis.AcceptTCP()
-			conn.SetKeepAlive(true)
 			if err3 != nil {
-				log.Fatalln(err3, "In receive.go::go func(),AcceptTCP failed")
+				log.Println(err3, "In receive.go::go func(),AcceptTCP failed")
+				time.Sleep(10 * time.Millisecond)
+				continue
 			}
+			conn.SetKeepAlive(true)
 			//Once connect to a node, make a sub-handle func to handle this connection
-			go func(conn *net.TCPConn, channel chan *protobuf.Message) {
-				for {
-					//Receive bytes
-					lengthBuf := make([]byte, 4)
-					_, err1 := io.ReadFull(conn, lengthBuf)
-					length := utils.BytesToInt(lengthBuf)
-					buf := make([]byte, length)
-					_, err2 := io.ReadFull(conn, buf)
+			go receiveLoop(conn, receiveChannel, num, fileLogger, nil)
+		}
+	}()
+	return receiveChannel
+}
 
-					if err1 != nil || err2 != nil {
-						if num <= 10 || rand.Intn(num) < 10 {
-							log.Printf("The receive channel of %s (from %s) has break down", conn.LocalAddr(), conn.RemoteAddr())
-						}
-						return
-					}
+// receiveLoop reads length-prefixed messages from conn and pushes them to channel until the connection breaks,
+// the peer then reconnects with a new connection
+// when sender is not nil the connection is authenticated and *sender overrides the Sender field of every message
+func receiveLoop(conn net.Conn, channel chan *protobuf.Message, num int, fileLogger *log.Logger, sender *uint32) {
+	for {
+		//Receive bytes
+		lengthBuf := make([]byte, 4)
+		_, err1 := io.ReadFull(conn, lengthBuf)
+		length := utils.BytesToInt(lengthBuf)
+		buf := make([]byte, length)
+		_, err2 := io.ReadFull(conn, buf)
 
-					//Do Unmarshal
-					var m protobuf.Message
-					err3 := proto.Unmarshal(buf, &m)
-					if Debug == true {
-						fileLogger.Println(m)
-					}
-					if err3 != nil {
-						log.Fatalln(err3, "In receive.go::go func(),Unmarshal failed")
-					}
-					//Push protobuf.Message to receivechannel
-					(channel) <- &m
-				}
+		if err1 != nil || err2 != nil {
+			if num <= 10 || rand.Intn(num) < 10 {
+				log.Printf("The receive channel of %s (from %s) has break down", conn.LocalAddr(), conn.RemoteAddr())
+			}
+			conn.Close()
+			return
+		}
 
-			}(conn, receiveChannel)
+		//Do Unmarshal
+		var m protobuf.Message
+		err3 := proto.Unmarshal(buf, &m)
+		if fileLogger != nil {
+			fileLogger.Println(&m)
 		}
-	}()
-	return receiveChannel
+		if err3 != nil {
+			log.Println(err3, "In receive.go::go func(),Unmarshal failed")
+			conn.Close()
+			return
+		}
+		if sender != nil {
+			m.Sender = *sender
+		}
+		//Push protobuf.Message to receivechannel
+		(channel) <- &m
+	}
 }
@@ -2,75 +2,35 @@ package core
 
 import (
 	"Chamael/pkg/protobuf"
-	"Chamael/pkg/utils"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"time"
-
-	"google.golang.org/protobuf/proto"
 )
 
 // MAXMESSAGE is the size of channels
 var MAXMESSAGE = 4096
 
-// MakeSendChannel returns a channel to send messages to hostIP
+// MakeSendChannel returns a channel to send messages to hostIP, the connection is re-established whenever it breaks
 func MakeSendChannel(hostIP string, hostPort string, dirname string, Debug bool) chan *protobuf.Message {
-	var addr *net.TCPAddr
-	var conn *net.TCPConn
-	var err1, err2 error
-	var fileLogger *log.Logger
-	//Retry to connet to node
-	retry := true
-	for retry {
-		addr, err1 = net.ResolveTCPAddr("tcp4", hostIP+":"+hostPort)
-		conn, err2 = net.DialTCP("tcp4", nil, addr)
-		if err1 != nil {
-			retry = true
-			time.Sleep(1000)
-			continue
-		}
-		if err2 != nil {
-			retry = true
-			time.Sleep(1000)
-			continue
-		}
-		retry = false
-
-		conn.SetKeepAlive(true)
-	}
-	//Make the send channel and the handle func
-	sendChannel := make(chan *protobuf.Message, MAXMESSAGE)
-
-	go func(conn *net.TCPConn, channel chan *protobuf.Message) {
-		if Debug == true {
-			filename := fmt.Sprintf("%s/(Send)%s.log", dirname, conn.RemoteAddr())
-			file, _ := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-			fileLogger = log.New(file, "[MessageLogger] ", log.Ldate|log.Ltime|log.Lmicroseconds)
-		}
-		for {
-			//Pop protobuf.Message form sendchannel
-
-			m := <-(channel)
-			if Debug == true {
-				fileLogger.Println(m)
-			}
-			//Do Marshal
-			byt, err1 := proto.Marshal(m)
-			if err1 != nil {
-				log.Fatalln(err1)
-			}
-			//Send bytes
+	return newTCPLink(hostIP+":"+hostPort, dirname, Debug, DefaultLinkOptions).C
+}
 
-			length := len(byt)
-			_, err2 := conn.Write(utils.IntToBytes(length))
-			_, err3 := conn.Write(byt)
-			if err2 != nil || err3 != nil {
-				log.Fatalln("The send channel has break down!", err2)
-			}
-		}
-	}(conn, sendChannel)
+// newTCPLink returns a link to addr over plain TCP
+func newTCPLink(addr string, dirname string, Debug bool, opts LinkOptions) *Link {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 15 * time.Second}
+	return NewLink(addr, func() (net.Conn, error) {
+		return dialer.Dial("tcp4", addr)
+	}, opts, sendLogger(dirname, addr, Debug))
+}
 
-	return sendChannel
+// sendLogger returns the logger of the messages sent to addr, or nil when not debugging
+func sendLogger(dirname string, addr string, Debug bool) *log.Logger {
+	if Debug == false {
+		return nil
+	}
+	filename := fmt.Sprintf("%s/(Send)%s.log", dirname, addr)
+	file, _ := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	return log.New(file, "[MessageLogger] ", log.Ldate|log.Ltime|log.Lmicroseconds)
 }
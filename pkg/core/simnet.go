@@ -0,0 +1,225 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+// LinkConfig describes the conditions of a directed link
+type LinkConfig struct {
+	Latency      int     `yaml:"Latency"`      //one-way latency (ms)
+	Jitter       int     `yaml:"Jitter"`       //latency jitter (ms): the width for uniform, the standard deviation for normal
+	Distribution string  `yaml:"Distribution"` //latency distribution: uniform(default) or normal
+	Bandwidth    int     `yaml:"Bandwidth"`    //bytes per second, 0 means unlimited
+	DropRate     float64 `yaml:"DropRate"`     //probability of dropping a message
+	ReorderRate  float64 `yaml:"ReorderRate"`  //probability of delaying a message by one extra latency so that later messages overtake it
+}
+
+// LinkOverride overrides the default conditions of the link Src -> Dst
+type LinkOverride struct {
+	Src        uint32 `yaml:"Src"`
+	Dst        uint32 `yaml:"Dst"`
+	LinkConfig `yaml:",inline"`
+}
+
+// Partition drops every message between different groups during [Start, End) (ms since the transport is created)
+// parties not listed in any group form a group of their own
+type Partition struct {
+	Start  int        `yaml:"Start"`
+	End    int        `yaml:"End"`
+	Groups [][]uint32 `yaml:"Groups"`
+}
+
+// SimConfig is the configuration of the simulated network
+type SimConfig struct {
+	Seed       int64          `yaml:"Seed"`
+	Default    LinkConfig     `yaml:"Default"`
+	Links      []LinkOverride `yaml:"Links"`
+	Partitions []Partition    `yaml:"Partitions"`
+}
+
+// LoadSimConfig reads a SimConfig from a yaml file
+func LoadSimConfig(filename string) (*SimConfig, error) {
+	byt, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c := &SimConfig{}
+	if err := yaml.Unmarshal(byt, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// link returns the conditions of the link src -> dst
+func (c *SimConfig) link(src uint32, dst uint32) LinkConfig {
+	for _, l := range c.Links {
+		if l.Src == src && l.Dst == dst {
+			return l.LinkConfig
+		}
+	}
+	return c.Default
+}
+
+// partitioned reports whether src and dst are separated by a partition at elapsed
+func (c *SimConfig) partitioned(src uint32, dst uint32, elapsed time.Duration) bool {
+	for _, part := range c.Partitions {
+		if elapsed < time.Duration(part.Start)*time.Millisecond || elapsed >= time.Duration(part.End)*time.Millisecond {
+			continue
+		}
+		if groupOf(part.Groups, src) != groupOf(part.Groups, dst) {
+			return true
+		}
+	}
+	return false
+}
+
+func groupOf(groups [][]uint32, pid uint32) int {
+	for i, g := range groups {
+		for _, id := range g {
+			if id == pid {
+				return i
+			}
+		}
+	}
+	return -1 - int(pid)
+}
+
+// SimTransport wraps another transport and shapes every outgoing link according to a SimConfig.
+// Every link draws from its own random source seeded by (Seed, src, dst), so drops, latencies and
+// reorderings are reproducible for the same sequence of messages on that link.
+type SimTransport struct {
+	inner  Transport
+	pid    uint32
+	config *SimConfig
+	start  time.Time
+}
+
+// NewSimTransport wraps the transport of party pid
+func NewSimTransport(inner Transport, pid uint32, config *SimConfig) *SimTransport {
+	return &SimTransport{
+		inner:  inner,
+		pid:    pid,
+		config: config,
+		start:  time.Now(),
+	}
+}
+
+// Listen receives from the wrapped transport
+func (t *SimTransport) Listen() (chan *protobuf.Message, error) {
+	return t.inner.Listen()
+}
+
+// Dial returns a send channel whose messages reach party des after the simulated link
+func (t *SimTransport) Dial(des uint32) (chan *protobuf.Message, error) {
+	out, err := t.inner.Dial(des)
+	if err != nil {
+		return nil, err
+	}
+	// messages to itself are not shaped
+	if des == t.pid {
+		return out, nil
+	}
+
+	l := &simLink{
+		config: t.config.link(t.pid, des),
+		rng:    rand.New(rand.NewSource(t.config.Seed ^ int64(t.pid)<<32 ^ int64(des))),
+		queue:  make(chan simDelivery, MAXMESSAGE),
+		out:    out,
+	}
+	sendChannel := make(chan *protobuf.Message, MAXMESSAGE)
+	go l.deliver()
+	go func() {
+		for m := range sendChannel {
+			if t.config.partitioned(t.pid, des, time.Since(t.start)) {
+				continue
+			}
+			l.schedule(m)
+		}
+	}()
+	return sendChannel, nil
+}
+
+// LinkState returns the state of the wrapped link, a partitioned link is reported as disconnected
+func (t *SimTransport) LinkState(des uint32) LinkState {
+	if t.config.partitioned(t.pid, des, time.Since(t.start)) {
+		return LinkDisconnected
+	}
+	if inner, ok := t.inner.(LinkMonitor); ok {
+		return inner.LinkState(des)
+	}
+	return LinkConnected
+}
+
+type simDelivery struct {
+	at time.Time
+	m  *protobuf.Message
+}
+
+type simLink struct {
+	config LinkConfig
+	rng    *rand.Rand
+
+	busyUntil   time.Time // the link transmits one message at a time when the bandwidth is limited
+	lastDeliver time.Time // messages that are not reordered keep FIFO order
+	queue       chan simDelivery
+	out         chan *protobuf.Message
+}
+
+// delay samples the propagation delay of one message
+func (l *simLink) delay() time.Duration {
+	latency := float64(l.config.Latency)
+	jitter := float64(l.config.Jitter)
+	var d float64
+	switch l.config.Distribution {
+	case "normal":
+		d = latency + l.rng.NormFloat64()*jitter
+	default:
+		d = latency + l.rng.Float64()*jitter
+	}
+	d = math.Max(d, 0)
+	return time.Duration(d * float64(time.Millisecond))
+}
+
+// schedule decides the fate of m and queues it for delivery
+func (l *simLink) schedule(m *protobuf.Message) {
+	if l.rng.Float64() < l.config.DropRate {
+		return
+	}
+	now := time.Now()
+	sendAt := now
+	if l.config.Bandwidth > 0 {
+		if l.busyUntil.After(sendAt) {
+			sendAt = l.busyUntil
+		}
+		size := proto.Size(m)
+		l.busyUntil = sendAt.Add(time.Duration(float64(size) / float64(l.config.Bandwidth) * float64(time.Second)))
+		sendAt = l.busyUntil
+	}
+	at := sendAt.Add(l.delay())
+
+	if l.rng.Float64() < l.config.ReorderRate {
+		at = at.Add(time.Duration(l.config.Latency) * time.Millisecond)
+		time.AfterFunc(at.Sub(now), func() { l.out <- m })
+		return
+	}
+	if at.Before(l.lastDeliver) {
+		at = l.lastDeliver
+	}
+	l.lastDeliver = at
+	l.queue <- simDelivery{at: at, m: m}
+}
+
+// deliver forwards the in-order messages when their time comes
+func (l *simLink) deliver() {
+	for d := range l.queue {
+		time.Sleep(time.Until(d.at))
+		l.out <- d.m
+	}
+}
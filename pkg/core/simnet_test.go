@@ -0,0 +1,66 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// runLink sends count messages from party 0 to party 1 and returns the IDs received within wait
+func runLink(t *testing.T, config *SimConfig, count int, wait time.Duration) []uint32 {
+	net := NewMemoryNetwork(2)
+	sender := NewSimTransport(net.Transport(0), 0, config)
+	inbox, _ := net.Transport(1).Listen()
+	send, err := sender.Dial(1)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	for i := 0; i < count; i++ {
+		send <- &protobuf.Message{Type: "Test", Id: utils.Uint32ToBytes(uint32(i))}
+	}
+
+	var received []uint32
+	timeout := time.After(wait)
+	for {
+		select {
+		case m := <-inbox:
+			received = append(received, utils.BytesToUint32(m.Id))
+		case <-timeout:
+			return received
+		}
+	}
+}
+
+func TestSimTransportDeterministicDrops(t *testing.T) {
+	config := &SimConfig{Seed: 7, Default: LinkConfig{Latency: 1, Jitter: 2, DropRate: 0.3}}
+	first := runLink(t, config, 200, 200*time.Millisecond)
+	second := runLink(t, config, 200, 200*time.Millisecond)
+
+	if len(first) == 0 || len(first) == 200 {
+		t.Fatalf("expected some but not all messages to be dropped, got %d", len(first))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("expected the same seed to drop the same messages")
+	}
+	// 不乱序时保持 FIFO
+	for i := 1; i < len(first); i++ {
+		if first[i] <= first[i-1] {
+			t.Fatalf("expected FIFO delivery, got %v", first)
+		}
+	}
+}
+
+func TestSimTransportLatencyAndPartition(t *testing.T) {
+	config := &SimConfig{Default: LinkConfig{Latency: 50}}
+	start := time.Now()
+	if got := runLink(t, config, 1, 30*time.Millisecond); len(got) != 0 {
+		t.Errorf("expected message to be delayed by the link latency, received after %v", time.Since(start))
+	}
+
+	config = &SimConfig{Partitions: []Partition{{Start: 0, End: 1000, Groups: [][]uint32{{0}, {1}}}}}
+	if got := runLink(t, config, 10, 50*time.Millisecond); len(got) != 0 {
+		t.Errorf("expected messages across the partition to be dropped, got %v", got)
+	}
+}
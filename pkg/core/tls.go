@@ -0,0 +1,198 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"Chamael/pkg/utils"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing/bn256"
+	"go.dedis.ch/kyber/v3/sign/bls"
+)
+
+// oidNodeIdentity is the certificate extension binding the TLS key to a node: PID || BLS signature
+var oidNodeIdentity = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 59999, 1}
+
+// identityDigest is the content signed with the BLS key of node pid to endorse a TLS public key
+func identityDigest(pid uint32, tlsKey []byte) []byte {
+	return utils.MessageEncap([][]byte{[]byte("Chamael-TLS"), utils.Uint32ToBytes(pid), tlsKey})
+}
+
+// TLSTransport is a TCP transport secured by mutual TLS.
+// Every node uses a fresh ed25519 certificate endorsed by its BLS key from the config,
+// so a connection is accepted only from a peer holding the secret key of the PID it claims,
+// and the receiver stamps that PID onto every message instead of trusting m.Sender.
+type TLSTransport struct {
+	pid      uint32
+	ipList   []string
+	portList []string
+	debug    bool
+	dirname  string
+	linkSet
+
+	pk   []kyber.Point
+	cert tls.Certificate
+}
+
+// NewTLSTransport creates the certificate of party pid endorsed by sk, pk are the BLS public keys of all parties
+func NewTLSTransport(pid uint32, ipList []string, portList []string, sk kyber.Scalar, pk []kyber.Point, debug bool) (*TLSTransport, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := bls.Sign(bn256.NewSuite(), sk, identityDigest(pid, pub))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(pid) + 1),
+		Subject:      pkix.Name{CommonName: fmt.Sprintf("Chamael node %d", pid)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * 365 * time.Hour),
+		ExtraExtensions: []pkix.Extension{{
+			Id:    oidNodeIdentity,
+			Value: append(utils.Uint32ToBytes(pid), sig...),
+		}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, pub, priv)
+	if err != nil {
+		return nil, err
+	}
+	return &TLSTransport{
+		pid:      pid,
+		ipList:   ipList,
+		portList: portList,
+		debug:    debug,
+		pk:       pk,
+		cert:     tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv},
+	}, nil
+}
+
+// peerID checks that the certificate is endorsed by the BLS key of the PID it carries and returns that PID
+func (t *TLSTransport) peerID(rawCerts [][]byte) (uint32, error) {
+	if len(rawCerts) == 0 {
+		return 0, errors.New("peer presented no certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return 0, err
+	}
+	tlsKey, ok := cert.PublicKey.(ed25519.PublicKey)
+	if !ok {
+		return 0, errors.New("peer certificate is not ed25519")
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidNodeIdentity) || len(ext.Value) < 4 {
+			continue
+		}
+		pid := utils.BytesToUint32(ext.Value[:4])
+		if int(pid) >= len(t.pk) {
+			return 0, errors.New("peer PID is out of range")
+		}
+		if err := bls.Verify(bn256.NewSuite(), t.pk[pid], identityDigest(pid, tlsKey), ext.Value[4:]); err != nil {
+			return 0, fmt.Errorf("peer certificate is not endorsed by node %d: %v", pid, err)
+		}
+		return pid, nil
+	}
+	return 0, errors.New("peer certificate carries no node identity")
+}
+
+// config returns the TLS config, expect is the PID the peer must prove, -1 accepts any node
+func (t *TLSTransport) config(expect int) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{t.cert},
+		MinVersion:   tls.VersionTLS13,
+		ClientAuth:   tls.RequireAnyClientCert,
+		// certificates are self-signed, the chain of trust is the BLS endorsement checked below
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			pid, err := t.peerID(rawCerts)
+			if err != nil {
+				return err
+			}
+			if expect >= 0 && pid != uint32(expect) {
+				return fmt.Errorf("expected node %d, peer proved node %d", expect, pid)
+			}
+			return nil
+		},
+	}
+}
+
+// Listen accepts mutually authenticated connections
+func (t *TLSTransport) Listen() (chan *protobuf.Message, error) {
+	lis, err := tls.Listen("tcp4", ":"+t.portList[t.pid], t.config(-1))
+	if err != nil {
+		return nil, err
+	}
+	log.Println("create tls listener", lis.Addr(), "success")
+	receiveChannel := make(chan *protobuf.Message, MAXMESSAGE)
+	go func() {
+		var fileLogger *log.Logger
+		if t.debug {
+			homeDir, _ := os.UserHomeDir()
+			filename := fmt.Sprintf("%s/Chamael/log/(Received)%s.log", homeDir, lis.Addr())
+			file, _ := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+			fileLogger = log.New(file, "[MessageLogger] ", log.Ldate|log.Ltime|log.Lmicroseconds)
+		}
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				log.Println(err, "In tls.go::Listen(),Accept failed")
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			go func(conn *tls.Conn) {
+				if err := conn.Handshake(); err != nil {
+					log.Println("TLS handshake from", conn.RemoteAddr(), "failed:", err)
+					conn.Close()
+					return
+				}
+				pid, _ := t.peerID(peerCerts(conn.ConnectionState()))
+				receiveLoop(conn, receiveChannel, len(t.portList), fileLogger, &pid)
+			}(conn.(*tls.Conn))
+		}
+	}()
+	return receiveChannel, nil
+}
+
+// peerCerts returns the raw certificates of the peer
+func peerCerts(state tls.ConnectionState) [][]byte {
+	var raw [][]byte
+	for _, c := range state.PeerCertificates {
+		raw = append(raw, c.Raw)
+	}
+	return raw
+}
+
+// Dial returns the link to party des, every connection of the link must prove to be des
+func (t *TLSTransport) Dial(des uint32) (chan *protobuf.Message, error) {
+	if int(des) >= len(t.ipList) {
+		return nil, errors.New("Destination id is too large")
+	}
+	if t.debug && t.dirname == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		t.dirname = fmt.Sprintf(homeDir+"/Chamael/log/%s", t.ipList[t.pid]+":"+t.portList[t.pid])
+		os.Mkdir(t.dirname, 0755)
+	}
+	addr := t.ipList[des] + ":" + t.portList[des]
+	dialer := &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 15 * time.Second}
+	config := t.config(int(des))
+	return t.add(des, NewLink(addr, func() (net.Conn, error) {
+		return tls.DialWithDialer(dialer, "tcp4", addr, config)
+	}, t.opts, sendLogger(t.dirname, addr, t.debug))), nil
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"net"
+	"testing"
+	"time"
+
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/pairing"
+	"go.dedis.ch/kyber/v3/sign/bls"
+)
+
+// freePort returns a local port that is not in use
+func freePort(t *testing.T) string {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer lis.Close()
+	_, port, _ := net.SplitHostPort(lis.Addr().String())
+	return port
+}
+
+func TestTLSTransportStampsAuthenticatedSender(t *testing.T) {
+	suite := pairing.NewSuiteBn256()
+	sks := make([]kyber.Scalar, 2)
+	pks := make([]kyber.Point, 2)
+	for i := range sks {
+		sks[i], pks[i] = bls.NewKeyPair(suite, suite.RandomStream())
+	}
+	ipList := []string{"127.0.0.1", "127.0.0.1"}
+	portList := []string{freePort(t), freePort(t)}
+
+	receiver, err := NewTLSTransport(0, ipList, portList, sks[0], pks, false)
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+	inbox, err := receiver.Listen()
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	// 冒充节点1但没有其私钥的连接无法通过握手
+	impostor, _ := NewTLSTransport(1, ipList, portList, sks[0], pks, false)
+	go func() {
+		send, _ := impostor.Dial(0)
+		send <- &protobuf.Message{Type: "Impostor", Sender: 1}
+	}()
+
+	sender, _ := NewTLSTransport(1, ipList, portList, sks[1], pks, false)
+	send, err := sender.Dial(0)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	send <- &protobuf.Message{Type: "Test", Sender: 0}
+
+	select {
+	case m := <-inbox:
+		if m.Type != "Test" {
+			t.Fatalf("expected only the authenticated message, got %s", m.Type)
+		}
+		if m.Sender != 1 {
+			t.Errorf("expected the sender to be stamped as 1, got %d", m.Sender)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not received")
+	}
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"Chamael/pkg/protobuf"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Transport is the message layer between parties.
+// Listen returns the channel of messages sent to this party, Dial returns the channel used to send messages to party des.
+type Transport interface {
+	Listen() (chan *protobuf.Message, error)
+	Dial(des uint32) (chan *protobuf.Message, error)
+}
+
+// TCPTransport sends length-prefixed protobuf messages over TCP
+type TCPTransport struct {
+	pid      uint32
+	ipList   []string
+	portList []string
+	debug    bool
+	dirname  string
+	linkSet
+}
+
+// NewTCPTransport returns the TCP transport of party pid, ipList and portList are indexed by PID
+func NewTCPTransport(pid uint32, ipList []string, portList []string, debug bool) *TCPTransport {
+	return &TCPTransport{
+		pid:      pid,
+		ipList:   ipList,
+		portList: portList,
+		debug:    debug,
+	}
+}
+
+// Listen creates the TCP listener of this party
+func (t *TCPTransport) Listen() (chan *protobuf.Message, error) {
+	return MakeReceiveChannel(t.portList[t.pid], t.debug, len(t.portList)), nil
+}
+
+// Dial returns the link to party des, which connects in the background and reconnects whenever the connection breaks
+func (t *TCPTransport) Dial(des uint32) (chan *protobuf.Message, error) {
+	if int(des) >= len(t.ipList) {
+		return nil, errors.New("Destination id is too large")
+	}
+	if t.debug && t.dirname == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		t.dirname = fmt.Sprintf(homeDir+"/Chamael/log/%s", t.ipList[t.pid]+":"+t.portList[t.pid])
+		os.Mkdir(t.dirname, 0755)
+	}
+	return t.add(des, newTCPLink(t.ipList[des]+":"+t.portList[des], t.dirname, t.debug, t.opts)), nil
+}
+
+// MemoryNetwork connects parties of the same process through channels, used to run whole systems inside one test
+type MemoryNetwork struct {
+	inboxes []chan *protobuf.Message
+}
+
+// NewMemoryNetwork creates an in-memory network of n parties
+func NewMemoryNetwork(n int) *MemoryNetwork {
+	net := &MemoryNetwork{inboxes: make([]chan *protobuf.Message, n)}
+	for i := range net.inboxes {
+		net.inboxes[i] = make(chan *protobuf.Message, MAXMESSAGE)
+	}
+	return net
+}
+
+// Transport returns the transport of party pid on this network
+func (net *MemoryNetwork) Transport(pid uint32) Transport {
+	return &memoryTransport{net: net, pid: pid}
+}
+
+type memoryTransport struct {
+	net *MemoryNetwork
+	pid uint32
+}
+
+func (t *memoryTransport) Listen() (chan *protobuf.Message, error) {
+	return t.net.inboxes[t.pid], nil
+}
+
+// Dial returns a send channel whose messages are copied into the inbox of des,
+// so that parties never share a message like they would not over TCP
+func (t *memoryTransport) Dial(des uint32) (chan *protobuf.Message, error) {
+	if int(des) >= len(t.net.inboxes) {
+		return nil, errors.New("Destination id is too large")
+	}
+	sendChannel := make(chan *protobuf.Message, MAXMESSAGE)
+	go func(inbox chan *protobuf.Message) {
+		for m := range sendChannel {
+			inbox <- proto.Clone(m).(*protobuf.Message)
+		}
+	}(t.net.inboxes[des])
+	return sendChannel, nil
+}
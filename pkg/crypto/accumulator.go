@@ -1,10 +1,18 @@
 package crypto
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
+	"fmt"
 	"math/big"
-	"strings"
+	"runtime"
+	"sync"
+)
+
+var (
+	ErrMember    = errors.New("element is a member of the accumulated set")
+	ErrNotMember = errors.New("element is not a member of the accumulated set")
+	// ErrPartialHistory 从其他值继续累加的累加器不知道之前累加的元素
+	ErrPartialHistory = errors.New("accumulator does not know the elements accumulated before it was resumed")
 )
 
 // Setup 存储 RSA accumulator 的参数
@@ -32,7 +40,7 @@ const (
 )
 
 // TrustedSetup 返回一个 RSA accumulator 参数（2048 位）
-// 注意：仅用于 demo，切勿在生产环境中使用固定常数
+// 注意：仅用于 demo，切勿在生产环境中使用固定常数；部署时用 GenerateSetup（cmd/accSetup）生成参数
 func TrustedSetup() *Setup {
 	ret := &Setup{
 		N: new(big.Int),
@@ -65,15 +73,6 @@ func AccWithoutProve(set []string, encodeType EncodeType, setup *Setup) (*big.In
 	return acc, reps
 }
 
-// 快速构造 Acc
-func FastAcc(set []string, encodeType EncodeType, setup *Setup) *big.Int {
-	// 将 set 所有元素计算为一个哈希值
-	hash := sha256.Sum256([]byte(strings.Join(set, "")))
-
-	acc, _ := AccWithoutProve([]string{hex.EncodeToString(hash[:])}, encodeType, setup)
-	return acc
-}
-
 // AccumulateNew 计算 base^exp mod N
 func AccumulateNew(base, exp, N *big.Int) *big.Int {
 	return new(big.Int).Exp(base, exp, N)
@@ -83,15 +82,293 @@ func AccumulateNew(base, exp, N *big.Int) *big.Int {
 // 对于每个代表 r，其 proof = g^(（全体代表数乘积）/ r) mod N
 func ProveMembership(g, N *big.Int, reps []*big.Int) []*big.Int {
 	proofs := make([]*big.Int, len(reps))
+	if len(reps) > 0 {
+		rootFactor(g, N, reps, proofs)
+	}
+	return proofs
+}
+
+// rootFactor 分治计算 membership proof: 左半部分的 proof 以 g^(右半部分乘积) 为底递归, 右半部分同理,
+// 共需 O(n log n) 次模幂而不是逐个计算的 O(n^2)
+func rootFactor(g, N *big.Int, reps []*big.Int, proofs []*big.Int) {
+	if len(reps) == 1 {
+		proofs[0] = g
+		return
+	}
+	half := len(reps) / 2
+	left, right := product(reps[:half]), product(reps[half:])
+	rootFactor(new(big.Int).Exp(g, right, N), N, reps[:half], proofs[:half])
+	rootFactor(new(big.Int).Exp(g, left, N), N, reps[half:], proofs[half:])
+}
+
+// product 计算代表数的乘积
+func product(reps []*big.Int) *big.Int {
 	prod := big.NewInt(1)
 	for _, r := range reps {
 		prod.Mul(prod, r)
 	}
-	for i, r := range reps {
-		quotient := new(big.Int).Div(prod, r)
-		proofs[i] = new(big.Int).Exp(g, quotient, N)
+	return prod
+}
+
+// VerifyMembership 验证 witness 是代表数 rep 的 membership proof: witness^rep = acc mod N
+func VerifyMembership(setup *Setup, acc, rep, witness *big.Int) bool {
+	return new(big.Int).Exp(witness, rep, setup.N).Cmp(acc) == 0
+}
+
+// NonMembershipProof 代表数 x 不在累加集合中的证明. 集合代表数乘积 u 与 x 互素时,
+// 由 Bezout 等式 a*u + b*x = 1 (0 <= a < x) 取 B = g^b, 验证 acc^a * B^x = g mod N
+type NonMembershipProof struct {
+	A *big.Int
+	B *big.Int
+}
+
+// ProveNonMembership 为不在 reps 中的代表数 x 生成 non-membership proof, x 整除集合乘积时返回 ErrMember
+func ProveNonMembership(setup *Setup, reps []*big.Int, x *big.Int) (*NonMembershipProof, error) {
+	u := product(reps)
+	a, b := new(big.Int), new(big.Int)
+	if new(big.Int).GCD(a, b, u, x).Cmp(big.NewInt(1)) != 0 {
+		return nil, ErrMember
 	}
-	return proofs
+	// 把 a 规约到 [0, x), b 相应调整为 (1 - a*u) / x, 使证明中的 A 较短
+	a.Mod(a, x)
+	b.Sub(big.NewInt(1), new(big.Int).Mul(a, u))
+	b.Quo(b, x)
+
+	B, err := expSigned(setup.G, b, setup.N)
+	if err != nil {
+		return nil, err
+	}
+	return &NonMembershipProof{A: a, B: B}, nil
+}
+
+// VerifyNonMembership 验证 proof 证明了代表数 x 不在 acc 累加的集合中
+func VerifyNonMembership(setup *Setup, acc, x *big.Int, proof *NonMembershipProof) bool {
+	if proof == nil || proof.A == nil || proof.B == nil || proof.A.Sign() < 0 || proof.A.Cmp(x) >= 0 {
+		return false
+	}
+	lhs := new(big.Int).Exp(acc, proof.A, setup.N)
+	lhs.Mul(lhs, new(big.Int).Exp(proof.B, x, setup.N))
+	lhs.Mod(lhs, setup.N)
+	return lhs.Cmp(new(big.Int).Mod(setup.G, setup.N)) == 0
+}
+
+// expSigned 计算 base^exp mod N, exp 为负数时使用 base 的模逆
+func expSigned(base, exp, N *big.Int) (*big.Int, error) {
+	if exp.Sign() >= 0 {
+		return new(big.Int).Exp(base, exp, N), nil
+	}
+	inv := new(big.Int).ModInverse(base, N)
+	if inv == nil {
+		return nil, errors.New("base is not invertible modulo N")
+	}
+	return new(big.Int).Exp(inv, new(big.Int).Neg(exp), N), nil
+}
+
+// Accumulator 在 base 的基础上累加一组元素(如各 epoch 提交的交易编码)各自的代表数, value = base^(代表数乘积) mod N.
+// 新建的累加器 base 为 g; 从上一个值继续累加时 base 为该值, 只保存之后加入的元素, 仍可为它们生成 witness
+type Accumulator struct {
+	setup      *Setup
+	encodeType EncodeType
+	base       *big.Int
+	reps       []*big.Int
+	index      map[string]int // 元素 -> 代表数在 reps 中的位置
+	elements   []string
+	value      *big.Int
+}
+
+// NewAccumulator 累加 set 中每个元素的代表数, acc = g^(所有代表数乘积) mod N, 重复的元素只累加一次
+func NewAccumulator(set []string, encodeType EncodeType, setup *Setup) *Accumulator {
+	a := ResumeAccumulator(nil, encodeType, setup)
+	var unique []string
+	for _, v := range set {
+		if !a.Contains(v) {
+			a.index[v] = len(unique)
+			unique = append(unique, v)
+		}
+	}
+	a.elements = unique
+	a.reps = GenRepresentatives(unique, encodeType)
+	a.value = AccumulateNew(a.base, product(a.reps), setup.N)
+	return a
+}
+
+// ResumeAccumulator 从累加器的值 value 继续累加, value 为 nil 时从 g 开始
+func ResumeAccumulator(value *big.Int, encodeType EncodeType, setup *Setup) *Accumulator {
+	base := setup.G
+	if value != nil {
+		base = value
+	}
+	return &Accumulator{
+		setup:      setup,
+		encodeType: encodeType,
+		base:       new(big.Int).Set(base),
+		index:      make(map[string]int),
+		value:      new(big.Int).Set(base),
+	}
+}
+
+// Value 累加器的值
+func (a *Accumulator) Value() *big.Int {
+	return new(big.Int).Set(a.value)
+}
+
+// Contains 报告元素是否被累加
+func (a *Accumulator) Contains(element string) bool {
+	_, ok := a.index[element]
+	return ok
+}
+
+// Add 把一批新元素并入累加器: value' = value^(新代表数乘积), 元素已被累加或重复时返回 ErrMember 且不做修改.
+// 已有元素的 witness 用 UpdateWitnessOnAdd 更新
+func (a *Accumulator) Add(elements []string) error {
+	_, _, err := a.add(elements)
+	return err
+}
+
+// AddAndProve 与 Add 相同, 同时返回 value^x = value' 的 PoE 和新元素代表数的乘积 x.
+// 知道元素的节点用 VerifyAdd 检查更新, 只拿到 x 的节点用 VerifyPoE 检查, 都只需两次短指数模幂
+func (a *Accumulator) AddAndProve(elements []string) (*PoE, *big.Int, error) {
+	old, x, err := a.add(elements)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ProvePoE(a.setup, old, x, a.value), x, nil
+}
+
+func (a *Accumulator) add(elements []string) (*big.Int, *big.Int, error) {
+	seen := make(map[string]bool, len(elements))
+	for _, v := range elements {
+		if a.Contains(v) || seen[v] {
+			return nil, nil, fmt.Errorf("%w: %q", ErrMember, v)
+		}
+		seen[v] = true
+	}
+	reps := GenRepresentatives(elements, a.encodeType)
+	for i, v := range elements {
+		a.index[v] = len(a.reps)
+		a.reps = append(a.reps, reps[i])
+		a.elements = append(a.elements, v)
+	}
+	old, x := a.value, product(reps)
+	a.value = AccumulateNew(old, x, a.setup.N)
+	return old, x, nil
+}
+
+// Delete 从累加器删除一批元素, 没有陷门时需要用剩余元素从 base 重新计算, 只能删除 base 之后加入的元素,
+// 否则返回 ErrNotMember 且不做修改. 其余元素的 witness 用 UpdateWitnessOnDelete 更新
+func (a *Accumulator) Delete(elements []string) error {
+	_, _, err := a.delete(elements)
+	return err
+}
+
+// DeleteAndProve 与 Delete 相同, 同时返回 value'^x = value 的 PoE, 其他节点用 VerifyDelete 检查更新
+func (a *Accumulator) DeleteAndProve(elements []string) (*PoE, error) {
+	old, x, err := a.delete(elements)
+	if err != nil {
+		return nil, err
+	}
+	return ProvePoE(a.setup, a.value, x, old), nil
+}
+
+func (a *Accumulator) delete(elements []string) (*big.Int, *big.Int, error) {
+	removed := make(map[string]bool, len(elements))
+	for _, v := range elements {
+		if !a.Contains(v) || removed[v] {
+			return nil, nil, fmt.Errorf("%w: %q", ErrNotMember, v)
+		}
+		removed[v] = true
+	}
+	x := big.NewInt(1)
+	var reps []*big.Int
+	var remaining []string
+	index := make(map[string]int, len(a.elements)-len(removed))
+	for i, v := range a.elements {
+		if removed[v] {
+			x.Mul(x, a.reps[i])
+			continue
+		}
+		index[v] = len(reps)
+		reps = append(reps, a.reps[i])
+		remaining = append(remaining, v)
+	}
+	old := a.value
+	a.reps, a.elements, a.index = reps, remaining, index
+	a.value = AccumulateNew(a.base, product(reps), a.setup.N)
+	return old, x, nil
+}
+
+// ProveMembership 为单个元素生成 membership witness, 元素未被累加时返回 ErrNotMember
+func (a *Accumulator) ProveMembership(element string) (*big.Int, error) {
+	i, ok := a.index[element]
+	if !ok {
+		return nil, ErrNotMember
+	}
+	others := product(a.reps[:i])
+	others.Mul(others, product(a.reps[i+1:]))
+	return AccumulateNew(a.base, others, a.setup.N), nil
+}
+
+// Witnesses 按元素加入的顺序为所有元素生成 membership witness
+func (a *Accumulator) Witnesses() []*big.Int {
+	return ProveMembership(a.base, a.setup.N, a.reps)
+}
+
+// ProveNonMembership 为未被累加的元素生成 non-membership proof. 从其他值继续累加的累加器不知道 base 中的元素,
+// 此时返回 ErrPartialHistory
+func (a *Accumulator) ProveNonMembership(element string) (*NonMembershipProof, error) {
+	if a.base.Cmp(a.setup.G) != 0 {
+		return nil, ErrPartialHistory
+	}
+	return ProveNonMembership(a.setup, a.reps, a.rep(element))
+}
+
+// rep 元素的代表数
+func (a *Accumulator) rep(element string) *big.Int {
+	return GenRepresentatives([]string{element}, a.encodeType)[0]
+}
+
+// UpdateWitnessOnAdd 累加器加入代表数 added 后更新已有成员的 witness: w' = w^(added 乘积)
+func UpdateWitnessOnAdd(setup *Setup, witness *big.Int, added []*big.Int) *big.Int {
+	return AccumulateNew(witness, product(added), setup.N)
+}
+
+// UpdateWitnessOnDelete 累加器删除代表数 deleted 得到 acc 后更新成员 rep 的 witness.
+// 由 a*rep + b*x = 1 (x 为 deleted 乘积) 得 w' = w^b * acc^a, rep 本身被删除时返回 ErrNotMember
+func UpdateWitnessOnDelete(setup *Setup, witness, rep, acc *big.Int, deleted []*big.Int) (*big.Int, error) {
+	x := product(deleted)
+	a, b := new(big.Int), new(big.Int)
+	if new(big.Int).GCD(a, b, rep, x).Cmp(big.NewInt(1)) != 0 {
+		return nil, ErrNotMember
+	}
+	wb, err := expSigned(witness, b, setup.N)
+	if err != nil {
+		return nil, err
+	}
+	acca, err := expSigned(acc, a, setup.N)
+	if err != nil {
+		return nil, err
+	}
+	return wb.Mul(wb, acca).Mod(wb, setup.N), nil
+}
+
+// VerifyAdd 验证累加器的值从 from 加入 elements 后为 to
+func VerifyAdd(setup *Setup, encodeType EncodeType, from, to *big.Int, elements []string, proof *PoE) bool {
+	return VerifyPoE(setup, from, product(GenRepresentatives(elements, encodeType)), to, proof)
+}
+
+// VerifyDelete 验证累加器的值从 from 删除 elements 后为 to
+func VerifyDelete(setup *Setup, encodeType EncodeType, from, to *big.Int, elements []string, proof *PoE) bool {
+	return VerifyPoE(setup, to, product(GenRepresentatives(elements, encodeType)), from, proof)
+}
+
+// VerifyElementMembership 验证 witness 证明了元素被 acc 累加
+func VerifyElementMembership(setup *Setup, encodeType EncodeType, acc *big.Int, element string, witness *big.Int) bool {
+	return VerifyMembership(setup, acc, GenRepresentatives([]string{element}, encodeType)[0], witness)
+}
+
+// VerifyElementNonMembership 验证 proof 证明了元素未被 acc 累加
+func VerifyElementNonMembership(setup *Setup, encodeType EncodeType, acc *big.Int, element string, proof *NonMembershipProof) bool {
+	return VerifyNonMembership(setup, acc, GenRepresentatives([]string{element}, encodeType)[0], proof)
 }
 
 // GenRepresentatives 根据指定的编码方式为输入集合生成代表数
@@ -105,22 +382,23 @@ func GenRepresentatives(set []string, encodeType EncodeType) []*big.Int {
 	}
 }
 
-// genRepWithHashToPrimeFromSHA256 对集合中每个元素调用 HashToPrime 生成代表数
+// genRepWithHashToPrimeFromSHA256 对集合中每个元素调用 HashToPrime 生成代表数, 元素较多时分给多个 goroutine 计算
 func genRepWithHashToPrimeFromSHA256(set []string) []*big.Int {
 	reps := make([]*big.Int, len(set))
-	for i, v := range set {
-		reps[i] = HashToPrime([]byte(v))
+	workers := runtime.NumCPU()
+	if workers > len(set) {
+		workers = len(set)
 	}
-	return reps
-}
-
-// HashToPrime 对输入数据采用 SHA256 哈希，然后找到不小于该哈希值的下一个质数
-func HashToPrime(data []byte) *big.Int {
-	hash := sha256.Sum256(data)
-	n := new(big.Int).SetBytes(hash[:])
-	// 保证 n 为质数
-	for !n.ProbablyPrime(20) {
-		n.Add(n, big.NewInt(1))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(set); i += workers {
+				reps[i] = HashToPrime([]byte(set[i]))
+			}
+		}(w)
 	}
-	return n
+	wg.Wait()
+	return reps
 }
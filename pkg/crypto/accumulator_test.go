@@ -0,0 +1,121 @@
+package crypto
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestAccumulatorMembership(t *testing.T) {
+	setup := TrustedSetup()
+	set := []string{"<Dummy TX: xxxx01>", "<Dummy TX: xxxx02>", "<Dummy TX: xxxx03>", "<Dummy TX: xxxx04>", "<Dummy TX: xxxx05>"}
+	acc := NewAccumulator(set, HashToPrimeFromSha256, setup)
+
+	witnesses := acc.Witnesses()
+	for i, v := range set {
+		w, err := acc.ProveMembership(v)
+		if err != nil {
+			t.Fatalf("failed to prove membership of %s: %v", v, err)
+		}
+		if w.Cmp(witnesses[i]) != 0 {
+			t.Errorf("witness of %s differs from the batch witness", v)
+		}
+		if !VerifyElementMembership(setup, HashToPrimeFromSha256, acc.Value(), v, w) {
+			t.Errorf("membership of %s did not verify", v)
+		}
+		// 一个元素的证明不能用于另一个元素
+		if VerifyElementMembership(setup, HashToPrimeFromSha256, acc.Value(), set[(i+1)%len(set)], w) {
+			t.Errorf("witness of %s verified for another element", v)
+		}
+	}
+	if _, err := acc.ProveMembership("<Dummy TX: xxxx06>"); !errors.Is(err, ErrNotMember) {
+		t.Errorf("expected ErrNotMember, got %v", err)
+	}
+}
+
+func TestAccumulatorNonMembership(t *testing.T) {
+	setup := TrustedSetup()
+	set := []string{"<Dummy TX: xxxx01>", "<Dummy TX: xxxx02>", "<Dummy TX: xxxx03>"}
+	acc := NewAccumulator(set, HashToPrimeFromSha256, setup)
+
+	proof, err := acc.ProveNonMembership("<Dummy TX: xxxx04>")
+	if err != nil {
+		t.Fatalf("failed to prove non-membership: %v", err)
+	}
+	if !VerifyElementNonMembership(setup, HashToPrimeFromSha256, acc.Value(), "<Dummy TX: xxxx04>", proof) {
+		t.Error("non-membership did not verify")
+	}
+	if VerifyElementNonMembership(setup, HashToPrimeFromSha256, acc.Value(), "<Dummy TX: xxxx05>", proof) {
+		t.Error("non-membership proof verified for another element")
+	}
+	if VerifyElementNonMembership(setup, HashToPrimeFromSha256, acc.Value(), "<Dummy TX: xxxx04>", &NonMembershipProof{A: proof.A, B: big.NewInt(2)}) {
+		t.Error("forged non-membership proof verified")
+	}
+	if _, err := acc.ProveNonMembership(set[1]); !errors.Is(err, ErrMember) {
+		t.Errorf("expected ErrMember for an accumulated element, got %v", err)
+	}
+}
+
+func TestAccumulatorIncrementalUpdates(t *testing.T) {
+	setup := TrustedSetup()
+	epoch1 := []string{"<Dummy TX: xxxx01>", "<Dummy TX: xxxx02>"}
+	epoch2 := []string{"<Dummy TX: xxxx03>", "<Dummy TX: xxxx04>"}
+
+	acc := NewAccumulator(epoch1, HashToPrimeFromSha256, setup)
+	a1 := acc.Value()
+	w, _ := acc.ProveMembership(epoch1[0])
+
+	// 下一个 epoch 从上一个值继续累加, 结果与一次累加全部元素相同
+	resumed := ResumeAccumulator(a1, HashToPrimeFromSha256, setup)
+	proof, x, err := resumed.AddAndProve(epoch2)
+	if err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	a2 := resumed.Value()
+	if a2.Cmp(NewAccumulator(append(epoch1, epoch2...), HashToPrimeFromSha256, setup).Value()) != 0 {
+		t.Error("incremental accumulator differs from accumulating all elements at once")
+	}
+	if !VerifyAdd(setup, HashToPrimeFromSha256, a1, a2, epoch2, proof) {
+		t.Error("PoE of the batch add did not verify")
+	}
+	if !VerifyPoE(setup, a1, x, a2, proof) {
+		t.Error("PoE did not verify against the exponent of the batch")
+	}
+	if VerifyAdd(setup, HashToPrimeFromSha256, a1, a2, epoch2[:1], proof) {
+		t.Error("PoE verified for a different batch")
+	}
+	if err := resumed.Add(epoch2[:1]); !errors.Is(err, ErrMember) {
+		t.Errorf("expected ErrMember when adding an element twice, got %v", err)
+	}
+	if _, err := resumed.ProveNonMembership("<Dummy TX: xxxx05>"); !errors.Is(err, ErrPartialHistory) {
+		t.Errorf("expected ErrPartialHistory, got %v", err)
+	}
+
+	// 旧成员的 witness 随新的 epoch 更新, 新成员的 witness 相对于继续累加的值
+	w = UpdateWitnessOnAdd(setup, w, GenRepresentatives(epoch2, HashToPrimeFromSha256))
+	if !VerifyElementMembership(setup, HashToPrimeFromSha256, a2, epoch1[0], w) {
+		t.Error("updated witness of an earlier member did not verify")
+	}
+	w3, _ := resumed.ProveMembership(epoch2[0])
+	if !VerifyElementMembership(setup, HashToPrimeFromSha256, a2, epoch2[0], w3) {
+		t.Error("witness of a resumed accumulator did not verify")
+	}
+
+	// 删除一批元素后更新剩余成员的 witness
+	proof, err = resumed.DeleteAndProve(epoch2[1:])
+	if err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	a3 := resumed.Value()
+	if !VerifyDelete(setup, HashToPrimeFromSha256, a2, a3, epoch2[1:], proof) {
+		t.Error("PoE of the batch delete did not verify")
+	}
+	deleted := GenRepresentatives(epoch2[1:], HashToPrimeFromSha256)
+	w, err = UpdateWitnessOnDelete(setup, w, GenRepresentatives(epoch1[:1], HashToPrimeFromSha256)[0], a3, deleted)
+	if err != nil || !VerifyElementMembership(setup, HashToPrimeFromSha256, a3, epoch1[0], w) {
+		t.Errorf("witness updated after the delete did not verify: %v", err)
+	}
+	if err := resumed.Delete(epoch1[:1]); !errors.Is(err, ErrNotMember) {
+		t.Errorf("expected ErrNotMember when deleting an element accumulated before resuming, got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+const (
+	// HashToPrimeBits 代表数的位数, 即 SHA256 的输出长度, 最高位固定为 1
+	HashToPrimeBits = sha256.Size * 8
+	// RepresentativeDomain 累加器元素代表数的域标签
+	RepresentativeDomain = "Chamael-HashToPrime-Representative-v1"
+	// hashToPrimeRounds 候选数来自哈希而非攻击者构造, ProbablyPrime 在 Miller-Rabin 之外还做 Baillie-PSW 检验
+	hashToPrimeRounds = 2
+	// hashToPrimeCacheSize 缓存的代表数个数, 缓存满后整体清空
+	hashToPrimeCacheSize = 1 << 16
+)
+
+// primeCache 缓存 (域标签, 数据) -> 素数, 同一笔交易在交易池、共识和累加器中会多次求代表数
+var primeCache = struct {
+	sync.Mutex
+	primes map[[sha256.Size]byte]*big.Int
+}{primes: make(map[[sha256.Size]byte]*big.Int)}
+
+// HashToPrime 把数据确定性地映射为 HashToPrimeBits 位的素数, 用作累加器元素的代表数
+func HashToPrime(data []byte) *big.Int {
+	return HashToPrimeDomain(RepresentativeDomain, data)
+}
+
+// HashToPrimeDomain 在域 domain 下把数据映射为素数: 依次对 计数器 = 0, 1, ... 计算
+// SHA256(len(domain) || domain || 计数器 || data), 置最高位和最低位后得到的第一个素数即为结果.
+// 不同的域得到互不相关的素数, 结果在 [2^(bits-1), 2^bits) 中近似均匀分布
+func HashToPrimeDomain(domain string, data []byte) *big.Int {
+	key := cacheKey(domain, data)
+	primeCache.Lock()
+	p, ok := primeCache.primes[key]
+	primeCache.Unlock()
+	if ok {
+		return new(big.Int).Set(p)
+	}
+
+	p = derivePrime(domain, data)
+
+	primeCache.Lock()
+	if len(primeCache.primes) >= hashToPrimeCacheSize {
+		primeCache.primes = make(map[[sha256.Size]byte]*big.Int)
+	}
+	primeCache.primes[key] = p
+	primeCache.Unlock()
+	return new(big.Int).Set(p)
+}
+
+// derivePrime 不使用缓存的计数器重哈希
+func derivePrime(domain string, data []byte) *big.Int {
+	prefix := make([]byte, 8, 8+len(domain)+4)
+	binary.BigEndian.PutUint64(prefix, uint64(len(domain)))
+	prefix = append(prefix, domain...)
+	prefix = append(prefix, 0, 0, 0, 0)
+	counter := prefix[len(prefix)-4:]
+
+	h := sha256.New()
+	sum := make([]byte, 0, sha256.Size)
+	n := new(big.Int)
+	for c := uint32(0); ; c++ {
+		binary.BigEndian.PutUint32(counter, c)
+		h.Reset()
+		h.Write(prefix)
+		h.Write(data)
+		sum = h.Sum(sum[:0])
+		sum[0] |= 0x80
+		sum[len(sum)-1] |= 1
+		// 大多数候选数有小素因子, 先用 64 位除法排除, 只对剩下的做开销大得多的素性检验
+		if hasSmallFactor(sum) {
+			continue
+		}
+		n.SetBytes(sum)
+		if n.ProbablyPrime(hashToPrimeRounds) {
+			return n
+		}
+	}
+}
+
+// sieveBound 试除的小素数上界
+const sieveBound = 1 << 12
+
+// sieveChunk 一组乘积不超过 2^64 的小素数, 候选数对乘积取模一次后即可得到对每个素数的余数
+type sieveChunk struct {
+	product uint64
+	primes  []uint64
+}
+
+var sieveChunks = buildSieve(sieveBound)
+
+// buildSieve 把 [3, bound) 中的素数按乘积不超过 2^64 分组
+func buildSieve(bound int) []sieveChunk {
+	var chunks []sieveChunk
+	chunk := sieveChunk{product: 1}
+	for p := uint64(3); p < uint64(bound); p += 2 {
+		if !big.NewInt(int64(p)).ProbablyPrime(0) {
+			continue
+		}
+		if hi, _ := bits.Mul64(chunk.product, p); hi != 0 {
+			chunks = append(chunks, chunk)
+			chunk = sieveChunk{product: 1}
+		}
+		chunk.product *= p
+		chunk.primes = append(chunk.primes, p)
+	}
+	return append(chunks, chunk)
+}
+
+// hasSmallFactor 报告大端字节表示的奇数 num 是否有小于 sieveBound 的素因子
+func hasSmallFactor(num []byte) bool {
+	for _, chunk := range sieveChunks {
+		var r uint64
+		for i := 0; i < len(num); i += 8 {
+			_, r = bits.Div64(r, binary.BigEndian.Uint64(num[i:i+8]), chunk.product)
+		}
+		for _, p := range chunk.primes {
+			if r%p == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cacheKey 缓存的键: 域标签和数据的哈希, 不保存数据本身
+func cacheKey(domain string, data []byte) [sha256.Size]byte {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(domain)))
+	h := sha256.New()
+	h.Write(size[:])
+	h.Write([]byte(domain))
+	h.Write(data)
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// legacyHashToPrime 之前的实现: 从 SHA256 的值开始逐个加一直到遇到素数, 只用于对比
+func legacyHashToPrime(data []byte) *big.Int {
+	hash := sha256.Sum256(data)
+	n := new(big.Int).SetBytes(hash[:])
+	for !n.ProbablyPrime(20) {
+		n.Add(n, big.NewInt(1))
+	}
+	return n
+}
+
+func TestHashToPrime(t *testing.T) {
+	data := []byte("<Dummy TX: xxxx01>")
+	p := HashToPrime(data)
+	if p.BitLen() != HashToPrimeBits || !p.ProbablyPrime(20) {
+		t.Fatalf("expected a %d-bit prime, got %d bits", HashToPrimeBits, p.BitLen())
+	}
+	if derivePrime(RepresentativeDomain, data).Cmp(p) != 0 {
+		t.Error("cached result differs from the derived prime")
+	}
+
+	// 调用者修改结果不影响缓存
+	p.Add(p, big.NewInt(1))
+	if HashToPrime(data).Cmp(derivePrime(RepresentativeDomain, data)) != 0 {
+		t.Error("modifying a result changed the cache")
+	}
+
+	if HashToPrimeDomain(PoEDomain, data).Cmp(HashToPrime(data)) == 0 {
+		t.Error("different domains map to the same prime")
+	}
+	if HashToPrime([]byte("<Dummy TX: xxxx02>")).Cmp(HashToPrime(data)) == 0 {
+		t.Error("different data map to the same prime")
+	}
+}
+
+func BenchmarkHashToPrime(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		derivePrime(RepresentativeDomain, []byte(fmt.Sprintf("<Dummy TX: %d>", i)))
+	}
+}
+
+func BenchmarkHashToPrimeLegacy(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		legacyHashToPrime([]byte(fmt.Sprintf("<Dummy TX: %d>", i)))
+	}
+}
+
+func BenchmarkHashToPrimeCached(b *testing.B) {
+	data := []byte("<Dummy TX: cached>")
+	HashToPrime(data)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		HashToPrime(data)
+	}
+}
+
+// 一个 epoch 20000 笔交易的代表数
+func BenchmarkGenRepresentatives(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		set := make([]string, 20000)
+		for j := range set {
+			set[j] = fmt.Sprintf("<Dummy TX: %d-%d>", i, j)
+		}
+		GenRepresentatives(set, HashToPrimeFromSha256)
+	}
+}
@@ -61,6 +61,11 @@ func NewMerkleTree(data [][]string) (*MerkleTree, error) {
 	}, nil
 }
 
+// TxsRoot returns the root of the TxMerkleTree over individual transactions, nil for an empty list
+func TxsRoot(txs []string) []byte {
+	return NewTxMerkleTree(txs).Root()
+}
+
 // GetMerkleTreeRoot returns a Merkle tree root
 func (t *MerkleTree) GetMerkleTreeRoot() []byte {
 	if t == nil {
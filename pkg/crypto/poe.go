@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+)
+
+// PoEDomain PoE 挑战的域标签, 与累加器元素的代表数互不相关
+const PoEDomain = "Chamael-HashToPrime-PoE-v1"
+
+// PoE Wesolowski 指数证明, 证明 u^x = w mod N 而验证者不必计算 x 次幂:
+// 挑战 l = HashToPrimeDomain(PoEDomain, u, x, w), Q = u^(x / l), 验证 Q^l * u^(x mod l) = w
+type PoE struct {
+	Q *big.Int
+}
+
+// ProvePoE 生成 u^x = w 的证明
+func ProvePoE(setup *Setup, u, x, w *big.Int) *PoE {
+	l := poeChallenge(u, x, w)
+	q := new(big.Int).Quo(x, l)
+	return &PoE{Q: new(big.Int).Exp(u, q, setup.N)}
+}
+
+// VerifyPoE 验证 proof 证明了 u^x = w mod N, 只需两次以 l 大小为指数的模幂
+func VerifyPoE(setup *Setup, u, x, w *big.Int, proof *PoE) bool {
+	if proof == nil || proof.Q == nil || proof.Q.Sign() < 0 || proof.Q.Cmp(setup.N) >= 0 || x.Sign() < 0 {
+		return false
+	}
+	l := poeChallenge(u, x, w)
+	r := new(big.Int).Mod(x, l)
+	lhs := new(big.Int).Exp(proof.Q, l, setup.N)
+	lhs.Mul(lhs, new(big.Int).Exp(u, r, setup.N))
+	lhs.Mod(lhs, setup.N)
+	return lhs.Cmp(new(big.Int).Mod(w, setup.N)) == 0
+}
+
+// poeChallenge Fiat-Shamir 挑战, 每个数带长度前缀后哈希, 避免不同的 (u, x, w) 拼接出相同的输入
+func poeChallenge(u, x, w *big.Int) *big.Int {
+	h := sha256.New()
+	for _, v := range []*big.Int{u, x, w} {
+		b := v.Bytes()
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(b)))
+		h.Write(size[:])
+		h.Write(b)
+	}
+	return HashToPrimeDomain(PoEDomain, h.Sum(nil))
+}
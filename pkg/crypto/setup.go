@@ -0,0 +1,139 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ErrInvalidSetup 参数文件中的 RSA accumulator 参数不可用
+var ErrInvalidSetup = errors.New("invalid RSA accumulator parameters")
+
+// smallPrimeBound 检查模数没有小于该值的素因子
+const smallPrimeBound = 1 << 16
+
+// setupFile 参数文件的格式, 各数以十进制字符串保存
+type setupFile struct {
+	N string `yaml:"N"`
+	G string `yaml:"G"`
+	H string `yaml:"H"`
+}
+
+// GenerateSetup 生成 bits 位的 RSA 模数 N = p*q 以及随机的二次剩余 G 和 H, 返回前丢弃 p 和 q,
+// 生成者之后也无法计算群的阶
+func GenerateSetup(bits int) (*Setup, error) {
+	if bits < RSABitLength {
+		return nil, fmt.Errorf("%w: modulus must have at least %d bits", ErrInvalidSetup, RSABitLength)
+	}
+	N := new(big.Int)
+	for N.BitLen() != bits {
+		p, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := rand.Prime(rand.Reader, bits-bits/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+		N.Mul(p, q)
+	}
+	G, err := randomQR(N)
+	if err != nil {
+		return nil, err
+	}
+	H, err := randomQR(N)
+	if err != nil {
+		return nil, err
+	}
+	return &Setup{N: N, G: G, H: H}, nil
+}
+
+// randomQR 生成与 N 互素的随机数的平方 mod N
+func randomQR(N *big.Int) (*big.Int, error) {
+	for {
+		r, err := rand.Int(rand.Reader, N)
+		if err != nil {
+			return nil, err
+		}
+		x := new(big.Int).Exp(r, big.NewInt(2), N)
+		if validGenerator(x, N) {
+			return x, nil
+		}
+	}
+}
+
+// validGenerator 检查 1 < x < N-1 且 x 与 N 互素
+func validGenerator(x, N *big.Int) bool {
+	if x == nil || x.Cmp(big.NewInt(1)) <= 0 || x.Cmp(new(big.Int).Sub(N, big.NewInt(1))) >= 0 {
+		return false
+	}
+	return new(big.Int).GCD(nil, nil, x, N).Cmp(big.NewInt(1)) == 0
+}
+
+// Validate 检查参数可以安全使用: 模数不少于 RSABitLength 位、是合数且没有小素因子, G 和 H 是模 N 的可逆元且不为 ±1.
+// 无法验证生成者是否丢弃了 p 和 q
+func (s *Setup) Validate() error {
+	if s.N == nil || s.G == nil || s.H == nil {
+		return fmt.Errorf("%w: N, G or H is missing or not a decimal integer", ErrInvalidSetup)
+	}
+	if s.N.BitLen() < RSABitLength {
+		return fmt.Errorf("%w: modulus has %d bits, need at least %d", ErrInvalidSetup, s.N.BitLen(), RSABitLength)
+	}
+	if s.N.ProbablyPrime(20) {
+		return fmt.Errorf("%w: modulus is prime", ErrInvalidSetup)
+	}
+	for p := int64(2); p < smallPrimeBound; p++ {
+		if big.NewInt(p).ProbablyPrime(0) && new(big.Int).Mod(s.N, big.NewInt(p)).Sign() == 0 {
+			return fmt.Errorf("%w: modulus has the small factor %d", ErrInvalidSetup, p)
+		}
+	}
+	if !validGenerator(s.G, s.N) {
+		return fmt.Errorf("%w: G is not a unit other than ±1 modulo N", ErrInvalidSetup)
+	}
+	if !validGenerator(s.H, s.N) {
+		return fmt.Errorf("%w: H is not a unit other than ±1 modulo N", ErrInvalidSetup)
+	}
+	return nil
+}
+
+// WriteSetup 把参数写入参数文件
+func WriteSetup(filename string, s *Setup) error {
+	byt, err := yaml.Marshal(setupFile{N: s.N.String(), G: s.G.String(), H: s.H.String()})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, byt, 0644)
+}
+
+// LoadSetup 读取参数文件并验证参数
+func LoadSetup(filename string) (*Setup, error) {
+	byt, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f setupFile
+	if err := yaml.Unmarshal(byt, &f); err != nil {
+		return nil, err
+	}
+	s := &Setup{N: parseDecimal(f.N), G: parseDecimal(f.G), H: parseDecimal(f.H)}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseDecimal 解析十进制整数, 格式错误时返回 nil
+func parseDecimal(str string) *big.Int {
+	n, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		return nil
+	}
+	return n
+}
@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateAndLoadSetup(t *testing.T) {
+	setup, err := GenerateSetup(RSABitLength)
+	if err != nil {
+		t.Fatalf("failed to generate parameters: %v", err)
+	}
+	if setup.N.BitLen() != RSABitLength || setup.N.Cmp(TrustedSetup().N) == 0 {
+		t.Errorf("unexpected modulus of %d bits", setup.N.BitLen())
+	}
+
+	filename := filepath.Join(t.TempDir(), "acc_params.yaml")
+	if err := WriteSetup(filename, setup); err != nil {
+		t.Fatalf("failed to write parameters: %v", err)
+	}
+	loaded, err := LoadSetup(filename)
+	if err != nil {
+		t.Fatalf("failed to load parameters: %v", err)
+	}
+	if loaded.N.Cmp(setup.N) != 0 || loaded.G.Cmp(setup.G) != 0 || loaded.H.Cmp(setup.H) != 0 {
+		t.Error("loaded parameters differ from the written ones")
+	}
+
+	if _, err := GenerateSetup(1024); !errors.Is(err, ErrInvalidSetup) {
+		t.Errorf("expected a short modulus to be refused, got %v", err)
+	}
+}
+
+func TestLoadSetupRejectsInvalidParameters(t *testing.T) {
+	if err := TrustedSetup().Validate(); err != nil {
+		t.Errorf("demo parameters are invalid: %v", err)
+	}
+
+	demo := TrustedSetup()
+	prime := new(big.Int).Lsh(big.NewInt(1), RSABitLength)
+	for !prime.ProbablyPrime(20) {
+		prime.Add(prime, big.NewInt(1))
+	}
+	cases := map[string]*Setup{
+		"short modulus": {N: big.NewInt(35), G: big.NewInt(2), H: big.NewInt(3)},
+		"prime modulus": {N: prime, G: demo.G, H: demo.H},
+		"small factor":  {N: new(big.Int).Mul(demo.N, big.NewInt(3)), G: demo.G, H: demo.H},
+		"trivial G":     {N: demo.N, G: big.NewInt(1), H: demo.H},
+		"G = -1":        {N: demo.N, G: new(big.Int).Sub(demo.N, big.NewInt(1)), H: demo.H},
+		"zero H":        {N: demo.N, G: demo.G, H: big.NewInt(0)},
+	}
+	for name, setup := range cases {
+		filename := filepath.Join(t.TempDir(), "acc_params.yaml")
+		if err := WriteSetup(filename, setup); err != nil {
+			t.Fatalf("%s: failed to write parameters: %v", name, err)
+		}
+		if _, err := LoadSetup(filename); !errors.Is(err, ErrInvalidSetup) {
+			t.Errorf("%s: expected ErrInvalidSetup, got %v", name, err)
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "acc_params.yaml")
+	if err := ioutil.WriteFile(filename, []byte("N: 0x1234\nG: \"2\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSetup(filename); !errors.Is(err, ErrInvalidSetup) {
+		t.Errorf("expected ErrInvalidSetup for malformed numbers, got %v", err)
+	}
+}
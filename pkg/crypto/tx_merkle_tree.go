@@ -0,0 +1,172 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// ErrInvalidIndices 要证明的叶子位置不是升序、有重复或超出树的范围
+var ErrInvalidIndices = errors.New("leaf indices must be sorted, unique and within the tree")
+
+// TxMerkleTree 每笔交易一个叶子的 Merkle 树. 叶子哈希为 H(0x00||tx), 内部节点为 H(0x01||left||right),
+// 节点数为奇数的层中最后一个节点原样提升到上一层
+type TxMerkleTree struct {
+	levels [][][]byte // levels[0] 为叶子哈希, 最后一层只有根
+}
+
+// MerkleMultiProof 证明一组叶子在 TxMerkleTree 中. 单个叶子的证明即普通的包含证明,
+// 多个叶子共享的兄弟节点只发送一次
+type MerkleMultiProof struct {
+	Leaves  int      // 树的叶子数
+	Indices []int    // 被证明的叶子位置, 升序
+	Hashes  [][]byte // 兄弟节点哈希, 按验证方使用的顺序排列
+}
+
+// merkleNode 自底向上计算时当前层中已知的节点
+type merkleNode struct {
+	pos  int
+	hash []byte
+}
+
+// leafHash 叶子哈希 H(0x00||tx)
+func leafHash(tx string) []byte {
+	s := sha3.New512()
+	s.Write([]byte{0})
+	s.Write([]byte(tx))
+	return s.Sum(nil)
+}
+
+// nodeHash 内部节点哈希 H(0x01||left||right)
+func nodeHash(left, right []byte) []byte {
+	s := sha3.New512()
+	s.Write([]byte{1})
+	s.Write(left)
+	s.Write(right)
+	return s.Sum(nil)
+}
+
+// NewTxMerkleTree 以编码后的交易为叶子构建 Merkle 树
+func NewTxMerkleTree(txs []string) *TxMerkleTree {
+	level := make([][]byte, len(txs))
+	for i, tx := range txs {
+		level[i] = leafHash(tx)
+	}
+	t := &TxMerkleTree{levels: [][][]byte{level}}
+	for len(level) > 1 {
+		up := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				up = append(up, level[i])
+			} else {
+				up = append(up, nodeHash(level[i], level[i+1]))
+			}
+		}
+		t.levels = append(t.levels, up)
+		level = up
+	}
+	return t
+}
+
+// Len 返回叶子数
+func (t *TxMerkleTree) Len() int {
+	return len(t.levels[0])
+}
+
+// Root 返回 Merkle 根, 空树返回 nil
+func (t *TxMerkleTree) Root() []byte {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		return nil
+	}
+	return top[0]
+}
+
+// Prove 为升序位置 indices 处的叶子生成多重证明
+func (t *TxMerkleTree) Prove(indices []int) (*MerkleMultiProof, error) {
+	if !validIndices(indices, t.Len()) {
+		return nil, ErrInvalidIndices
+	}
+	proof := &MerkleMultiProof{Leaves: t.Len(), Indices: append([]int(nil), indices...)}
+	nodes := make([]merkleNode, len(indices))
+	for i, idx := range indices {
+		nodes[i] = merkleNode{pos: idx, hash: t.levels[0][idx]}
+	}
+	climb(t.Len(), nodes, func(level, pos int) ([]byte, bool) {
+		proof.Hashes = append(proof.Hashes, t.levels[level][pos])
+		return t.levels[level][pos], true
+	})
+	return proof, nil
+}
+
+// ProveTx 返回位置 index 处叶子的包含证明
+func (t *TxMerkleTree) ProveTx(index int) (*MerkleMultiProof, error) {
+	return t.Prove([]int{index})
+}
+
+// VerifyMultiProof 验证 txs 依次是根为 root 的树中 proof.Indices 各位置的叶子
+func VerifyMultiProof(root []byte, proof *MerkleMultiProof, txs []string) bool {
+	if proof == nil || len(txs) != len(proof.Indices) || !validIndices(proof.Indices, proof.Leaves) {
+		return false
+	}
+	nodes := make([]merkleNode, len(txs))
+	for i, tx := range txs {
+		nodes[i] = merkleNode{pos: proof.Indices[i], hash: leafHash(tx)}
+	}
+	next := 0
+	computed, ok := climb(proof.Leaves, nodes, func(level, pos int) ([]byte, bool) {
+		if next >= len(proof.Hashes) {
+			return nil, false
+		}
+		next++
+		return proof.Hashes[next-1], true
+	})
+	return ok && next == len(proof.Hashes) && bytes.Equal(computed, root)
+}
+
+// climb 把已知节点逐层哈希到根. 未知的兄弟节点通过 sibling 获取:
+// 证明方从树中取, 验证方从证明中依次取
+func climb(size int, nodes []merkleNode, sibling func(level, pos int) ([]byte, bool)) ([]byte, bool) {
+	for level := 0; size > 1; level++ {
+		up := make([]merkleNode, 0, len(nodes))
+		for i := 0; i < len(nodes); i++ {
+			n := nodes[i]
+			switch {
+			case n.pos%2 == 0 && n.pos+1 == size:
+				up = append(up, merkleNode{pos: n.pos / 2, hash: n.hash})
+			case n.pos%2 == 0 && i+1 < len(nodes) && nodes[i+1].pos == n.pos+1:
+				up = append(up, merkleNode{pos: n.pos / 2, hash: nodeHash(n.hash, nodes[i+1].hash)})
+				i++
+			case n.pos%2 == 0:
+				right, ok := sibling(level, n.pos+1)
+				if !ok {
+					return nil, false
+				}
+				up = append(up, merkleNode{pos: n.pos / 2, hash: nodeHash(n.hash, right)})
+			default:
+				left, ok := sibling(level, n.pos-1)
+				if !ok {
+					return nil, false
+				}
+				up = append(up, merkleNode{pos: n.pos / 2, hash: nodeHash(left, n.hash)})
+			}
+		}
+		nodes = up
+		size = (size + 1) / 2
+	}
+	return nodes[0].hash, true
+}
+
+// validIndices 检查 indices 非空、升序无重复且都小于 leaves
+func validIndices(indices []int, leaves int) bool {
+	if len(indices) == 0 {
+		return false
+	}
+	for i, idx := range indices {
+		if idx < 0 || idx >= leaves || (i > 0 && idx <= indices[i-1]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func dummyTxs(n int) []string {
+	txs := make([]string, n)
+	for i := range txs {
+		txs[i] = fmt.Sprintf("<Dummy TX: xxxx%02d>", i)
+	}
+	return txs
+}
+
+// 对不同大小的树, 每个叶子子集的多重证明都能验证, 且与单叶子证明组合使用相同的根
+func TestTxMerkleTreeMultiProofs(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		txs := dummyTxs(n)
+		tree := NewTxMerkleTree(txs)
+		if !bytes.Equal(tree.Root(), TxsRoot(txs)) {
+			t.Fatalf("n=%d: TxsRoot differs from the tree root", n)
+		}
+		for subset := 1; subset < 1<<n; subset++ {
+			var indices []int
+			var proven []string
+			for i := 0; i < n; i++ {
+				if subset&(1<<i) != 0 {
+					indices = append(indices, i)
+					proven = append(proven, txs[i])
+				}
+			}
+			proof, err := tree.Prove(indices)
+			if err != nil {
+				t.Fatalf("n=%d %v: %v", n, indices, err)
+			}
+			if !VerifyMultiProof(tree.Root(), proof, proven) {
+				t.Errorf("n=%d %v: multiproof did not verify", n, indices)
+			}
+			// 全部叶子的证明不需要任何兄弟节点
+			if subset == 1<<n-1 && len(proof.Hashes) != 0 {
+				t.Errorf("n=%d: proof of all leaves has %d hashes", n, len(proof.Hashes))
+			}
+		}
+	}
+}
+
+func TestTxMerkleTreeRejectsInvalidProofs(t *testing.T) {
+	txs := dummyTxs(6)
+	tree := NewTxMerkleTree(txs)
+	proof, _ := tree.Prove([]int{1, 4})
+
+	if VerifyMultiProof(tree.Root(), proof, []string{txs[1], txs[3]}) {
+		t.Error("proof verified a different transaction")
+	}
+	if VerifyMultiProof(tree.Root(), proof, []string{txs[4], txs[1]}) {
+		t.Error("proof verified transactions in the wrong order")
+	}
+	moved := *proof
+	moved.Indices = []int{1, 5}
+	if VerifyMultiProof(tree.Root(), &moved, []string{txs[1], txs[4]}) {
+		t.Error("proof verified a transaction at another position")
+	}
+	resized := *proof
+	resized.Leaves = 7
+	if VerifyMultiProof(tree.Root(), &resized, []string{txs[1], txs[4]}) {
+		t.Error("proof verified against a different tree size")
+	}
+	extra := *proof
+	extra.Hashes = append(append([][]byte(nil), proof.Hashes...), tree.Root())
+	if VerifyMultiProof(tree.Root(), &extra, []string{txs[1], txs[4]}) {
+		t.Error("proof with unused hashes verified")
+	}
+
+	for _, indices := range [][]int{nil, {4, 1}, {1, 1}, {6}, {-1}} {
+		if _, err := tree.Prove(indices); err != ErrInvalidIndices {
+			t.Errorf("expected ErrInvalidIndices for %v, got %v", indices, err)
+		}
+	}
+	if NewTxMerkleTree(nil).Root() != nil {
+		t.Error("expected a nil root for an empty tree")
+	}
+}
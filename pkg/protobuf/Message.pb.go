@@ -29,6 +29,8 @@ type Message struct {
 	Id     []byte `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
 	Sender uint32 `protobuf:"varint,3,opt,name=sender,proto3" json:"sender,omitempty"`
 	Data   []byte `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	Seq    uint64 `protobuf:"varint,5,opt,name=seq,proto3" json:"seq,omitempty"` //发送方的消息序号,用于拒绝重放
+	Sig    []byte `protobuf:"bytes,6,opt,name=sig,proto3" json:"sig,omitempty"`  //发送方对 type||id||sender||seq||data 的签名,未启用消息签名时为空
 }
 
 func (x *Message) Reset() {
@@ -91,6 +93,234 @@ func (x *Message) GetData() []byte {
 	return nil
 }
 
+func (x *Message) GetSeq() uint64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Message) GetSig() []byte {
+	if x != nil {
+		return x.Sig
+	}
+	return nil
+}
+
+//Chamael-交易格式
+type TxInput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Shard  uint32 `protobuf:"varint,1,opt,name=shard,proto3" json:"shard,omitempty"`
+	Object []byte `protobuf:"bytes,2,opt,name=object,proto3" json:"object,omitempty"` //被花费对象的ID, 即所有者的 ed25519 公钥
+	Amount uint64 `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+}
+
+func (x *TxInput) Reset() {
+	*x = TxInput{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TxInput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TxInput) ProtoMessage() {}
+
+func (x *TxInput) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TxInput.ProtoReflect.Descriptor instead.
+func (*TxInput) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TxInput) GetShard() uint32 {
+	if x != nil {
+		return x.Shard
+	}
+	return 0
+}
+
+func (x *TxInput) GetObject() []byte {
+	if x != nil {
+		return x.Object
+	}
+	return nil
+}
+
+func (x *TxInput) GetAmount() uint64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+type TxOutput struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Shard  uint32 `protobuf:"varint,1,opt,name=shard,proto3" json:"shard,omitempty"`
+	Object []byte `protobuf:"bytes,2,opt,name=object,proto3" json:"object,omitempty"` //新产生对象的ID
+	Amount uint64 `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"`
+}
+
+func (x *TxOutput) Reset() {
+	*x = TxOutput{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TxOutput) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TxOutput) ProtoMessage() {}
+
+func (x *TxOutput) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TxOutput.ProtoReflect.Descriptor instead.
+func (*TxOutput) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TxOutput) GetShard() uint32 {
+	if x != nil {
+		return x.Shard
+	}
+	return 0
+}
+
+func (x *TxOutput) GetObject() []byte {
+	if x != nil {
+		return x.Object
+	}
+	return nil
+}
+
+func (x *TxOutput) GetAmount() uint64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+type Transaction struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id      []byte      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"` //除 id 和 sig 外其余字段的哈希
+	Inputs  []*TxInput  `protobuf:"bytes,2,rep,name=inputs,proto3" json:"inputs,omitempty"`
+	Outputs []*TxOutput `protobuf:"bytes,3,rep,name=outputs,proto3" json:"outputs,omitempty"`
+	Nonce   uint64      `protobuf:"varint,4,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	Sig     []byte      `protobuf:"bytes,5,opt,name=sig,proto3" json:"sig,omitempty"`   //输入所有者对 id 的 ed25519 签名, 一笔交易的输入属于同一个所有者
+	Data    []byte      `protobuf:"bytes,6,opt,name=data,proto3" json:"data,omitempty"` //附加数据, 如全局共识中 NL/NS 的结果
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Transaction) GetId() []byte {
+	if x != nil {
+		return x.Id
+	}
+	return nil
+}
+
+func (x *Transaction) GetInputs() []*TxInput {
+	if x != nil {
+		return x.Inputs
+	}
+	return nil
+}
+
+func (x *Transaction) GetOutputs() []*TxOutput {
+	if x != nil {
+		return x.Outputs
+	}
+	return nil
+}
+
+func (x *Transaction) GetNonce() uint64 {
+	if x != nil {
+		return x.Nonce
+	}
+	return 0
+}
+
+func (x *Transaction) GetSig() []byte {
+	if x != nil {
+		return x.Sig
+	}
+	return nil
+}
+
+func (x *Transaction) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
 //Chamael-2pHotstuff使用的消息类型
 type New_View struct {
 	state         protoimpl.MessageState
@@ -103,7 +333,7 @@ type New_View struct {
 func (x *New_View) Reset() {
 	*x = New_View{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[1]
+		mi := &file_Message_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -116,7 +346,7 @@ func (x *New_View) String() string {
 func (*New_View) ProtoMessage() {}
 
 func (x *New_View) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[1]
+	mi := &file_Message_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -129,7 +359,7 @@ func (x *New_View) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use New_View.ProtoReflect.Descriptor instead.
 func (*New_View) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{1}
+	return file_Message_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *New_View) GetNone() []byte {
@@ -144,13 +374,14 @@ type Prepare struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Txs []string `protobuf:"bytes,1,rep,name=txs,proto3" json:"txs,omitempty"`
+	Block  *Block      `protobuf:"bytes,1,opt,name=block,proto3" json:"block,omitempty"`
+	HighQC *QuorumCert `protobuf:"bytes,2,opt,name=highQC,proto3" json:"highQC,omitempty"`
 }
 
 func (x *Prepare) Reset() {
 	*x = Prepare{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[2]
+		mi := &file_Message_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -163,7 +394,7 @@ func (x *Prepare) String() string {
 func (*Prepare) ProtoMessage() {}
 
 func (x *Prepare) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[2]
+	mi := &file_Message_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -176,12 +407,19 @@ func (x *Prepare) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Prepare.ProtoReflect.Descriptor instead.
 func (*Prepare) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{2}
+	return file_Message_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *Prepare) GetTxs() []string {
+func (x *Prepare) GetBlock() *Block {
 	if x != nil {
-		return x.Txs
+		return x.Block
+	}
+	return nil
+}
+
+func (x *Prepare) GetHighQC() *QuorumCert {
+	if x != nil {
+		return x.HighQC
 	}
 	return nil
 }
@@ -198,7 +436,7 @@ type Prepare_Vote struct {
 func (x *Prepare_Vote) Reset() {
 	*x = Prepare_Vote{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[3]
+		mi := &file_Message_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -211,7 +449,7 @@ func (x *Prepare_Vote) String() string {
 func (*Prepare_Vote) ProtoMessage() {}
 
 func (x *Prepare_Vote) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[3]
+	mi := &file_Message_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -224,7 +462,7 @@ func (x *Prepare_Vote) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Prepare_Vote.ProtoReflect.Descriptor instead.
 func (*Prepare_Vote) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{3}
+	return file_Message_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *Prepare_Vote) GetVote() uint32 {
@@ -246,14 +484,14 @@ type Precommit struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Aggsig []byte `protobuf:"bytes,1,opt,name=aggsig,proto3" json:"aggsig,omitempty"`
-	Aggpk  []byte `protobuf:"bytes,2,opt,name=aggpk,proto3" json:"aggpk,omitempty"`
+	Aggsig  []byte `protobuf:"bytes,1,opt,name=aggsig,proto3" json:"aggsig,omitempty"`
+	Signers []byte `protobuf:"bytes,3,opt,name=signers,proto3" json:"signers,omitempty"`
 }
 
 func (x *Precommit) Reset() {
 	*x = Precommit{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[4]
+		mi := &file_Message_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -266,7 +504,7 @@ func (x *Precommit) String() string {
 func (*Precommit) ProtoMessage() {}
 
 func (x *Precommit) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[4]
+	mi := &file_Message_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -279,7 +517,7 @@ func (x *Precommit) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Precommit.ProtoReflect.Descriptor instead.
 func (*Precommit) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{4}
+	return file_Message_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *Precommit) GetAggsig() []byte {
@@ -289,39 +527,499 @@ func (x *Precommit) GetAggsig() []byte {
 	return nil
 }
 
-func (x *Precommit) GetAggpk() []byte {
+func (x *Precommit) GetSigners() []byte {
 	if x != nil {
-		return x.Aggpk
+		return x.Signers
+	}
+	return nil
+}
+
+type Precommit_Vote struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Vote uint32 `protobuf:"varint,1,opt,name=vote,proto3" json:"vote,omitempty"`
+	Sig  []byte `protobuf:"bytes,2,opt,name=sig,proto3" json:"sig,omitempty"`
+}
+
+func (x *Precommit_Vote) Reset() {
+	*x = Precommit_Vote{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Precommit_Vote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Precommit_Vote) ProtoMessage() {}
+
+func (x *Precommit_Vote) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Precommit_Vote.ProtoReflect.Descriptor instead.
+func (*Precommit_Vote) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Precommit_Vote) GetVote() uint32 {
+	if x != nil {
+		return x.Vote
+	}
+	return 0
+}
+
+func (x *Precommit_Vote) GetSig() []byte {
+	if x != nil {
+		return x.Sig
+	}
+	return nil
+}
+
+type Commit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Aggsig  []byte `protobuf:"bytes,1,opt,name=aggsig,proto3" json:"aggsig,omitempty"`
+	Signers []byte `protobuf:"bytes,3,opt,name=signers,proto3" json:"signers,omitempty"`
+}
+
+func (x *Commit) Reset() {
+	*x = Commit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Commit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Commit) ProtoMessage() {}
+
+func (x *Commit) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Commit.ProtoReflect.Descriptor instead.
+func (*Commit) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Commit) GetAggsig() []byte {
+	if x != nil {
+		return x.Aggsig
+	}
+	return nil
+}
+
+func (x *Commit) GetSigners() []byte {
+	if x != nil {
+		return x.Signers
+	}
+	return nil
+}
+
+type Block struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Height   uint32         `protobuf:"varint,1,opt,name=height,proto3" json:"height,omitempty"`
+	Parent   []byte         `protobuf:"bytes,2,opt,name=parent,proto3" json:"parent,omitempty"`
+	Proposer uint32         `protobuf:"varint,3,opt,name=proposer,proto3" json:"proposer,omitempty"`
+	TxRoot   []byte         `protobuf:"bytes,4,opt,name=txRoot,proto3" json:"txRoot,omitempty"`
+	Justify  *QuorumCert    `protobuf:"bytes,5,opt,name=justify,proto3" json:"justify,omitempty"`
+	Txs      []*Transaction `protobuf:"bytes,6,rep,name=txs,proto3" json:"txs,omitempty"`
+}
+
+func (x *Block) Reset() {
+	*x = Block{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Block) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Block) ProtoMessage() {}
+
+func (x *Block) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Block.ProtoReflect.Descriptor instead.
+func (*Block) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Block) GetHeight() uint32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Block) GetParent() []byte {
+	if x != nil {
+		return x.Parent
+	}
+	return nil
+}
+
+func (x *Block) GetProposer() uint32 {
+	if x != nil {
+		return x.Proposer
+	}
+	return 0
+}
+
+func (x *Block) GetTxRoot() []byte {
+	if x != nil {
+		return x.TxRoot
+	}
+	return nil
+}
+
+func (x *Block) GetJustify() *QuorumCert {
+	if x != nil {
+		return x.Justify
+	}
+	return nil
+}
+
+func (x *Block) GetTxs() []*Transaction {
+	if x != nil {
+		return x.Txs
+	}
+	return nil
+}
+
+type QuorumCert struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Epoch     uint32 `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"` //被认证区块所在的 epoch; 链式模式每个 epoch 提议一个区块, 即区块高度
+	View      uint32 `protobuf:"varint,2,opt,name=view,proto3" json:"view,omitempty"`
+	Aggsig    []byte `protobuf:"bytes,3,opt,name=aggsig,proto3" json:"aggsig,omitempty"`
+	BlockHash []byte `protobuf:"bytes,5,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Signers   []byte `protobuf:"bytes,6,opt,name=signers,proto3" json:"signers,omitempty"`
+	Phase     uint32 `protobuf:"varint,7,opt,name=phase,proto3" json:"phase,omitempty"` //为 2 时是两阶段 HotStuff 提交区块的 commitQC(对 blockHash||2||epoch 的聚合签名), 否则是对 blockHash||1||epoch 的 QC
+}
+
+func (x *QuorumCert) Reset() {
+	*x = QuorumCert{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *QuorumCert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuorumCert) ProtoMessage() {}
+
+func (x *QuorumCert) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuorumCert.ProtoReflect.Descriptor instead.
+func (*QuorumCert) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QuorumCert) GetEpoch() uint32 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *QuorumCert) GetView() uint32 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *QuorumCert) GetAggsig() []byte {
+	if x != nil {
+		return x.Aggsig
+	}
+	return nil
+}
+
+func (x *QuorumCert) GetBlockHash() []byte {
+	if x != nil {
+		return x.BlockHash
+	}
+	return nil
+}
+
+func (x *QuorumCert) GetSigners() []byte {
+	if x != nil {
+		return x.Signers
+	}
+	return nil
+}
+
+func (x *QuorumCert) GetPhase() uint32 {
+	if x != nil {
+		return x.Phase
+	}
+	return 0
+}
+
+type Timeout struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Epoch  uint32      `protobuf:"varint,1,opt,name=epoch,proto3" json:"epoch,omitempty"`
+	View   uint32      `protobuf:"varint,2,opt,name=view,proto3" json:"view,omitempty"`
+	HighQC *QuorumCert `protobuf:"bytes,3,opt,name=highQC,proto3" json:"highQC,omitempty"`
+	Sig    []byte      `protobuf:"bytes,4,opt,name=sig,proto3" json:"sig,omitempty"`
+}
+
+func (x *Timeout) Reset() {
+	*x = Timeout{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Timeout) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Timeout) ProtoMessage() {}
+
+func (x *Timeout) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Timeout.ProtoReflect.Descriptor instead.
+func (*Timeout) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Timeout) GetEpoch() uint32 {
+	if x != nil {
+		return x.Epoch
+	}
+	return 0
+}
+
+func (x *Timeout) GetView() uint32 {
+	if x != nil {
+		return x.View
+	}
+	return 0
+}
+
+func (x *Timeout) GetHighQC() *QuorumCert {
+	if x != nil {
+		return x.HighQC
+	}
+	return nil
+}
+
+func (x *Timeout) GetSig() []byte {
+	if x != nil {
+		return x.Sig
+	}
+	return nil
+}
+
+//Chamael-chainedHotstuff使用的消息类型
+type Generic struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Block *Block `protobuf:"bytes,1,opt,name=block,proto3" json:"block,omitempty"`
+}
+
+func (x *Generic) Reset() {
+	*x = Generic{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Generic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Generic) ProtoMessage() {}
+
+func (x *Generic) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Generic.ProtoReflect.Descriptor instead.
+func (*Generic) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Generic) GetBlock() *Block {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+type Generic_Vote struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BlockHash []byte `protobuf:"bytes,1,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Sig       []byte `protobuf:"bytes,2,opt,name=sig,proto3" json:"sig,omitempty"`
+}
+
+func (x *Generic_Vote) Reset() {
+	*x = Generic_Vote{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_Message_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Generic_Vote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Generic_Vote) ProtoMessage() {}
+
+func (x *Generic_Vote) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Generic_Vote.ProtoReflect.Descriptor instead.
+func (*Generic_Vote) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Generic_Vote) GetBlockHash() []byte {
+	if x != nil {
+		return x.BlockHash
+	}
+	return nil
+}
+
+func (x *Generic_Vote) GetSig() []byte {
+	if x != nil {
+		return x.Sig
 	}
 	return nil
 }
 
-type Precommit_Vote struct {
+//Chamael-状态同步使用的消息类型
+type Block_Request struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Vote uint32 `protobuf:"varint,1,opt,name=vote,proto3" json:"vote,omitempty"`
-	Sig  []byte `protobuf:"bytes,2,opt,name=sig,proto3" json:"sig,omitempty"`
+	BlockHash []byte `protobuf:"bytes,1,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	From      uint32 `protobuf:"varint,2,opt,name=from,proto3" json:"from,omitempty"`
+	Epoch     uint32 `protobuf:"varint,3,opt,name=epoch,proto3" json:"epoch,omitempty"` //请求方当前的 epoch, Block_Response 以 epoch||blockHash 为 ID, 随 epoch 回收
 }
 
-func (x *Precommit_Vote) Reset() {
-	*x = Precommit_Vote{}
+func (x *Block_Request) Reset() {
+	*x = Block_Request{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[5]
+		mi := &file_Message_proto_msgTypes[15]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Precommit_Vote) String() string {
+func (x *Block_Request) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Precommit_Vote) ProtoMessage() {}
+func (*Block_Request) ProtoMessage() {}
 
-func (x *Precommit_Vote) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[5]
+func (x *Block_Request) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[15]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -332,51 +1030,57 @@ func (x *Precommit_Vote) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Precommit_Vote.ProtoReflect.Descriptor instead.
-func (*Precommit_Vote) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{5}
+// Deprecated: Use Block_Request.ProtoReflect.Descriptor instead.
+func (*Block_Request) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{15}
 }
 
-func (x *Precommit_Vote) GetVote() uint32 {
+func (x *Block_Request) GetBlockHash() []byte {
 	if x != nil {
-		return x.Vote
+		return x.BlockHash
+	}
+	return nil
+}
+
+func (x *Block_Request) GetFrom() uint32 {
+	if x != nil {
+		return x.From
 	}
 	return 0
 }
 
-func (x *Precommit_Vote) GetSig() []byte {
+func (x *Block_Request) GetEpoch() uint32 {
 	if x != nil {
-		return x.Sig
+		return x.Epoch
 	}
-	return nil
+	return 0
 }
 
-type Commit struct {
+type Block_Response struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Aggsig []byte `protobuf:"bytes,1,opt,name=aggsig,proto3" json:"aggsig,omitempty"`
-	Aggpk  []byte `protobuf:"bytes,2,opt,name=aggpk,proto3" json:"aggpk,omitempty"`
+	Blocks []*Block `protobuf:"bytes,1,rep,name=blocks,proto3" json:"blocks,omitempty"`
 }
 
-func (x *Commit) Reset() {
-	*x = Commit{}
+func (x *Block_Response) Reset() {
+	*x = Block_Response{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[6]
+		mi := &file_Message_proto_msgTypes[16]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
 }
 
-func (x *Commit) String() string {
+func (x *Block_Response) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Commit) ProtoMessage() {}
+func (*Block_Response) ProtoMessage() {}
 
-func (x *Commit) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[6]
+func (x *Block_Response) ProtoReflect() protoreflect.Message {
+	mi := &file_Message_proto_msgTypes[16]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -387,21 +1091,14 @@ func (x *Commit) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Commit.ProtoReflect.Descriptor instead.
-func (*Commit) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{6}
-}
-
-func (x *Commit) GetAggsig() []byte {
-	if x != nil {
-		return x.Aggsig
-	}
-	return nil
+// Deprecated: Use Block_Response.ProtoReflect.Descriptor instead.
+func (*Block_Response) Descriptor() ([]byte, []int) {
+	return file_Message_proto_rawDescGZIP(), []int{16}
 }
 
-func (x *Commit) GetAggpk() []byte {
+func (x *Block_Response) GetBlocks() []*Block {
 	if x != nil {
-		return x.Aggpk
+		return x.Blocks
 	}
 	return nil
 }
@@ -412,13 +1109,13 @@ type TXs_Inform struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Txs []string `protobuf:"bytes,1,rep,name=txs,proto3" json:"txs,omitempty"`
+	Txs []*Transaction `protobuf:"bytes,1,rep,name=txs,proto3" json:"txs,omitempty"`
 }
 
 func (x *TXs_Inform) Reset() {
 	*x = TXs_Inform{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[7]
+		mi := &file_Message_proto_msgTypes[17]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -431,7 +1128,7 @@ func (x *TXs_Inform) String() string {
 func (*TXs_Inform) ProtoMessage() {}
 
 func (x *TXs_Inform) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[7]
+	mi := &file_Message_proto_msgTypes[17]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -444,10 +1141,10 @@ func (x *TXs_Inform) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TXs_Inform.ProtoReflect.Descriptor instead.
 func (*TXs_Inform) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{7}
+	return file_Message_proto_rawDescGZIP(), []int{17}
 }
 
-func (x *TXs_Inform) GetTxs() []string {
+func (x *TXs_Inform) GetTxs() []*Transaction {
 	if x != nil {
 		return x.Txs
 	}
@@ -465,7 +1162,7 @@ type Sig_Inform struct {
 func (x *Sig_Inform) Reset() {
 	*x = Sig_Inform{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[8]
+		mi := &file_Message_proto_msgTypes[18]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -478,7 +1175,7 @@ func (x *Sig_Inform) String() string {
 func (*Sig_Inform) ProtoMessage() {}
 
 func (x *Sig_Inform) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[8]
+	mi := &file_Message_proto_msgTypes[18]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -491,7 +1188,7 @@ func (x *Sig_Inform) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Sig_Inform.ProtoReflect.Descriptor instead.
 func (*Sig_Inform) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{8}
+	return file_Message_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *Sig_Inform) GetNone() []byte {
@@ -513,7 +1210,7 @@ type Sigmsg struct {
 func (x *Sigmsg) Reset() {
 	*x = Sigmsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[9]
+		mi := &file_Message_proto_msgTypes[19]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -526,7 +1223,7 @@ func (x *Sigmsg) String() string {
 func (*Sigmsg) ProtoMessage() {}
 
 func (x *Sigmsg) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[9]
+	mi := &file_Message_proto_msgTypes[19]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -539,7 +1236,7 @@ func (x *Sigmsg) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Sigmsg.ProtoReflect.Descriptor instead.
 func (*Sigmsg) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{9}
+	return file_Message_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *Sigmsg) GetRoot() []byte {
@@ -561,18 +1258,23 @@ type InputBFT_Result struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Txs       []string `protobuf:"bytes,1,rep,name=txs,proto3" json:"txs,omitempty"`
-	Root      []byte   `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
-	Path      [][]byte `protobuf:"bytes,3,rep,name=path,proto3" json:"path,omitempty"`
-	Indicator []int64  `protobuf:"varint,4,rep,packed,name=indicator,proto3" json:"indicator,omitempty"`
-	Aggsig    []byte   `protobuf:"bytes,5,opt,name=aggsig,proto3" json:"aggsig,omitempty"`
-	Aggpk     []byte   `protobuf:"bytes,6,opt,name=aggpk,proto3" json:"aggpk,omitempty"`
+	Txs      []*Transaction `protobuf:"bytes,1,rep,name=txs,proto3" json:"txs,omitempty"`
+	Root     []byte         `protobuf:"bytes,2,opt,name=root,proto3" json:"root,omitempty"`
+	Aggsig   []byte         `protobuf:"bytes,5,opt,name=aggsig,proto3" json:"aggsig,omitempty"`
+	Rejected []*Transaction `protobuf:"bytes,7,rep,name=rejected,proto3" json:"rejected,omitempty"`                  //本分片作为输入分片拒绝的跨片交易, 与 txs 一起由 root 签名
+	Signers  []byte         `protobuf:"bytes,10,opt,name=signers,proto3" json:"signers,omitempty"`                   //签名者在源分片内编号(SID)的位图, 用于从源分片的公钥重建聚合公钥
+	Counts   []uint32       `protobuf:"varint,11,rep,packed,name=counts,proto3" json:"counts,omitempty"`             //各输出分片的交易数, 与拒绝的交易数一起作为默克尔树的第一个叶子, 保证每个分片收到的交易是完整的
+	Proof    [][]byte       `protobuf:"bytes,12,rep,name=proof,proto3" json:"proof,omitempty"`                       //本分片的交易和拒绝的交易在交易级默克尔树中的多重证明
+	AccFrom  []byte         `protobuf:"bytes,13,opt,name=acc_from,json=accFrom,proto3" json:"acc_from,omitempty"`    //源分片本 epoch 提交的交易并入累加器前的值
+	Acc      []byte         `protobuf:"bytes,14,opt,name=acc,proto3" json:"acc,omitempty"`                           //并入后的值, 与 acc_from 和 acc_exp 的摘要一起由 root 的签名覆盖
+	AccExp   []byte         `protobuf:"bytes,15,opt,name=acc_exp,json=accExp,proto3" json:"acc_exp,omitempty"`       //本 epoch 并入的交易代表数的乘积, acc_from^acc_exp = acc
+	AccProof []byte         `protobuf:"bytes,16,opt,name=acc_proof,json=accProof,proto3" json:"acc_proof,omitempty"` //acc_from^acc_exp = acc 的 PoE, 接收方不必计算 acc_exp 次幂即可验证累加器的更新
 }
 
 func (x *InputBFT_Result) Reset() {
 	*x = InputBFT_Result{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[10]
+		mi := &file_Message_proto_msgTypes[20]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -585,7 +1287,7 @@ func (x *InputBFT_Result) String() string {
 func (*InputBFT_Result) ProtoMessage() {}
 
 func (x *InputBFT_Result) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[10]
+	mi := &file_Message_proto_msgTypes[20]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -598,10 +1300,10 @@ func (x *InputBFT_Result) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use InputBFT_Result.ProtoReflect.Descriptor instead.
 func (*InputBFT_Result) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{10}
+	return file_Message_proto_rawDescGZIP(), []int{20}
 }
 
-func (x *InputBFT_Result) GetTxs() []string {
+func (x *InputBFT_Result) GetTxs() []*Transaction {
 	if x != nil {
 		return x.Txs
 	}
@@ -615,30 +1317,65 @@ func (x *InputBFT_Result) GetRoot() []byte {
 	return nil
 }
 
-func (x *InputBFT_Result) GetPath() [][]byte {
+func (x *InputBFT_Result) GetAggsig() []byte {
 	if x != nil {
-		return x.Path
+		return x.Aggsig
 	}
 	return nil
 }
 
-func (x *InputBFT_Result) GetIndicator() []int64 {
+func (x *InputBFT_Result) GetRejected() []*Transaction {
 	if x != nil {
-		return x.Indicator
+		return x.Rejected
 	}
 	return nil
 }
 
-func (x *InputBFT_Result) GetAggsig() []byte {
+func (x *InputBFT_Result) GetSigners() []byte {
 	if x != nil {
-		return x.Aggsig
+		return x.Signers
 	}
 	return nil
 }
 
-func (x *InputBFT_Result) GetAggpk() []byte {
+func (x *InputBFT_Result) GetCounts() []uint32 {
 	if x != nil {
-		return x.Aggpk
+		return x.Counts
+	}
+	return nil
+}
+
+func (x *InputBFT_Result) GetProof() [][]byte {
+	if x != nil {
+		return x.Proof
+	}
+	return nil
+}
+
+func (x *InputBFT_Result) GetAccFrom() []byte {
+	if x != nil {
+		return x.AccFrom
+	}
+	return nil
+}
+
+func (x *InputBFT_Result) GetAcc() []byte {
+	if x != nil {
+		return x.Acc
+	}
+	return nil
+}
+
+func (x *InputBFT_Result) GetAccExp() []byte {
+	if x != nil {
+		return x.AccExp
+	}
+	return nil
+}
+
+func (x *InputBFT_Result) GetAccProof() []byte {
+	if x != nil {
+		return x.AccProof
 	}
 	return nil
 }
@@ -658,7 +1395,7 @@ type NoLiveness struct {
 func (x *NoLiveness) Reset() {
 	*x = NoLiveness{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[11]
+		mi := &file_Message_proto_msgTypes[21]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -671,7 +1408,7 @@ func (x *NoLiveness) String() string {
 func (*NoLiveness) ProtoMessage() {}
 
 func (x *NoLiveness) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[11]
+	mi := &file_Message_proto_msgTypes[21]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -684,7 +1421,7 @@ func (x *NoLiveness) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NoLiveness.ProtoReflect.Descriptor instead.
 func (*NoLiveness) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{11}
+	return file_Message_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *NoLiveness) GetShardID() uint32 {
@@ -730,7 +1467,7 @@ type NL_Response struct {
 func (x *NL_Response) Reset() {
 	*x = NL_Response{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[12]
+		mi := &file_Message_proto_msgTypes[22]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -743,7 +1480,7 @@ func (x *NL_Response) String() string {
 func (*NL_Response) ProtoMessage() {}
 
 func (x *NL_Response) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[12]
+	mi := &file_Message_proto_msgTypes[22]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -756,7 +1493,7 @@ func (x *NL_Response) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NL_Response.ProtoReflect.Descriptor instead.
 func (*NL_Response) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{12}
+	return file_Message_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *NL_Response) GetShardID() uint32 {
@@ -808,7 +1545,7 @@ type NL_Confirm struct {
 func (x *NL_Confirm) Reset() {
 	*x = NL_Confirm{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[13]
+		mi := &file_Message_proto_msgTypes[23]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -821,7 +1558,7 @@ func (x *NL_Confirm) String() string {
 func (*NL_Confirm) ProtoMessage() {}
 
 func (x *NL_Confirm) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[13]
+	mi := &file_Message_proto_msgTypes[23]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -834,7 +1571,7 @@ func (x *NL_Confirm) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NL_Confirm.ProtoReflect.Descriptor instead.
 func (*NL_Confirm) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{13}
+	return file_Message_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *NL_Confirm) GetShardID() uint32 {
@@ -884,7 +1621,7 @@ type NoSafety struct {
 func (x *NoSafety) Reset() {
 	*x = NoSafety{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[14]
+		mi := &file_Message_proto_msgTypes[24]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -897,7 +1634,7 @@ func (x *NoSafety) String() string {
 func (*NoSafety) ProtoMessage() {}
 
 func (x *NoSafety) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[14]
+	mi := &file_Message_proto_msgTypes[24]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -910,7 +1647,7 @@ func (x *NoSafety) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NoSafety.ProtoReflect.Descriptor instead.
 func (*NoSafety) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{14}
+	return file_Message_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *NoSafety) GetShardID() uint32 {
@@ -983,7 +1720,7 @@ type NS_Choice struct {
 func (x *NS_Choice) Reset() {
 	*x = NS_Choice{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[15]
+		mi := &file_Message_proto_msgTypes[25]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -996,7 +1733,7 @@ func (x *NS_Choice) String() string {
 func (*NS_Choice) ProtoMessage() {}
 
 func (x *NS_Choice) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[15]
+	mi := &file_Message_proto_msgTypes[25]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1009,7 +1746,7 @@ func (x *NS_Choice) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use NS_Choice.ProtoReflect.Descriptor instead.
 func (*NS_Choice) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{15}
+	return file_Message_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *NS_Choice) GetShardID() uint32 {
@@ -1055,7 +1792,7 @@ type ReConfig struct {
 func (x *ReConfig) Reset() {
 	*x = ReConfig{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[16]
+		mi := &file_Message_proto_msgTypes[26]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1068,7 +1805,7 @@ func (x *ReConfig) String() string {
 func (*ReConfig) ProtoMessage() {}
 
 func (x *ReConfig) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[16]
+	mi := &file_Message_proto_msgTypes[26]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1081,7 +1818,7 @@ func (x *ReConfig) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReConfig.ProtoReflect.Descriptor instead.
 func (*ReConfig) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{16}
+	return file_Message_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *ReConfig) GetShardID() uint32 {
@@ -1127,7 +1864,7 @@ type RC_CheckOK struct {
 func (x *RC_CheckOK) Reset() {
 	*x = RC_CheckOK{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[17]
+		mi := &file_Message_proto_msgTypes[27]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1140,7 +1877,7 @@ func (x *RC_CheckOK) String() string {
 func (*RC_CheckOK) ProtoMessage() {}
 
 func (x *RC_CheckOK) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[17]
+	mi := &file_Message_proto_msgTypes[27]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1153,7 +1890,7 @@ func (x *RC_CheckOK) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RC_CheckOK.ProtoReflect.Descriptor instead.
 func (*RC_CheckOK) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{17}
+	return file_Message_proto_rawDescGZIP(), []int{27}
 }
 
 func (x *RC_CheckOK) GetShardID() uint32 {
@@ -1204,7 +1941,7 @@ type RC_NewEpoch struct {
 func (x *RC_NewEpoch) Reset() {
 	*x = RC_NewEpoch{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_Message_proto_msgTypes[18]
+		mi := &file_Message_proto_msgTypes[28]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -1217,7 +1954,7 @@ func (x *RC_NewEpoch) String() string {
 func (*RC_NewEpoch) ProtoMessage() {}
 
 func (x *RC_NewEpoch) ProtoReflect() protoreflect.Message {
-	mi := &file_Message_proto_msgTypes[18]
+	mi := &file_Message_proto_msgTypes[28]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1230,7 +1967,7 @@ func (x *RC_NewEpoch) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RC_NewEpoch.ProtoReflect.Descriptor instead.
 func (*RC_NewEpoch) Descriptor() ([]byte, []int) {
-	return file_Message_proto_rawDescGZIP(), []int{18}
+	return file_Message_proto_rawDescGZIP(), []int{28}
 }
 
 func (x *RC_NewEpoch) GetShardID() uint32 {
@@ -1258,47 +1995,127 @@ var File_Message_proto protoreflect.FileDescriptor
 
 var file_Message_proto_rawDesc = []byte{
 	0x0a, 0x0d, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
-	0x59, 0x0a, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79,
+	0x7d, 0x0a, 0x07, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79,
 	0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x0e,
 	0x0a, 0x02, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16,
 	0x0a, 0x06, 0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06,
 	0x73, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x1e, 0x0a, 0x08, 0x4e, 0x65,
-	0x77, 0x5f, 0x56, 0x69, 0x65, 0x77, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x6f, 0x6e, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6e, 0x6f, 0x6e, 0x65, 0x22, 0x1b, 0x0a, 0x07, 0x50, 0x72,
-	0x65, 0x70, 0x61, 0x72, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x01, 0x20, 0x03,
-	0x28, 0x09, 0x52, 0x03, 0x74, 0x78, 0x73, 0x22, 0x34, 0x0a, 0x0c, 0x50, 0x72, 0x65, 0x70, 0x61,
-	0x72, 0x65, 0x5f, 0x56, 0x6f, 0x74, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73,
-	0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67, 0x22, 0x39, 0x0a,
-	0x09, 0x50, 0x72, 0x65, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x67,
-	0x67, 0x73, 0x69, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x67, 0x67, 0x73,
-	0x69, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x67, 0x67, 0x70, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0c, 0x52, 0x05, 0x61, 0x67, 0x67, 0x70, 0x6b, 0x22, 0x36, 0x0a, 0x0e, 0x50, 0x72, 0x65, 0x63,
-	0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x5f, 0x56, 0x6f, 0x74, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x6f,
-	0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x12, 0x10,
-	0x0a, 0x03, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67,
-	0x22, 0x36, 0x0a, 0x06, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x67,
-	0x67, 0x73, 0x69, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x67, 0x67, 0x73,
-	0x69, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x61, 0x67, 0x67, 0x70, 0x6b, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0c, 0x52, 0x05, 0x61, 0x67, 0x67, 0x70, 0x6b, 0x22, 0x1e, 0x0a, 0x0a, 0x54, 0x58, 0x73, 0x5f,
-	0x49, 0x6e, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x10, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x01, 0x20,
-	0x03, 0x28, 0x09, 0x52, 0x03, 0x74, 0x78, 0x73, 0x22, 0x20, 0x0a, 0x0a, 0x53, 0x69, 0x67, 0x5f,
-	0x49, 0x6e, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x6f, 0x6e, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6e, 0x6f, 0x6e, 0x65, 0x22, 0x2e, 0x0a, 0x06, 0x53, 0x69,
-	0x67, 0x6d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0c, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x69, 0x67, 0x18,
-	0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67, 0x22, 0x97, 0x01, 0x0a, 0x0f, 0x49,
-	0x6e, 0x70, 0x75, 0x74, 0x42, 0x46, 0x54, 0x5f, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x10,
-	0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x03, 0x74, 0x78, 0x73,
-	0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04,
-	0x72, 0x6f, 0x6f, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x03, 0x20, 0x03,
-	0x28, 0x0c, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x69, 0x6e, 0x64, 0x69,
-	0x63, 0x61, 0x74, 0x6f, 0x72, 0x18, 0x04, 0x20, 0x03, 0x28, 0x03, 0x52, 0x09, 0x69, 0x6e, 0x64,
-	0x69, 0x63, 0x61, 0x74, 0x6f, 0x72, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69, 0x67,
-	0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69, 0x67, 0x12, 0x14,
-	0x0a, 0x05, 0x61, 0x67, 0x67, 0x70, 0x6b, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x61,
-	0x67, 0x67, 0x70, 0x6b, 0x22, 0x54, 0x0a, 0x0a, 0x4e, 0x6f, 0x4c, 0x69, 0x76, 0x65, 0x6e, 0x65,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x65,
+	0x71, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x65, 0x71, 0x12, 0x10, 0x0a, 0x03,
+	0x73, 0x69, 0x67, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67, 0x22, 0x4f,
+	0x0a, 0x07, 0x54, 0x78, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x68, 0x61,
+	0x72, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72, 0x64, 0x12,
+	0x16, 0x0a, 0x06, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x6d, 0x6f, 0x75, 0x6e,
+	0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x61, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x22,
+	0x50, 0x0a, 0x08, 0x54, 0x78, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x68, 0x61, 0x72, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x73, 0x68, 0x61, 0x72,
+	0x64, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x06, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x6d, 0x6f,
+	0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x61, 0x6d, 0x6f, 0x75, 0x6e,
+	0x74, 0x22, 0xa0, 0x01, 0x0a, 0x0b, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x02, 0x69,
+	0x64, 0x12, 0x20, 0x0a, 0x06, 0x69, 0x6e, 0x70, 0x75, 0x74, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x08, 0x2e, 0x54, 0x78, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x52, 0x06, 0x69, 0x6e, 0x70,
+	0x75, 0x74, 0x73, 0x12, 0x23, 0x0a, 0x07, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x18, 0x03,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x54, 0x78, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x52,
+	0x07, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x6e, 0x63,
+	0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x12, 0x10,
+	0x0a, 0x03, 0x73, 0x69, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67,
+	0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x22, 0x1e, 0x0a, 0x08, 0x4e, 0x65, 0x77, 0x5f, 0x56, 0x69, 0x65, 0x77,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x6f, 0x6e, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04,
+	0x6e, 0x6f, 0x6e, 0x65, 0x22, 0x4c, 0x0a, 0x07, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65, 0x12,
+	0x1c, 0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x06,
+	0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x23, 0x0a,
+	0x06, 0x68, 0x69, 0x67, 0x68, 0x51, 0x43, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e,
+	0x51, 0x75, 0x6f, 0x72, 0x75, 0x6d, 0x43, 0x65, 0x72, 0x74, 0x52, 0x06, 0x68, 0x69, 0x67, 0x68,
+	0x51, 0x43, 0x22, 0x34, 0x0a, 0x0c, 0x50, 0x72, 0x65, 0x70, 0x61, 0x72, 0x65, 0x5f, 0x56, 0x6f,
+	0x74, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67, 0x22, 0x3d, 0x0a, 0x09, 0x50, 0x72, 0x65, 0x63,
+	0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69, 0x67, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69, 0x67, 0x12, 0x18, 0x0a,
+	0x07, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07,
+	0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x73, 0x22, 0x36, 0x0a, 0x0e, 0x50, 0x72, 0x65, 0x63, 0x6f,
+	0x6d, 0x6d, 0x69, 0x74, 0x5f, 0x56, 0x6f, 0x74, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x6f, 0x74,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x76, 0x6f, 0x74, 0x65, 0x12, 0x10, 0x0a,
+	0x03, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67, 0x22,
+	0x3a, 0x0a, 0x06, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x67, 0x67,
+	0x73, 0x69, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69,
+	0x67, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x07, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x73, 0x22, 0xb2, 0x01, 0x0a, 0x05,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x16, 0x0a,
+	0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x70,
+	0x61, 0x72, 0x65, 0x6e, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65,
+	0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x70, 0x6f, 0x73, 0x65,
+	0x72, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x78, 0x52, 0x6f, 0x6f, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x06, 0x74, 0x78, 0x52, 0x6f, 0x6f, 0x74, 0x12, 0x25, 0x0a, 0x07, 0x6a, 0x75, 0x73,
+	0x74, 0x69, 0x66, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x51, 0x75, 0x6f,
+	0x72, 0x75, 0x6d, 0x43, 0x65, 0x72, 0x74, 0x52, 0x07, 0x6a, 0x75, 0x73, 0x74, 0x69, 0x66, 0x79,
+	0x12, 0x1e, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e,
+	0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x03, 0x74, 0x78, 0x73,
+	0x22, 0x9c, 0x01, 0x0a, 0x0a, 0x51, 0x75, 0x6f, 0x72, 0x75, 0x6d, 0x43, 0x65, 0x72, 0x74, 0x12,
+	0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05,
+	0x65, 0x70, 0x6f, 0x63, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x04, 0x76, 0x69, 0x65, 0x77, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x67, 0x67,
+	0x73, 0x69, 0x67, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69,
+	0x67, 0x12, 0x1c, 0x0a, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61, 0x73, 0x68, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61, 0x73, 0x68, 0x12,
+	0x18, 0x0a, 0x07, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x07, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x72, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x70, 0x68, 0x61,
+	0x73, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x70, 0x68, 0x61, 0x73, 0x65, 0x22,
+	0x6a, 0x0a, 0x07, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70,
+	0x6f, 0x63, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68,
+	0x12, 0x12, 0x0a, 0x04, 0x76, 0x69, 0x65, 0x77, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04,
+	0x76, 0x69, 0x65, 0x77, 0x12, 0x23, 0x0a, 0x06, 0x68, 0x69, 0x67, 0x68, 0x51, 0x43, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x51, 0x75, 0x6f, 0x72, 0x75, 0x6d, 0x43, 0x65, 0x72,
+	0x74, 0x52, 0x06, 0x68, 0x69, 0x67, 0x68, 0x51, 0x43, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x69, 0x67,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67, 0x22, 0x27, 0x0a, 0x07, 0x47,
+	0x65, 0x6e, 0x65, 0x72, 0x69, 0x63, 0x12, 0x1c, 0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x05, 0x62,
+	0x6c, 0x6f, 0x63, 0x6b, 0x22, 0x3e, 0x0a, 0x0c, 0x47, 0x65, 0x6e, 0x65, 0x72, 0x69, 0x63, 0x5f,
+	0x56, 0x6f, 0x74, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61, 0x73,
+	0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61,
+	0x73, 0x68, 0x12, 0x10, 0x0a, 0x03, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x03, 0x73, 0x69, 0x67, 0x22, 0x57, 0x0a, 0x0d, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48, 0x61,
+	0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x48,
+	0x61, 0x73, 0x68, 0x12, 0x12, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x65, 0x70, 0x6f, 0x63, 0x68, 0x22, 0x30, 0x0a,
+	0x0e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x1e, 0x0a, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x06, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x22,
+	0x2c, 0x0a, 0x0a, 0x54, 0x58, 0x73, 0x5f, 0x49, 0x6e, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x1e, 0x0a,
+	0x03, 0x74, 0x78, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x54, 0x72, 0x61,
+	0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x03, 0x74, 0x78, 0x73, 0x22, 0x20, 0x0a,
+	0x0a, 0x53, 0x69, 0x67, 0x5f, 0x49, 0x6e, 0x66, 0x6f, 0x72, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x6f, 0x6e, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6e, 0x6f, 0x6e, 0x65, 0x22,
+	0x2e, 0x0a, 0x06, 0x53, 0x69, 0x67, 0x6d, 0x73, 0x67, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12, 0x10, 0x0a,
+	0x03, 0x73, 0x69, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x73, 0x69, 0x67, 0x22,
+	0xb2, 0x02, 0x0a, 0x0f, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x42, 0x46, 0x54, 0x5f, 0x52, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x12, 0x1e, 0x0a, 0x03, 0x74, 0x78, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x0c, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x03,
+	0x74, 0x78, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x04, 0x72, 0x6f, 0x6f, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69,
+	0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x61, 0x67, 0x67, 0x73, 0x69, 0x67, 0x12,
+	0x28, 0x0a, 0x08, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x18, 0x07, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x0c, 0x2e, 0x54, 0x72, 0x61, 0x6e, 0x73, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52,
+	0x08, 0x72, 0x65, 0x6a, 0x65, 0x63, 0x74, 0x65, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x69, 0x67,
+	0x6e, 0x65, 0x72, 0x73, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x73, 0x69, 0x67, 0x6e,
+	0x65, 0x72, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x0b, 0x20,
+	0x03, 0x28, 0x0d, 0x52, 0x06, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x70,
+	0x72, 0x6f, 0x6f, 0x66, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x05, 0x70, 0x72, 0x6f, 0x6f,
+	0x66, 0x12, 0x19, 0x0a, 0x08, 0x61, 0x63, 0x63, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x0d, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x07, 0x61, 0x63, 0x63, 0x46, 0x72, 0x6f, 0x6d, 0x12, 0x10, 0x0a, 0x03,
+	0x61, 0x63, 0x63, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x61, 0x63, 0x63, 0x12, 0x17,
+	0x0a, 0x07, 0x61, 0x63, 0x63, 0x5f, 0x65, 0x78, 0x70, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x06, 0x61, 0x63, 0x63, 0x45, 0x78, 0x70, 0x12, 0x1b, 0x0a, 0x09, 0x61, 0x63, 0x63, 0x5f, 0x70,
+	0x72, 0x6f, 0x6f, 0x66, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x61, 0x63, 0x63, 0x50,
+	0x72, 0x6f, 0x6f, 0x66, 0x22, 0x54, 0x0a, 0x0a, 0x4e, 0x6f, 0x4c, 0x69, 0x76, 0x65, 0x6e, 0x65,
 	0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x68, 0x61, 0x72, 0x64, 0x49, 0x44, 0x18, 0x01, 0x20,
 	0x01, 0x28, 0x0d, 0x52, 0x07, 0x73, 0x68, 0x61, 0x72, 0x64, 0x49, 0x44, 0x12, 0x0c, 0x0a, 0x01,
 	0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x01, 0x68, 0x12, 0x0c, 0x0a, 0x01, 0x61, 0x18,
@@ -1367,34 +2184,56 @@ func file_Message_proto_rawDescGZIP() []byte {
 	return file_Message_proto_rawDescData
 }
 
-var file_Message_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_Message_proto_msgTypes = make([]protoimpl.MessageInfo, 29)
 var file_Message_proto_goTypes = []interface{}{
 	(*Message)(nil),         // 0: Message
-	(*New_View)(nil),        // 1: New_View
-	(*Prepare)(nil),         // 2: Prepare
-	(*Prepare_Vote)(nil),    // 3: Prepare_Vote
-	(*Precommit)(nil),       // 4: Precommit
-	(*Precommit_Vote)(nil),  // 5: Precommit_Vote
-	(*Commit)(nil),          // 6: Commit
-	(*TXs_Inform)(nil),      // 7: TXs_Inform
-	(*Sig_Inform)(nil),      // 8: Sig_Inform
-	(*Sigmsg)(nil),          // 9: Sigmsg
-	(*InputBFT_Result)(nil), // 10: InputBFT_Result
-	(*NoLiveness)(nil),      // 11: NoLiveness
-	(*NL_Response)(nil),     // 12: NL_Response
-	(*NL_Confirm)(nil),      // 13: NL_Confirm
-	(*NoSafety)(nil),        // 14: NoSafety
-	(*NS_Choice)(nil),       // 15: NS_Choice
-	(*ReConfig)(nil),        // 16: ReConfig
-	(*RC_CheckOK)(nil),      // 17: RC_CheckOK
-	(*RC_NewEpoch)(nil),     // 18: RC_NewEpoch
+	(*TxInput)(nil),         // 1: TxInput
+	(*TxOutput)(nil),        // 2: TxOutput
+	(*Transaction)(nil),     // 3: Transaction
+	(*New_View)(nil),        // 4: New_View
+	(*Prepare)(nil),         // 5: Prepare
+	(*Prepare_Vote)(nil),    // 6: Prepare_Vote
+	(*Precommit)(nil),       // 7: Precommit
+	(*Precommit_Vote)(nil),  // 8: Precommit_Vote
+	(*Commit)(nil),          // 9: Commit
+	(*Block)(nil),           // 10: Block
+	(*QuorumCert)(nil),      // 11: QuorumCert
+	(*Timeout)(nil),         // 12: Timeout
+	(*Generic)(nil),         // 13: Generic
+	(*Generic_Vote)(nil),    // 14: Generic_Vote
+	(*Block_Request)(nil),   // 15: Block_Request
+	(*Block_Response)(nil),  // 16: Block_Response
+	(*TXs_Inform)(nil),      // 17: TXs_Inform
+	(*Sig_Inform)(nil),      // 18: Sig_Inform
+	(*Sigmsg)(nil),          // 19: Sigmsg
+	(*InputBFT_Result)(nil), // 20: InputBFT_Result
+	(*NoLiveness)(nil),      // 21: NoLiveness
+	(*NL_Response)(nil),     // 22: NL_Response
+	(*NL_Confirm)(nil),      // 23: NL_Confirm
+	(*NoSafety)(nil),        // 24: NoSafety
+	(*NS_Choice)(nil),       // 25: NS_Choice
+	(*ReConfig)(nil),        // 26: ReConfig
+	(*RC_CheckOK)(nil),      // 27: RC_CheckOK
+	(*RC_NewEpoch)(nil),     // 28: RC_NewEpoch
 }
 var file_Message_proto_depIdxs = []int32{
-	0, // [0:0] is the sub-list for method output_type
-	0, // [0:0] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	1,  // 0: Transaction.inputs:type_name -> TxInput
+	2,  // 1: Transaction.outputs:type_name -> TxOutput
+	10, // 2: Prepare.block:type_name -> Block
+	11, // 3: Prepare.highQC:type_name -> QuorumCert
+	11, // 4: Block.justify:type_name -> QuorumCert
+	3,  // 5: Block.txs:type_name -> Transaction
+	11, // 6: Timeout.highQC:type_name -> QuorumCert
+	10, // 7: Generic.block:type_name -> Block
+	10, // 8: Block_Response.blocks:type_name -> Block
+	3,  // 9: TXs_Inform.txs:type_name -> Transaction
+	3,  // 10: InputBFT_Result.txs:type_name -> Transaction
+	3,  // 11: InputBFT_Result.rejected:type_name -> Transaction
+	12, // [12:12] is the sub-list for method output_type
+	12, // [12:12] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_Message_proto_init() }
@@ -1416,7 +2255,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*New_View); i {
+			switch v := v.(*TxInput); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1428,7 +2267,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Prepare); i {
+			switch v := v.(*TxOutput); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1440,7 +2279,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Prepare_Vote); i {
+			switch v := v.(*Transaction); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1452,7 +2291,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Precommit); i {
+			switch v := v.(*New_View); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1464,7 +2303,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Precommit_Vote); i {
+			switch v := v.(*Prepare); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1476,7 +2315,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Commit); i {
+			switch v := v.(*Prepare_Vote); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1488,7 +2327,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TXs_Inform); i {
+			switch v := v.(*Precommit); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1500,7 +2339,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Sig_Inform); i {
+			switch v := v.(*Precommit_Vote); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1512,7 +2351,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Sigmsg); i {
+			switch v := v.(*Commit); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1524,7 +2363,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*InputBFT_Result); i {
+			switch v := v.(*Block); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1536,7 +2375,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*NoLiveness); i {
+			switch v := v.(*QuorumCert); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1548,7 +2387,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*NL_Response); i {
+			switch v := v.(*Timeout); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1560,7 +2399,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*NL_Confirm); i {
+			switch v := v.(*Generic); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1572,7 +2411,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*NoSafety); i {
+			switch v := v.(*Generic_Vote); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1584,7 +2423,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*NS_Choice); i {
+			switch v := v.(*Block_Request); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1596,7 +2435,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ReConfig); i {
+			switch v := v.(*Block_Response); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1608,7 +2447,7 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*RC_CheckOK); i {
+			switch v := v.(*TXs_Inform); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1620,6 +2459,126 @@ func file_Message_proto_init() {
 			}
 		}
 		file_Message_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sig_Inform); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sigmsg); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InputBFT_Result); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NoLiveness); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[22].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NL_Response); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[23].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NL_Confirm); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[24].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NoSafety); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[25].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*NS_Choice); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[26].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ReConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[27].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RC_CheckOK); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_Message_proto_msgTypes[28].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*RC_NewEpoch); i {
 			case 0:
 				return &v.state
@@ -1638,7 +2597,7 @@ func file_Message_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_Message_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   19,
+			NumMessages:   29,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -0,0 +1,330 @@
+package txs
+
+import (
+	"Chamael/pkg/protobuf"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// GenesisBalance 账户第一次出现时的余额, 生成的交易都从新账户转出
+var GenesisBalance = uint64(1000)
+
+var (
+	ErrWrongShard   = errors.New("transaction does not involve this shard")
+	ErrExecuted     = errors.New("transaction has already been executed")
+	ErrInsufficient = errors.New("insufficient balance")
+	ErrUnbalanced   = errors.New("outputs exceed inputs")
+	ErrAborted      = errors.New("transaction has been aborted")
+	ErrUnproven     = errors.New("outputs of a cross-shard transaction need the results of all input shards")
+	ErrStaleNonce   = errors.New("nonce is not above the last nonce spent from the account")
+)
+
+// State 一个分片的账户状态, 交易输入的 object 为扣款账户, 输出的 object 为收款账户.
+// 片内交易执行时直接扣款入账; 跨片交易先在每个输入分片执行, 锁定(扣除)本分片的输入,
+// 输出分片收齐各输入分片的 InputBFT_Result 后由 ApplyOutputs 为本分片的输出入账.
+// 已完成和已中止的记录由 Prune 在若干 epoch 后清理, 此后的重放由账户的 nonce 拒绝:
+// 同一账户在本分片花费的交易 nonce 必须递增
+type State struct {
+	mu       sync.Mutex
+	shard    int
+	epoch    uint32                   // 当前 epoch, 记录交易完成或中止的时间
+	balances map[string]uint64        // 账户 -> 余额, 不在表中的账户余额为 GenesisBalance
+	nonces   map[string]uint64        // 账户 -> 在本分片花费过的最大 nonce, 不随 Prune 清理
+	locked   map[string]*lockedInputs // 交易ID -> 被该跨片交易锁定的本分片输入
+	done     map[string]uint32        // 已在本分片完成的交易 -> 完成时的 epoch
+	aborted  map[string]uint32        // 被某个输入分片拒绝而中止的跨片交易 -> 中止时的 epoch
+	pruned   uint64                   // 被 Prune 清理的记录总数
+}
+
+// lockedInputs 跨片交易在本分片锁定的输入, 以及锁定时的 epoch
+type lockedInputs struct {
+	Inputs []*protobuf.TxInput
+	Epoch  uint32
+}
+
+// NewState 创建分片 shard 的初始状态
+func NewState(shard int) *State {
+	return &State{
+		shard:    shard,
+		balances: make(map[string]uint64),
+		nonces:   make(map[string]uint64),
+		locked:   make(map[string]*lockedInputs),
+		done:     make(map[string]uint32),
+		aborted:  make(map[string]uint32),
+	}
+}
+
+// Shard 状态所属的分片
+func (s *State) Shard() int {
+	return s.shard
+}
+
+// Balance 账户在本分片的余额
+func (s *State) Balance(account []byte) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.balance(hex.EncodeToString(account))
+}
+
+func (s *State) balance(key string) uint64 {
+	if b, ok := s.balances[key]; ok {
+		return b
+	}
+	return GenesisBalance
+}
+
+// Locked 报告交易 id 是否在本分片锁定了输入且尚未完成
+func (s *State) Locked(id []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.locked[hex.EncodeToString(id)]
+	return ok
+}
+
+// Execute 按共识顺序执行一笔交易, 返回错误时状态不变:
+// 本分片有输入且尚未锁定时扣除这些输入, 片内交易同时入账.
+// 跨片交易的输出不由 Execute 入账, 本分片为输出分片时返回 ErrUnproven
+func (s *State) Execute(tx *protobuf.Transaction) error {
+	if err := checkAmounts(tx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := hex.EncodeToString(tx.Id)
+	if _, ok := s.aborted[id]; ok {
+		return ErrAborted
+	}
+	if _, ok := s.done[id]; ok {
+		return ErrExecuted
+	}
+	var inputs []*protobuf.TxInput
+	for _, in := range tx.Inputs {
+		if int(in.Shard) == s.shard {
+			inputs = append(inputs, in)
+		}
+	}
+	_, locked := s.locked[id]
+
+	switch {
+	case len(inputs) > 0 && !locked:
+		if err := s.checkNonce(inputs, tx.Nonce); err != nil {
+			return err
+		}
+		if err := s.debit(inputs); err != nil {
+			return err
+		}
+		for _, in := range inputs {
+			s.nonces[hex.EncodeToString(in.Object)] = tx.Nonce
+		}
+		if IsInternal(tx) {
+			s.credit(tx.Outputs)
+			s.done[id] = s.epoch
+		} else {
+			s.locked[id] = &lockedInputs{Inputs: inputs, Epoch: s.epoch}
+		}
+	case OutputShard(tx) == s.shard:
+		return ErrUnproven
+	case locked:
+		return ErrExecuted
+	default:
+		return ErrWrongShard
+	}
+	return nil
+}
+
+// ApplyOutputs 为本分片是输出分片的跨片交易入账, 返回错误时状态不变.
+// 调用者保证各输入分片都已通过 InputBFT_Result 证明锁定了输入, 即交易来自交易池的 CheckAndRemoveTransactions
+func (s *State) ApplyOutputs(tx *protobuf.Transaction) error {
+	if err := checkAmounts(tx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := hex.EncodeToString(tx.Id)
+	_, aborted := s.aborted[id]
+	_, done := s.done[id]
+	switch {
+	case aborted:
+		return ErrAborted
+	case done:
+		return ErrExecuted
+	case IsInternal(tx) || OutputShard(tx) != s.shard:
+		return ErrWrongShard
+	}
+	s.credit(tx.Outputs)
+	s.done[id] = s.epoch
+	delete(s.locked, id)
+	return nil
+}
+
+// Rejected 报告 Execute 返回的错误是否表示本分片的输入无效, 此时跨片交易应被中止
+func Rejected(err error) bool {
+	return errors.Is(err, ErrInsufficient) || errors.Is(err, ErrUnbalanced)
+}
+
+// Abort 中止被某个输入分片拒绝的跨片交易: 退还本分片已锁定的输入,
+// 尚未执行的交易以后执行时返回 ErrAborted. 交易已在本分片完成时返回 false
+func (s *State) Abort(tx *protobuf.Transaction) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := hex.EncodeToString(tx.Id)
+	if _, ok := s.done[id]; ok {
+		return false
+	}
+	if lock, ok := s.locked[id]; ok {
+		for _, in := range lock.Inputs {
+			key := hex.EncodeToString(in.Object)
+			s.balances[key] = s.balance(key) + in.Amount
+		}
+		delete(s.locked, id)
+	}
+	s.aborted[id] = s.epoch
+	return true
+}
+
+// Prune 进入 epoch e, 清理 window 个 epoch 之前完成或中止的交易记录, 返回清理的记录数.
+// 输入分片不会收到跨片交易完成的通知: 输出分片最迟在 window 个 epoch 后让交易超时, 下一个 epoch 通知各输入分片,
+// 所以锁定超过 window+1 个 epoch 仍未被中止的交易已在输出分片入账, 释放其锁定记录并视为在本分片完成
+func (s *State) Prune(e, window uint32) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.epoch = e
+	for id, lock := range s.locked {
+		if lock.Epoch+window+1 < e {
+			delete(s.locked, id)
+			s.done[id] = e
+		}
+	}
+	n := 0
+	for _, records := range []map[string]uint32{s.done, s.aborted} {
+		for id, epoch := range records {
+			if epoch+window < e {
+				delete(records, id)
+				n++
+			}
+		}
+	}
+	s.pruned += uint64(n)
+	return n
+}
+
+// Pruned 被 Prune 清理的记录总数
+func (s *State) Pruned() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pruned
+}
+
+// checkNonce 检查 nonce 大于 inputs 中每个账户在本分片花费过的最大 nonce, 从未花费过的账户不限制
+func (s *State) checkNonce(inputs []*protobuf.TxInput, nonce uint64) error {
+	for _, in := range inputs {
+		key := hex.EncodeToString(in.Object)
+		if last, ok := s.nonces[key]; ok && nonce <= last {
+			return fmt.Errorf("%w: account %s", ErrStaleNonce, key)
+		}
+	}
+	return nil
+}
+
+// debit 检查余额后扣除 inputs, 同一账户的多个输入合并检查
+func (s *State) debit(inputs []*protobuf.TxInput) error {
+	need := make(map[string]uint64)
+	for _, in := range inputs {
+		key := hex.EncodeToString(in.Object)
+		total := need[key] + in.Amount
+		if total < in.Amount || total > s.balance(key) {
+			return fmt.Errorf("%w: account %s", ErrInsufficient, key)
+		}
+		need[key] = total
+	}
+	for key, amount := range need {
+		s.balances[key] = s.balance(key) - amount
+	}
+	return nil
+}
+
+// credit 为输出中属于本分片的账户入账
+func (s *State) credit(outputs []*protobuf.TxOutput) {
+	for _, out := range outputs {
+		if int(out.Shard) == s.shard {
+			key := hex.EncodeToString(out.Object)
+			s.balances[key] = s.balance(key) + out.Amount
+		}
+	}
+}
+
+// checkAmounts 检查输出总额不超过输入总额, 差额作为手续费销毁
+func checkAmounts(tx *protobuf.Transaction) error {
+	var in, out uint64
+	for _, i := range tx.Inputs {
+		if in+i.Amount < in {
+			return ErrUnbalanced
+		}
+		in += i.Amount
+	}
+	for _, o := range tx.Outputs {
+		if out+o.Amount < out {
+			return ErrUnbalanced
+		}
+		out += o.Amount
+	}
+	if out > in {
+		return ErrUnbalanced
+	}
+	return nil
+}
+
+// stateSnapshot State 的序列化格式
+type stateSnapshot struct {
+	Shard    int
+	Epoch    uint32
+	Balances map[string]uint64
+	Nonces   map[string]uint64
+	Locked   map[string]*lockedInputs
+	Done     map[string]uint32
+	Aborted  map[string]uint32
+	Pruned   uint64
+}
+
+// Snapshot 序列化状态, 用于节点重启后恢复
+func (s *State) Snapshot() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.Marshal(stateSnapshot{
+		Shard: s.shard, Epoch: s.epoch, Balances: s.balances, Nonces: s.nonces, Locked: s.locked,
+		Done: s.done, Aborted: s.aborted, Pruned: s.pruned,
+	})
+}
+
+// RestoreState 从 Snapshot 的结果恢复状态
+func RestoreState(data []byte) (*State, error) {
+	var snap stateSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	s := NewState(snap.Shard)
+	s.epoch, s.pruned = snap.Epoch, snap.Pruned
+	for key, b := range snap.Balances {
+		s.balances[key] = b
+	}
+	for key, nonce := range snap.Nonces {
+		s.nonces[key] = nonce
+	}
+	for key, lock := range snap.Locked {
+		s.locked[key] = lock
+	}
+	for key, e := range snap.Done {
+		s.done[key] = e
+	}
+	for key, e := range snap.Aborted {
+		s.aborted[key] = e
+	}
+	return s, nil
+}
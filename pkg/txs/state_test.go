@@ -0,0 +1,174 @@
+package txs
+
+import (
+	"Chamael/pkg/protobuf"
+	"errors"
+	"testing"
+)
+
+// transfer 生成从 from 的各个输入账户转出 amount, 转入分片 to 的账户 "to" 的交易
+func transfer(from []uint32, to uint32, amount uint64, nonce uint64) *protobuf.Transaction {
+	tx := &protobuf.Transaction{Nonce: nonce}
+	for _, shard := range from {
+		tx.Inputs = append(tx.Inputs, &protobuf.TxInput{Shard: shard, Object: []byte("from"), Amount: amount})
+	}
+	tx.Outputs = []*protobuf.TxOutput{{Shard: to, Object: []byte("to"), Amount: amount * uint64(len(from))}}
+	tx.Id = Hash(tx)
+	return tx
+}
+
+func TestStateRejectsDoubleSpends(t *testing.T) {
+	s := NewState(0)
+	first := transfer([]uint32{0}, 0, GenesisBalance-1, 1)
+	if err := s.Execute(first); err != nil {
+		t.Fatalf("failed to execute a valid transaction: %v", err)
+	}
+	if s.Balance([]byte("from")) != 1 || s.Balance([]byte("to")) != 2*GenesisBalance-1 {
+		t.Errorf("unexpected balances %d %d", s.Balance([]byte("from")), s.Balance([]byte("to")))
+	}
+	if err := s.Execute(first); !errors.Is(err, ErrExecuted) {
+		t.Errorf("expected ErrExecuted for a replayed transaction, got %v", err)
+	}
+	if err := s.Execute(transfer([]uint32{0}, 0, 2, 2)); !errors.Is(err, ErrInsufficient) {
+		t.Errorf("expected ErrInsufficient for a double spend, got %v", err)
+	}
+
+	unbalanced := transfer([]uint32{0}, 0, 1, 3)
+	unbalanced.Outputs[0].Amount = 2
+	if err := s.Execute(unbalanced); !errors.Is(err, ErrUnbalanced) {
+		t.Errorf("expected ErrUnbalanced, got %v", err)
+	}
+}
+
+func TestStateCrossShardTransaction(t *testing.T) {
+	in, out := NewState(0), NewState(1)
+	tx := transfer([]uint32{0}, 1, GenesisBalance, 1)
+
+	// 输入分片锁定输入, 余额不足以再次花费
+	if err := in.Execute(tx); err != nil || !in.Locked(tx.Id) {
+		t.Fatalf("expected the input shard to lock the inputs, got %v", err)
+	}
+	if err := in.Execute(transfer([]uint32{0}, 0, 1, 2)); !errors.Is(err, ErrInsufficient) {
+		t.Errorf("expected the locked balance to be unavailable, got %v", err)
+	}
+	if err := in.Execute(tx); !errors.Is(err, ErrExecuted) {
+		t.Errorf("expected ErrExecuted when locking twice, got %v", err)
+	}
+
+	// 输出分片不经输入分片的结果直接执行时不入账
+	if err := out.Execute(tx); !errors.Is(err, ErrUnproven) || out.Balance([]byte("to")) != GenesisBalance {
+		t.Fatalf("expected an unproven transaction not to credit anything, got %v and balance %d", err, out.Balance([]byte("to")))
+	}
+	if err := out.ApplyOutputs(tx); err != nil {
+		t.Fatalf("failed to apply the outputs: %v", err)
+	}
+	if out.Balance([]byte("to")) != 2*GenesisBalance {
+		t.Errorf("unexpected output balance %d", out.Balance([]byte("to")))
+	}
+	if err := out.ApplyOutputs(tx); !errors.Is(err, ErrExecuted) {
+		t.Errorf("expected ErrExecuted when applying the outputs twice, got %v", err)
+	}
+	if err := NewState(2).Execute(tx); !errors.Is(err, ErrWrongShard) {
+		t.Errorf("expected ErrWrongShard, got %v", err)
+	}
+
+	data, err := in.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+	restored, err := RestoreState(data)
+	if err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+	if restored.Shard() != 0 || !restored.Locked(tx.Id) || restored.Balance([]byte("from")) != 0 {
+		t.Errorf("state was not restored")
+	}
+}
+
+func TestStateAbortRefundsLockedInputs(t *testing.T) {
+	s := NewState(0)
+	tx := transfer([]uint32{0, 1}, 2, GenesisBalance, 1)
+	if err := s.Execute(tx); err != nil {
+		t.Fatalf("failed to lock the inputs: %v", err)
+	}
+	if !s.Abort(tx) || s.Locked(tx.Id) || s.Balance([]byte("from")) != GenesisBalance {
+		t.Errorf("expected the locked inputs to be refunded, balance %d", s.Balance([]byte("from")))
+	}
+
+	// 拒绝先于本分片执行到达时, 交易不再锁定输入
+	late := transfer([]uint32{0, 1}, 2, 1, 2)
+	s.Abort(late)
+	if err := s.Execute(late); !errors.Is(err, ErrAborted) {
+		t.Errorf("expected ErrAborted, got %v", err)
+	}
+	if err := s.Execute(transfer([]uint32{0}, 1, GenesisBalance+1, 3)); !Rejected(err) {
+		t.Errorf("expected an overspending input to be rejected, got %v", err)
+	}
+}
+
+// 超过窗口的已完成和已中止记录被清理, 窗口内外的重放都被拒绝
+func TestStatePrune(t *testing.T) {
+	s := NewState(0)
+	s.Prune(1, 2)
+	done := transfer([]uint32{0}, 0, 1, 1)
+	if err := s.Execute(done); err != nil {
+		t.Fatalf("failed to execute: %v", err)
+	}
+	s.Abort(transfer([]uint32{0, 1}, 2, 1, 2))
+
+	if n := s.Prune(3, 2); n != 0 {
+		t.Errorf("expected no records within the window to be pruned, got %d", n)
+	}
+	if err := s.Execute(done); !errors.Is(err, ErrExecuted) {
+		t.Errorf("expected ErrExecuted within the window, got %v", err)
+	}
+	if n := s.Prune(4, 2); n != 2 || s.Pruned() != 2 || len(s.done) != 0 || len(s.aborted) != 0 {
+		t.Errorf("expected both records to be pruned, got %d", n)
+	}
+	// 清理后重放已执行的交易, 由账户的 nonce 拒绝, 余额不再变化
+	balance := s.Balance([]byte("from"))
+	if err := s.Execute(done); !errors.Is(err, ErrStaleNonce) || s.Balance([]byte("from")) != balance {
+		t.Errorf("expected the replay to be rejected by its nonce, got %v and balance %d", err, s.Balance([]byte("from")))
+	}
+
+	data, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+	restored, err := RestoreState(data)
+	if err != nil || restored.Pruned() != 2 || restored.epoch != 4 {
+		t.Fatalf("pruning progress was not restored: %v", err)
+	}
+	if err := restored.Execute(done); !errors.Is(err, ErrStaleNonce) {
+		t.Errorf("expected the restored state to reject the replay, got %v", err)
+	}
+}
+
+// 输入分片锁定的输入在跨片交易不会再被中止后释放, 交易视为在本分片完成
+func TestStatePruneReleasesCompletedLocks(t *testing.T) {
+	in, out := NewState(0), NewState(1)
+	in.Prune(1, 2)
+	tx := transfer([]uint32{0}, 1, 1, 1)
+	if err := in.Execute(tx); err != nil {
+		t.Fatalf("failed to lock the inputs: %v", err)
+	}
+	if err := out.ApplyOutputs(tx); err != nil {
+		t.Fatalf("failed to apply the outputs: %v", err)
+	}
+
+	// 输出分片的超时通知最迟在 window+1 个 epoch 后到达, 此前仍可退还
+	in.Prune(4, 2)
+	if !in.Locked(tx.Id) {
+		t.Fatalf("expected the inputs to stay locked while the transaction can still be aborted")
+	}
+	in.Prune(5, 2)
+	if in.Locked(tx.Id) || len(in.locked) != 0 || in.Balance([]byte("from")) != GenesisBalance-1 {
+		t.Errorf("expected the lock to be released without a refund, balance %d", in.Balance([]byte("from")))
+	}
+	if err := in.Execute(tx); !errors.Is(err, ErrExecuted) {
+		t.Errorf("expected ErrExecuted for the completed transaction, got %v", err)
+	}
+	if in.Abort(tx) || in.Balance([]byte("from")) != GenesisBalance-1 {
+		t.Errorf("expected a late abort not to refund the completed transaction")
+	}
+}
@@ -1,22 +1,26 @@
 package txs
 
 import (
-	"fmt"
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
 	"math/rand"
-	"regexp"
-	"strconv"
-	"strings"
+	"sort"
 
 	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/proto"
 )
 
-type Transaction struct {
-	DummyTX     string `json:"DummyTX"`
-	InputShard  []int  `json:"InputShard"`
-	InputValid  []int  `json:"InputValid"`
-	OutputShard int    `json:"OutputShard"`
-	OutputValid int    `json:"OutputValid"`
-}
+var (
+	ErrNoInputs   = errors.New("transaction has no inputs")
+	ErrNoOutputs  = errors.New("transaction has no outputs")
+	ErrMismatchID = errors.New("transaction id does not match its content")
+	ErrUnsigned   = errors.New("transaction is not signed")
+	ErrBadOwner   = errors.New("inputs of a transaction must belong to one ed25519 public key")
+	ErrBadSig     = errors.New("transaction signature is invalid")
+)
 
 func randomString(size int, chars string) string {
 	result := make([]byte, size)
@@ -42,13 +46,134 @@ func contains(slice []int, value int) bool {
 	return false
 }
 
-func InterTxGenerator(size int, shardID int, PID int, chars string) string {
-	randomString := randomString(size, chars)
-	shardInfo := fmt.Sprintf(", Userset: %d, Input Shard: [%d], Input Valid: [1], Output Shard: %d, Output Valid: 2", PID, shardID, shardID)
-	//↑目前只考虑合法交易
-	return fmt.Sprintf("<Dummy TX: %s%s >", randomString, shardInfo)
+// Marshal 交易的确定性编码, 相同的交易总是得到相同的字节
+func Marshal(tx *protobuf.Transaction) []byte {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(tx)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// Hash 交易ID: 清空 id 和 sig 后编码的 sha256
+func Hash(tx *protobuf.Transaction) []byte {
+	body := proto.Clone(tx).(*protobuf.Transaction)
+	body.Id, body.Sig = nil, nil
+	hash := sha256.Sum256(Marshal(body))
+	return hash[:]
+}
+
+// Sign 设置交易ID, 并用输入所有者的私钥 sk 对ID签名. 交易的每个输入的 object 都应为 sk 对应的公钥
+func Sign(tx *protobuf.Transaction, sk ed25519.PrivateKey) {
+	tx.Id = Hash(tx)
+	tx.Sig = ed25519.Sign(sk, tx.Id)
+}
+
+// Verify 检查交易是否有输入输出, id 是否与内容一致, 以及是否由输入的所有者签名
+func Verify(tx *protobuf.Transaction) error {
+	if len(tx.Inputs) == 0 {
+		return ErrNoInputs
+	}
+	if len(tx.Outputs) == 0 {
+		return ErrNoOutputs
+	}
+	if !bytes.Equal(tx.Id, Hash(tx)) {
+		return ErrMismatchID
+	}
+	if len(tx.Sig) == 0 {
+		return ErrUnsigned
+	}
+	owner := tx.Inputs[0].Object
+	if len(owner) != ed25519.PublicKeySize {
+		return ErrBadOwner
+	}
+	for _, in := range tx.Inputs {
+		if !bytes.Equal(in.Object, owner) {
+			return ErrBadOwner
+		}
+	}
+	if !ed25519.Verify(owner, tx.Id, tx.Sig) {
+		return ErrBadSig
+	}
+	return nil
+}
+
+// InputShards 交易的输入分片, 升序且不重复
+func InputShards(tx *protobuf.Transaction) []int {
+	var shards []int
+	for _, in := range tx.Inputs {
+		if !contains(shards, int(in.Shard)) {
+			shards = append(shards, int(in.Shard))
+		}
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// OutputShard 交易的输出分片, 目前一笔交易的输出都在同一个分片, 没有输出时返回 -1
+func OutputShard(tx *protobuf.Transaction) int {
+	if len(tx.Outputs) == 0 {
+		return -1
+	}
+	return int(tx.Outputs[0].Shard)
 }
-func CrossTxGenerator(size, shardNum, Rrate int, PID int, chars string) string {
+
+// IsInternal 所有输入分片都与输出分片相同的交易为片内交易
+func IsInternal(tx *protobuf.Transaction) bool {
+	out := OutputShard(tx)
+	for _, shard := range InputShards(tx) {
+		if shard != out {
+			return false
+		}
+	}
+	return true
+}
+
+// Encode 把交易编码为字符串, 用于默克尔树和累加器
+func Encode(list []*protobuf.Transaction) []string {
+	encoded := make([]string, len(list))
+	for i, tx := range list {
+		encoded[i] = string(Marshal(tx))
+	}
+	return encoded
+}
+
+// newTransaction 生成从新账户转出的交易, 账户为随机生成的 ed25519 公钥, 在各输入分片各有一份余额.
+// 无效的输入转出的金额超过新账户的余额, 会被输入分片拒绝
+func newTransaction(size int, inputShards []int, inputValid []int, outputShard int, chars string) *protobuf.Transaction {
+	owner, sk, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		panic(err)
+	}
+	tx := &protobuf.Transaction{Nonce: rand.Uint64()}
+	var total uint64
+	for i, shard := range inputShards {
+		amount := uint64(1)
+		if inputValid[i] == 0 {
+			amount = GenesisBalance + 1
+		}
+		tx.Inputs = append(tx.Inputs, &protobuf.TxInput{
+			Shard:  uint32(shard),
+			Object: owner,
+			Amount: amount,
+		})
+		total += amount
+	}
+	tx.Outputs = []*protobuf.TxOutput{{
+		Shard:  uint32(outputShard),
+		Object: []byte(randomString(size, chars)),
+		Amount: total,
+	}}
+	Sign(tx, sk)
+	return tx
+}
+
+// InterTxGenerator 生成输入输出都在 shardID 的片内交易, 对象ID为 size 个随机字符
+func InterTxGenerator(size int, shardID int, PID int, chars string) *protobuf.Transaction {
+	//目前只考虑合法交易
+	return newTransaction(size, []int{shardID}, []int{1}, shardID, chars)
+}
+func CrossTxGenerator(size, shardNum, Rrate int, PID int, chars string) *protobuf.Transaction {
 
 	// Determine the number of input shards
 	inputShardNum := rand.Intn(3) + 1
@@ -59,17 +184,14 @@ func CrossTxGenerator(size, shardNum, Rrate int, PID int, chars string) string {
 	// Select input shards
 	inputShards := randomSample(0, shardNum, inputShardNum)
 
-	// Generate input validity
+	// Generate input validity, Rrate is the percentage of valid inputs
 	inputValid := make([]int, inputShardNum)
 	for i := range inputValid {
-		/*
-			if rand.Intn(100) < Rrate {
-				inputValid[i] = 1
-			} else {
-				inputValid[i] = 0
-			}
-		*/
-		inputValid[i] = 1 //目前只考虑合法交易
+		if rand.Intn(100) < Rrate {
+			inputValid[i] = 1
+		} else {
+			inputValid[i] = 0
+		}
 	}
 
 	// Choose output shard
@@ -82,66 +204,15 @@ func CrossTxGenerator(size, shardNum, Rrate int, PID int, chars string) string {
 		}
 	}
 
-	randomString := randomString(size, chars)
-	shardInfo := fmt.Sprintf(", Userset: %d, Input Shard: %v, Input Valid: %v, Output Shard: %d, Output Valid: 0",
-		PID, inputShards, inputValid, outputShard)
-
-	return fmt.Sprintf("<Dummy TX: %s%s >", randomString, shardInfo)
-}
-
-func ExtractTransactionDetails(tx string) (*Transaction, error) {
-	// 定义正则表达式模式
-	re := regexp.MustCompile(
-		`Input Shard: \[([0-9 ]+)\], Input Valid: \[([0-9 ]+)\], Output Shard: ([0-9]+), Output Valid: ([0-9]+)`,
-	)
-
-	// 查找匹配
-	matches := re.FindStringSubmatch(tx)
-	if len(matches) < 5 {
-		return nil, fmt.Errorf("transaction format is invalid")
-	}
-
-	// 提取和解析数据
-	inputShardsStr := matches[1]
-	inputValidsStr := matches[2]
-	outputShardStr := matches[3]
-	outputValidStr := matches[4]
-
-	// 解析 InputShard 列表
-	inputShards := parseIntList(inputShardsStr)
-	// 解析 InputValid 列表
-	inputValids := parseIntList(inputValidsStr)
-	// 解析 OutputShard 和 OutputValid
-	outputShard, err := strconv.Atoi(outputShardStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Output Shard: %v", err)
-	}
-	outputValid, err := strconv.Atoi(outputValidStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Output Valid: %v", err)
-	}
-
-	// 返回交易数据结构
-	return &Transaction{
-		InputShard:  inputShards,
-		InputValid:  inputValids,
-		OutputShard: outputShard,
-		OutputValid: outputValid,
-	}, nil
+	return newTransaction(size, inputShards, inputValid, outputShard, chars)
 }
 
-// 辅助函数：解析一个以逗号分隔的数字字符串为整型列表
-func parseIntList(str string) []int {
-	str = strings.Trim(str, "[]")           // 去掉开头和结尾的方括号
-	str = strings.ReplaceAll(str, ",", " ") // 替换逗号为空格（支持逗号分隔格式）
-	str = strings.TrimSpace(str)            // 去掉首尾空格
-	numStrs := strings.Fields(str)          // 根据空格分割
-	var nums []int
-	for _, numStr := range numStrs {
-		num, err := strconv.Atoi(numStr)
-		if err == nil {
-			nums = append(nums, num)
-		}
-	}
-	return nums
+// PoolStats 输出分片交易池中等待输入分片结果的跨片交易的统计
+type PoolStats struct {
+	Pending     int            // 尚未完成的交易数
+	Expired     uint64         // 超时被中止的交易总数
+	Aborted     int            // 尚未清理的被拒绝或超时而中止的交易数
+	Pruned      uint64         // 交易池中被清理的中止记录总数
+	StatePruned uint64         // 账户状态中被清理的已完成和已中止记录总数
+	OldestAge   map[int]uint32 // 输入分片 -> 等待该分片结果的最老交易已等待的 epoch 数
 }
@@ -1,89 +1,97 @@
 package txs
 
 import (
+	"Chamael/pkg/protobuf"
+	"crypto/ed25519"
+	"errors"
 	"reflect"
 	"testing"
+
+	"google.golang.org/protobuf/proto"
 )
 
-func TestExtractTransactionDetails(t *testing.T) {
-	tests := []struct {
-		name      string
-		tx        string
-		want      *Transaction
-		wantError bool
-	}{
-		{
-			name: "Valid Transaction",
-			tx:   "<Dummy TX: PT2PCFTPBGTGT3V2TYR8BCXVJPY7P5UY, Userset: 10, Input Shard: [1], Input Valid: [0], Output Shard: 2, Output Valid: 0 >",
-			want: &Transaction{
-				InputShard:  []int{1},
-				InputValid:  []int{0},
-				OutputShard: 2,
-				OutputValid: 0,
-			},
-			wantError: false,
-		},
-		{
-			name:      "Invalid Transaction",
-			tx:        "<Invalid TX: Missing Fields>",
-			want:      nil,
-			wantError: true,
-		},
-		{
-			name: "Valid Transaction with Multiple Inputs",
-			tx:   "<Dummy TX: PT2PCFTPBGTGT3V2TYR8BCXVJPY7P5UY, Userset: 10, Input Shard: [1 2 3], Input Valid: [0 1 0], Output Shard: 5, Output Valid: 1 >",
-			want: &Transaction{
-				InputShard:  []int{1, 2, 3},
-				InputValid:  []int{0, 1, 0},
-				OutputShard: 5,
-				OutputValid: 1,
-			},
-			wantError: false,
-		},
+const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func TestGeneratedTransactions(t *testing.T) {
+	itx := InterTxGenerator(32, 2, 10, chars)
+	if err := Verify(itx); err != nil {
+		t.Fatalf("generated internal transaction is invalid: %v", err)
+	}
+	if !IsInternal(itx) || OutputShard(itx) != 2 || !reflect.DeepEqual(InputShards(itx), []int{2}) {
+		t.Errorf("unexpected internal transaction %v", itx)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := ExtractTransactionDetails(tt.tx)
-			if (err != nil) != tt.wantError {
-				t.Errorf("extractTransactionDetails() error = %v, wantError %v", err, tt.wantError)
-				return
-			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("extractTransactionDetails() = %v, want %v", got, tt.want)
-			}
-		})
+	for i := 0; i < 100; i++ {
+		ctx := CrossTxGenerator(32, 4, 10, 10, chars)
+		if err := Verify(ctx); err != nil {
+			t.Fatalf("generated cross-shard transaction is invalid: %v", err)
+		}
+		if IsInternal(ctx) || contains(InputShards(ctx), OutputShard(ctx)) {
+			t.Fatalf("output shard of a cross-shard transaction is one of its inputs: %v", ctx)
+		}
 	}
 }
 
-func TestParseIntList(t *testing.T) {
-	tests := []struct {
-		name string
-		str  string
-		want []int
-	}{
-		{
-			name: "Single Number",
-			str:  "1",
-			want: []int{1},
-		},
-		{
-			name: "Multiple Numbers",
-			str:  "1, 2, 3",
-			want: []int{1, 2, 3},
-		},
-		{
-			name: "Spaces and Commas",
-			str:  " 1 ,  2,3 ",
-			want: []int{1, 2, 3},
-		},
+func TestInputShardsAreSortedAndUnique(t *testing.T) {
+	tx := &protobuf.Transaction{Inputs: []*protobuf.TxInput{{Shard: 3}, {Shard: 1}, {Shard: 3}}}
+	if got := InputShards(tx); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Errorf("InputShards() = %v, want [1 3]", got)
 	}
+}
+
+func TestVerifyRejectsModifiedTransactions(t *testing.T) {
+	tx := InterTxGenerator(32, 0, 10, chars)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if got := parseIntList(tt.str); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("parseIntList() = %v, want %v", got, tt.want)
-			}
-		})
+	if err := Verify(tx); err != nil {
+		t.Errorf("expected a generated transaction to verify, got %v", err)
+	}
+	// 签名不参与交易ID, 替换签名后ID仍一致但签名无效
+	resigned := proto.Clone(tx).(*protobuf.Transaction)
+	resigned.Sig = make([]byte, ed25519.SignatureSize)
+	if err := Verify(resigned); !errors.Is(err, ErrBadSig) {
+		t.Errorf("expected ErrBadSig, got %v", err)
+	}
+
+	modified := proto.Clone(tx).(*protobuf.Transaction)
+	modified.Outputs[0].Amount++
+	if err := Verify(modified); !errors.Is(err, ErrMismatchID) {
+		t.Errorf("expected ErrMismatchID, got %v", err)
+	}
+	if err := Verify(&protobuf.Transaction{Data: []byte("NL")}); !errors.Is(err, ErrNoInputs) {
+		t.Errorf("expected ErrNoInputs, got %v", err)
+	}
+}
+
+// 不能花费别人账户中的输入: 未签名, 用自己的私钥签名别人的输入, 或输入属于多个账户的交易都被拒绝
+func TestVerifyRejectsForeignInputs(t *testing.T) {
+	victim, _, _ := ed25519.GenerateKey(nil)
+	attacker, sk, _ := ed25519.GenerateKey(nil)
+	spend := func(owners ...ed25519.PublicKey) *protobuf.Transaction {
+		tx := &protobuf.Transaction{Outputs: []*protobuf.TxOutput{{Shard: 1, Object: attacker, Amount: 1}}}
+		for _, owner := range owners {
+			tx.Inputs = append(tx.Inputs, &protobuf.TxInput{Shard: 0, Object: owner, Amount: 1})
+		}
+		return tx
+	}
+
+	unsigned := spend(victim)
+	unsigned.Id = Hash(unsigned)
+	if err := Verify(unsigned); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected ErrUnsigned, got %v", err)
+	}
+	stolen := spend(victim)
+	Sign(stolen, sk)
+	if err := Verify(stolen); !errors.Is(err, ErrBadSig) {
+		t.Errorf("expected ErrBadSig, got %v", err)
+	}
+	mixed := spend(attacker, victim)
+	Sign(mixed, sk)
+	if err := Verify(mixed); !errors.Is(err, ErrBadOwner) {
+		t.Errorf("expected ErrBadOwner, got %v", err)
+	}
+	own := spend(attacker)
+	Sign(own, sk)
+	if err := Verify(own); err != nil {
+		t.Errorf("expected a transaction signed by its owner to verify, got %v", err)
 	}
 }
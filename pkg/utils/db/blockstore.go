@@ -0,0 +1,211 @@
+package db
+
+import (
+	"Chamael/pkg/protobuf"
+	"database/sql"
+	"fmt"
+	"math/big"
+
+	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/proto"
+)
+
+// BlockRecord 账本中的一条记录: 已提交的区块、证明其被提交的 QC 以及提交后的累加器值
+// 累加器按 epoch 更新, Acc 是提交该区块的 epoch 结束时的值, 同一 epoch 提交的区块 Acc 相同
+type BlockRecord struct {
+	Height uint32
+	Hash   []byte
+	Parent []byte
+	TxRoot []byte
+	QC     *protobuf.QuorumCert
+	Acc    *big.Int
+	Block  *protobuf.Block
+}
+
+// BlockStore 基于 SQLite 的已提交区块存储, 按高度和区块哈希索引
+type BlockStore struct {
+	db *sql.DB
+}
+
+// OpenBlockStore 打开(不存在时创建)区块存储, 已有的记录会被保留
+func OpenBlockStore(filename string) (*BlockStore, error) {
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	_, err = db.Exec("PRAGMA journal_mode = WAL")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set WAL mode: %v", err)
+	}
+
+	createTableSQL := `
+	CREATE TABLE IF NOT EXISTS blocks (
+		height INTEGER PRIMARY KEY,
+		hash BLOB NOT NULL UNIQUE,
+		parent BLOB NOT NULL,
+		tx_root BLOB,
+		qc BLOB,
+		acc TEXT,
+		block BLOB NOT NULL
+	);`
+	_, err = db.Exec(createTableSQL)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create table: %v", err)
+	}
+
+	// 节点重启所需的其他状态(epoch、lockedQC、交易池等)以键值形式保存
+	createMetaSQL := `
+	CREATE TABLE IF NOT EXISTS meta (
+		key TEXT PRIMARY KEY,
+		value BLOB
+	);`
+	_, err = db.Exec(createMetaSQL)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create meta table: %v", err)
+	}
+	return &BlockStore{db: db}, nil
+}
+
+// Close 关闭区块存储
+func (s *BlockStore) Close() error {
+	return s.db.Close()
+}
+
+// PutBlock 记录一个已提交的区块, 同一高度的旧记录会被覆盖
+func (s *BlockStore) PutBlock(hash []byte, b *protobuf.Block, qc *protobuf.QuorumCert, acc *big.Int) error {
+	blockData, err := proto.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("failed to marshal block: %v", err)
+	}
+	var qcData []byte
+	if qc != nil {
+		qcData, err = proto.Marshal(qc)
+		if err != nil {
+			return fmt.Errorf("failed to marshal qc: %v", err)
+		}
+	}
+	var accStr sql.NullString
+	if acc != nil {
+		accStr = sql.NullString{String: acc.String(), Valid: true}
+	}
+
+	_, err = s.db.Exec(`INSERT OR REPLACE INTO blocks (height, hash, parent, tx_root, qc, acc, block) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		b.Height, hash, b.Parent, b.TxRoot, qcData, accStr, blockData)
+	if err != nil {
+		return fmt.Errorf("failed to insert block %d: %v", b.Height, err)
+	}
+	return nil
+}
+
+// GetBlockByHeight 按高度读取区块, 不存在时返回 nil
+func (s *BlockStore) GetBlockByHeight(height uint32) (*BlockRecord, error) {
+	row := s.db.QueryRow(`SELECT height, hash, parent, tx_root, qc, acc, block FROM blocks WHERE height = ?`, height)
+	return scanBlock(row)
+}
+
+// GetBlockByHash 按区块哈希读取区块, 不存在时返回 nil
+func (s *BlockStore) GetBlockByHash(hash []byte) (*BlockRecord, error) {
+	row := s.db.QueryRow(`SELECT height, hash, parent, tx_root, qc, acc, block FROM blocks WHERE hash = ?`, hash)
+	return scanBlock(row)
+}
+
+// LastHeight 返回已记录的最高区块高度, 账本为空时返回 0
+func (s *BlockStore) LastHeight() (uint32, error) {
+	var height sql.NullInt64
+	err := s.db.QueryRow(`SELECT MAX(height) FROM blocks`).Scan(&height)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query last height: %v", err)
+	}
+	return uint32(height.Int64), nil
+}
+
+// Iterate 从高度 from 开始按高度顺序遍历区块, fn 返回 false 时停止
+func (s *BlockStore) Iterate(from uint32, fn func(*BlockRecord) bool) error {
+	rows, err := s.db.Query(`SELECT height, hash, parent, tx_root, qc, acc, block FROM blocks WHERE height >= ? ORDER BY height`, from)
+	if err != nil {
+		return fmt.Errorf("failed to query database: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanBlock(rows)
+		if err != nil {
+			return err
+		}
+		if !fn(r) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %v", err)
+	}
+	return nil
+}
+
+// PutMeta 在同一个事务中写入多个键值, 保证重启时读到的状态一致
+func (s *BlockStore) PutMeta(kv map[string][]byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	for key, value := range kv {
+		_, err = tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, key, value)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to write meta %s: %v", key, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// GetMeta 读取键对应的值, 不存在时返回 nil
+func (s *BlockStore) GetMeta(key string) ([]byte, error) {
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meta %s: %v", key, err)
+	}
+	return value, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanBlock(row scanner) (*BlockRecord, error) {
+	var r BlockRecord
+	var qcData, blockData []byte
+	var accStr sql.NullString
+	err := row.Scan(&r.Height, &r.Hash, &r.Parent, &r.TxRoot, &qcData, &accStr, &blockData)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan row: %v", err)
+	}
+
+	r.Block = new(protobuf.Block)
+	if err := proto.Unmarshal(blockData, r.Block); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal block %d: %v", r.Height, err)
+	}
+	if qcData != nil {
+		r.QC = new(protobuf.QuorumCert)
+		if err := proto.Unmarshal(qcData, r.QC); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal qc %d: %v", r.Height, err)
+		}
+	}
+	if accStr.Valid {
+		r.Acc, _ = new(big.Int).SetString(accStr.String, 10)
+	}
+	return &r, nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"Chamael/pkg/protobuf"
+	"bytes"
+	"math/big"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlockStore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "blocks.db")
+	store, err := OpenBlockStore(filename)
+	if err != nil {
+		t.Fatalf("failed to open block store: %v", err)
+	}
+
+	if h, err := store.LastHeight(); err != nil || h != 0 {
+		t.Fatalf("expected empty store, got height %d err %v", h, err)
+	}
+
+	parent := []byte("genesis")
+	for h := uint32(1); h <= 3; h++ {
+		hash := []byte{byte(h)}
+		b := &protobuf.Block{Height: h, Parent: parent, TxRoot: []byte("root"), Txs: []*protobuf.Transaction{{Data: []byte("tx")}}}
+		qc := &protobuf.QuorumCert{Epoch: h, BlockHash: hash}
+		if err := store.PutBlock(hash, b, qc, big.NewInt(int64(100+h))); err != nil {
+			t.Fatalf("failed to put block %d: %v", h, err)
+		}
+		parent = hash
+	}
+	store.Close()
+
+	// 重新打开后记录仍然存在
+	store, err = OpenBlockStore(filename)
+	if err != nil {
+		t.Fatalf("failed to reopen block store: %v", err)
+	}
+	defer store.Close()
+
+	if h, _ := store.LastHeight(); h != 3 {
+		t.Errorf("expected last height 3, got %d", h)
+	}
+
+	r, err := store.GetBlockByHeight(2)
+	if err != nil || r == nil {
+		t.Fatalf("failed to get block by height: %v", err)
+	}
+	if !bytes.Equal(r.Parent, []byte{1}) || r.QC.Epoch != 2 || r.Acc.Int64() != 102 || string(r.Block.Txs[0].Data) != "tx" {
+		t.Errorf("unexpected record at height 2: %+v", r)
+	}
+
+	r, err = store.GetBlockByHash([]byte{3})
+	if err != nil || r == nil || r.Height != 3 {
+		t.Fatalf("failed to get block by hash: %v", err)
+	}
+
+	if r, _ := store.GetBlockByHeight(4); r != nil {
+		t.Errorf("expected nil for missing height, got %+v", r)
+	}
+
+	var heights []uint32
+	err = store.Iterate(2, func(r *BlockRecord) bool {
+		heights = append(heights, r.Height)
+		return true
+	})
+	if err != nil || len(heights) != 2 || heights[0] != 2 || heights[1] != 3 {
+		t.Errorf("unexpected iteration result %v, err %v", heights, err)
+	}
+}
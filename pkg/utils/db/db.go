@@ -1,6 +1,7 @@
 package db
 
 import (
+	"Chamael/pkg/protobuf"
 	"database/sql"
 	"fmt"
 	"log"
@@ -8,9 +9,11 @@ import (
 	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
+	"google.golang.org/protobuf/proto"
 )
 
-func SaveTxsToSQL(txs []string, filename string) {
+// SaveTxsToSQL 把交易以 protobuf 编码写入新的数据库文件
+func SaveTxsToSQL(txs []*protobuf.Transaction, filename string) {
 	if _, err := os.Stat(filename); err == nil {
 		err := os.Remove(filename)
 		if err != nil {
@@ -35,7 +38,7 @@ func SaveTxsToSQL(txs []string, filename string) {
 	createTableSQL := `
 	CREATE TABLE IF NOT EXISTS transactions (
 	    id INTEGER PRIMARY KEY,
-		tx TEXT NOT NULL
+		tx BLOB NOT NULL
 	);`
 	_, err = tx.Exec(createTableSQL)
 	if err != nil {
@@ -61,7 +64,12 @@ func SaveTxsToSQL(txs []string, filename string) {
 	defer stmt.Close()
 
 	// 批量插入数据
-	for _, txData := range txs {
+	for _, t := range txs {
+		txData, err := proto.Marshal(t)
+		if err != nil {
+			tx.Rollback()
+			log.Fatalf("Error encoding transaction: %v\n", err)
+		}
 		_, err = stmt.Exec(txData)
 		if err != nil {
 			tx.Rollback()
@@ -76,7 +84,8 @@ func SaveTxsToSQL(txs []string, filename string) {
 	}
 }
 
-func LoadAndDeleteTxsFromDB(dbPath string, limit int) ([]string, error) {
+// LoadAndDeleteTxsFromDB 读出至多 limit 笔交易并从数据库中删除
+func LoadAndDeleteTxsFromDB(dbPath string, limit int) ([]*protobuf.Transaction, error) {
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
@@ -107,18 +116,23 @@ func LoadAndDeleteTxsFromDB(dbPath string, limit int) ([]string, error) {
 	}
 	defer rows.Close()
 
-	var txs []string
+	var txs []*protobuf.Transaction
 	var txIDs []int
 
 	// 读取数据库中的事务
 	for rows.Next() {
 		var id int
-		var txData string
+		var txData []byte
 		if err := rows.Scan(&id, &txData); err != nil {
 			tx.Rollback()
 			return nil, fmt.Errorf("failed to scan row: %v", err)
 		}
-		txs = append(txs, txData)
+		t := new(protobuf.Transaction)
+		if err := proto.Unmarshal(txData, t); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to decode transaction %d: %v", id, err)
+		}
+		txs = append(txs, t)
 		txIDs = append(txIDs, id)
 	}
 
@@ -3,36 +3,17 @@ package logger
 import (
 	"Chamael/internal/party"
 	"Chamael/pkg/config"
+	"Chamael/pkg/protobuf"
 	"Chamael/pkg/txs"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
-// isInternal 判断该交易是否为片内交易
-// 通过检查输入输出分片是否相同来区分片内交易和跨片交易
-func isInternal(tx string) bool {
-	// 使用 ExtractTransactionDetails 解析交易
-	transaction, err := txs.ExtractTransactionDetails(tx)
-	if err != nil {
-		fmt.Printf("Error parsing transaction: %v\n", err)
-		return false // 解析失败，默认认为是跨片交易
-	}
-
-	// 判断输入分片和输出分片是否相同
-	// 仅在所有输入分片和输出分片都相同的情况下，认为是片内交易
-	for _, inputShard := range transaction.InputShard {
-		if inputShard != transaction.OutputShard {
-			return false // 如果任何输入分片与输出分片不同，则是跨片交易
-		}
-	}
-
-	// 如果输入和输出分片都相同，认为是片内交易
-	return true
-}
-
 // CalculateTPS 计算并记录总TPS、片内TPS和跨片TPS到指定文件
-func CalculateTPS(c config.HonestConfig, p party.HonestParty, path string, timeChannel chan time.Time, outputChannel chan []string, block_delay_channel chan time.Duration, round_delay_channel chan time.Duration, extra_delay_channel chan time.Duration) {
+func CalculateTPS(c config.HonestConfig, p party.HonestParty, path string, timeChannel chan time.Time, outputChannel chan []*protobuf.Transaction, block_delay_channel chan time.Duration, round_delay_channel chan time.Duration, extra_delay_channel chan time.Duration, pool_stats_channel chan txs.PoolStats) {
 	var earliestTime, latestTime time.Time
 	var totalTransactions, internalTransactions, crossShardTransactions int
 
@@ -49,7 +30,7 @@ func CalculateTPS(c config.HonestConfig, p party.HonestParty, path string, timeC
 	for {
 		// 检查timeChannel
 		var timestamp time.Time
-		var txBatch []string
+		var txBatch []*protobuf.Transaction
 		var timeChannelEmpty, outputChannelEmpty bool
 
 		// 从 timeChannel 获取数据
@@ -71,7 +52,7 @@ func CalculateTPS(c config.HonestConfig, p party.HonestParty, path string, timeC
 		case txBatch = <-outputChannel:
 			// 计算交易数量并分类
 			if len(txBatch) > 0 {
-				if isInternal(txBatch[0]) {
+				if txs.IsInternal(txBatch[0]) {
 					internalTransactions += len(txBatch)
 				} else {
 					crossShardTransactions += len(txBatch)
@@ -173,16 +154,42 @@ roundDelayDone:
 
 	latency := (1-c.Crate)*avgBlockDelay + c.Crate*(avgBlockDelay+avgRoundDelay)
 
+	// 交易池统计取最后一个 epoch 的结果
+	var poolStats txs.PoolStats
+	for {
+		select {
+		case poolStats = <-pool_stats_channel:
+		default:
+			goto poolStatsDone
+		}
+	}
+poolStatsDone:
+	shards := make([]int, 0, len(poolStats.OldestAge))
+	for shard := range poolStats.OldestAge {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	var oldestAge []string
+	for _, shard := range shards {
+		oldestAge = append(oldestAge, fmt.Sprintf("shard %d: %d", shard, poolStats.OldestAge[shard]))
+	}
+
 	// 修改日志消息，添加延迟信息
 	logMessage := fmt.Sprintf(
 		"Total Transactions: %d\nInternal Transactions: %d\nCross-Shard Transactions: %d\n"+
 			"Total TPS: %.2f\nInternal TPS: %.2f\nCross-Shard TPS: %.2f\n"+
 			"Average Block Delay: %.2f ms\nAverage Round Delay: %.2f ms\nLatency: %.2f ms\n"+
-			"Intra-Shard Traffic: %.2f MB\nCross-Shard Traffic: %.2f MB\n",
+			"Intra-Shard Traffic: %.2f MB\nCross-Shard Traffic: %.2f MB\n"+
+			"Pending Cross-Shard Transactions: %d\nExpired Cross-Shard Transactions: %d\nAborted Cross-Shard Transactions: %d\n"+
+			"Pruned Records (pool/state): %d/%d\n"+
+			"Oldest Pending Age (epochs): %s\n",
 		totalTransactions, internalTransactions, crossShardTransactions,
 		totalTPS, internalTPS, crossShardTPS,
 		avgBlockDelay, avgRoundDelay, latency,
 		p.IntraShardTraffic, p.CrossShardTraffic,
+		poolStats.Pending, poolStats.Expired, poolStats.Aborted,
+		poolStats.Pruned, poolStats.StatePruned,
+		strings.Join(oldestAge, ", "),
 	)
 	_, err = fmt.Fprintln(file, logMessage)
 	if err != nil {
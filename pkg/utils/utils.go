@@ -53,6 +53,13 @@ func Uint32ToBytes(n uint32) []byte {
 	return bytebuf.Bytes()
 }
 
+// Uint64ToBytes convert uint64 to bytes
+func Uint64ToBytes(n uint64) []byte {
+	bytebuf := bytes.NewBuffer([]byte{})
+	binary.Write(bytebuf, binary.BigEndian, n)
+	return bytebuf.Bytes()
+}
+
 // BytesToUint32 convert bytes to uint32
 func BytesToUint32(byt []byte) uint32 {
 	bytebuff := bytes.NewBuffer(byt)